@@ -0,0 +1,94 @@
+package ziggurat
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// AckFunc acknowledges an event, a nil error acknowledges the event
+// a non nil error tells the message consumer that the event could not be processed
+type AckFunc func(err error)
+
+type ackKey struct{}
+
+// ackContext carries the Ack without allocating a new context for every event
+type ackContext struct {
+	context.Context
+	a *Ack
+}
+
+func (c *ackContext) Value(key any) any {
+	if _, ok := key.(ackKey); ok {
+		return c.a
+	}
+	return c.Context.Value(key)
+}
+
+// Ack tracks the acknowledgement of a single event
+// message consumers attach it to the context passed to the handler
+// handlers which finish processing an event after Handle returns
+// can take over the acknowledgement by calling DeferAck
+type Ack struct {
+	ctx ackContext
+	f   AckFunc
+	// state holds the generation of the event in the upper bits and whether it is acknowledged in the lowest bit,
+	// the generation changes on Reset so that an AckFunc handed out for an earlier event is a no-op
+	state    atomic.Uint64
+	deferred atomic.Bool
+}
+
+// WithAck returns a copy of ctx which carries an Ack,
+// f is invoked exactly once, either by Release or by the AckFunc returned by DeferAck
+func WithAck(ctx context.Context, f AckFunc) (context.Context, *Ack) {
+	a := &Ack{}
+	return a.Reset(ctx, f), a
+}
+
+// Reset prepares the Ack for a new event and returns the context to be passed to the handler,
+// message consumers can use it to reuse an Ack once the previous event has been acknowledged
+// an AckFunc handed out by DeferAck for the previous event is a no-op after Reset
+func (a *Ack) Reset(ctx context.Context, f AckFunc) context.Context {
+	a.ctx = ackContext{Context: ctx, a: a}
+	a.f = f
+	a.deferred.Store(false)
+	// the first generation which is not acknowledged after the current one
+	a.state.Store((a.state.Load() | 1) + 1)
+	return &a.ctx
+}
+
+// ack acknowledges the event of the generation, it is a no-op once the event is acknowledged or the Ack is reset
+func (a *Ack) ack(generation uint64, err error) {
+	if a.state.CompareAndSwap(generation, generation|1) {
+		a.f(err)
+	}
+}
+
+// Release acknowledges the event unless the acknowledgement was deferred
+// message consumers must call Release after the handler returns
+func (a *Ack) Release() {
+	if a.deferred.Load() {
+		return
+	}
+	a.ack(a.state.Load()&^1, nil)
+}
+
+// Deferred reports whether a handler took over the acknowledgement
+func (a *Ack) Deferred() bool {
+	return a.deferred.Load()
+}
+
+// DeferAck takes over the acknowledgement of the event being handled
+// the returned AckFunc must be called once the event is processed
+// the second return value is false if the message consumer does not support deferred acknowledgements
+// in which case the event is acknowledged as soon as the handler returns
+func DeferAck(ctx context.Context) (AckFunc, bool) {
+	a, ok := ctx.Value(ackKey{}).(*Ack)
+	if !ok {
+		return func(error) {}, false
+	}
+	a.deferred.Store(true)
+	generation := a.state.Load() &^ 1
+	return func(err error) {
+		a.ack(generation, err)
+	}, true
+}
@@ -0,0 +1,87 @@
+package ziggurat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAck(t *testing.T) {
+	t.Run("release acknowledges the event when not deferred", func(t *testing.T) {
+		var calls int
+		var got error = errors.New("not called")
+		ctx, ack := WithAck(context.Background(), func(err error) {
+			calls++
+			got = err
+		})
+		HandlerFunc(func(ctx context.Context, event *Event) {}).Handle(ctx, &Event{})
+		ack.Release()
+		ack.Release()
+		if calls != 1 || got != nil {
+			t.Errorf("expected one nil ack got calls:%d err:%v", calls, got)
+		}
+	})
+
+	t.Run("deferred ack is called by the handler", func(t *testing.T) {
+		var calls int
+		var got error
+		wantErr := errors.New("flush failed")
+		var deferred AckFunc
+		ctx, ack := WithAck(context.Background(), func(err error) {
+			calls++
+			got = err
+		})
+		HandlerFunc(func(ctx context.Context, event *Event) {
+			f, ok := DeferAck(ctx)
+			if !ok {
+				t.Error("expected ack to be deferrable")
+			}
+			deferred = f
+		}).Handle(ctx, &Event{})
+		ack.Release()
+		if calls != 0 {
+			t.Errorf("expected ack to be deferred, got %d calls", calls)
+		}
+		if !ack.Deferred() {
+			t.Error("expected Deferred to be true")
+		}
+		deferred(wantErr)
+		deferred(nil)
+		if calls != 1 || !errors.Is(got, wantErr) {
+			t.Errorf("expected one ack with %v got calls:%d err:%v", wantErr, calls, got)
+		}
+	})
+
+	t.Run("a deferred ack of an earlier event is a no-op once the ack is reset", func(t *testing.T) {
+		var acked []string
+		var stale AckFunc
+		a := &Ack{}
+		ctx := a.Reset(context.Background(), func(err error) { acked = append(acked, "first") })
+		stale, _ = DeferAck(ctx)
+		stale(nil)
+
+		ctx = a.Reset(context.Background(), func(err error) { acked = append(acked, "second") })
+		HandlerFunc(func(ctx context.Context, event *Event) {
+			ack, _ := DeferAck(ctx)
+			// a duplicate call of the earlier AckFunc does not acknowledge the event which holds the Ack now
+			stale(nil)
+			if len(acked) != 1 {
+				t.Errorf("expected the second event to not be acknowledged by the earlier AckFunc got %v", acked)
+			}
+			ack(nil)
+		}).Handle(ctx, &Event{})
+		a.Release()
+		if got := strings.Join(acked, ","); got != "first,second" {
+			t.Errorf("expected every event to be acknowledged once in order got %s", got)
+		}
+	})
+
+	t.Run("defer without an ack in the context", func(t *testing.T) {
+		f, ok := DeferAck(context.Background())
+		if ok {
+			t.Error("expected ok to be false")
+		}
+		f(nil)
+	})
+}
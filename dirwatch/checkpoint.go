@@ -0,0 +1,147 @@
+package dirwatch
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// checkpoint is persisted for every file being processed so that a restart
+// resumes after the last contiguously acknowledged event
+type checkpoint struct {
+	Acked  int  `json:"acked"`
+	Failed bool `json:"failed"`
+	// Size, ModTime and Inode identify the file the checkpoint belongs to, a file dropped with the same name
+	// or a file moved before its checkpoint was removed does not resume from it
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Inode   uint64    `json:"inode"`
+}
+
+func (cp *checkpoint) identify(info os.FileInfo) {
+	cp.Size = info.Size()
+	cp.ModTime = info.ModTime()
+	cp.Inode = inode(info)
+}
+
+func (cp checkpoint) identifies(info os.FileInfo) bool {
+	return cp.Size == info.Size() && cp.ModTime.Equal(info.ModTime()) && cp.Inode == inode(info)
+}
+
+func loadCheckpoint(path string) (checkpoint, error) {
+	var cp checkpoint
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, err
+	}
+	err = json.Unmarshal(b, &cp)
+	return cp, err
+}
+
+// save writes the checkpoint to a temporary file which is renamed so that a crash never leaves a partial checkpoint
+func (cp checkpoint) save(path string) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fileState tracks the acknowledgements of the events emitted for a single file
+type fileState struct {
+	path       string
+	name       string
+	checkpoint string
+	mu         sync.Mutex
+	cp         checkpoint
+	acked      map[int]struct{}
+	skip       int
+	emitted    int
+	finished   bool
+	abandoned  bool
+	completed  bool
+	// stale is set when the checkpoint of the file name belonged to another file
+	stale bool
+}
+
+func newFileState(dir, stateDir, name string, info os.FileInfo) (*fileState, error) {
+	fs := &fileState{
+		path:       filepath.Join(dir, name),
+		name:       name,
+		checkpoint: filepath.Join(stateDir, name+".json"),
+		acked:      map[int]struct{}{},
+	}
+	cp, err := loadCheckpoint(fs.checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	if cp != (checkpoint{}) && !cp.identifies(info) {
+		fs.stale = true
+		cp = checkpoint{}
+	}
+	cp.identify(info)
+	fs.cp = cp
+	fs.skip = cp.Acked
+	fs.emitted = cp.Acked
+	return fs, nil
+}
+
+// next registers a new event and returns its sequence number
+func (fs *fileState) next() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	seq := fs.emitted
+	fs.emitted++
+	return seq
+}
+
+// ack records the outcome of an event, it returns true once the file is finished and every event is acknowledged
+func (fs *fileState) ack(seq int, failed bool) (bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	changed := failed && !fs.cp.Failed
+	fs.cp.Failed = fs.cp.Failed || failed
+	fs.acked[seq] = struct{}{}
+	for {
+		if _, ok := fs.acked[fs.cp.Acked]; !ok {
+			break
+		}
+		delete(fs.acked, fs.cp.Acked)
+		fs.cp.Acked++
+		changed = true
+	}
+	var err error
+	if changed {
+		err = fs.cp.save(fs.checkpoint)
+	}
+	return fs.completeLocked(), err
+}
+
+// finish marks that no more events are emitted for the file, an abandoned file was not fully read
+// it returns true if every event is already acknowledged
+func (fs *fileState) finish(abandoned bool) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.finished = true
+	fs.abandoned = abandoned
+	return fs.completeLocked()
+}
+
+// completeLocked returns true exactly once
+func (fs *fileState) completeLocked() bool {
+	if !fs.finished || fs.completed || fs.cp.Acked < fs.emitted {
+		return false
+	}
+	fs.completed = true
+	return true
+}
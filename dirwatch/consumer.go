@@ -0,0 +1,316 @@
+package dirwatch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+)
+
+const (
+	EventType = "file"
+	// ModeFile emits a single event per file with the content of the file as the value
+	ModeFile = "file"
+	// ModeLines emits an event per non empty line
+	ModeLines = "lines"
+	// KeyPath holds the path of the file when it was read
+	KeyPath = "file-path"
+	// KeyName holds the name of the file
+	KeyName = "file-name"
+	// KeyLine holds the line number of the event as an int, it is only set in ModeLines
+	KeyLine = "file-line"
+	// KeyModTime holds the modification time of the file
+	KeyModTime = "file-modtime"
+)
+
+const stateDirName = ".ziggurat"
+
+// Consumer watches a directory and emits the files dropped into it as events
+//
+// A file is moved to the DoneDir once all of its events are acknowledged, or to the FailedDir
+// if any of them failed through a deferred acknowledgement. The number of acknowledged events
+// of every file being processed is checkpointed, a restart resumes after the last checkpoint.
+// Files are processed one at a time in lexical order, hidden files are ignored.
+// The RoutingPath of an event is the name of the file.
+type Consumer struct {
+	// Dir is the directory to watch
+	Dir string
+	// Pattern is a filepath.Match pattern for the file names, it defaults to "*"
+	Pattern string
+	// Mode is either ModeFile or ModeLines, it defaults to ModeFile
+	Mode string
+	// DoneDir and FailedDir default to the done and failed directories within Dir
+	DoneDir   string
+	FailedDir string
+	// PollInterval is the time between directory scans, it defaults to 1s
+	PollInterval time.Duration
+	// MinAge skips files which were modified recently, it can be used when files are not written atomically
+	MinAge time.Duration
+	Logger ziggurat.StructuredLogger
+
+	stateDir string
+	pending  sync.WaitGroup
+	mu       sync.Mutex
+	active   map[string]struct{}
+}
+
+func (c *Consumer) init() error {
+	if c.Logger == nil {
+		c.Logger = logger.NOOP
+	}
+	if c.Dir == "" {
+		return errors.New("dirwatch: Dir is required")
+	}
+	if c.Pattern == "" {
+		c.Pattern = "*"
+	}
+	if _, err := filepath.Match(c.Pattern, ""); err != nil {
+		return fmt.Errorf("dirwatch: invalid pattern %q: %w", c.Pattern, err)
+	}
+	if c.Mode == "" {
+		c.Mode = ModeFile
+	}
+	if c.Mode != ModeFile && c.Mode != ModeLines {
+		return fmt.Errorf("dirwatch: unknown mode %q", c.Mode)
+	}
+	if c.DoneDir == "" {
+		c.DoneDir = filepath.Join(c.Dir, "done")
+	}
+	if c.FailedDir == "" {
+		c.FailedDir = filepath.Join(c.Dir, "failed")
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Second
+	}
+	c.stateDir = filepath.Join(c.Dir, stateDirName)
+	c.active = map[string]struct{}{}
+	for _, d := range []string{c.DoneDir, c.FailedDir, c.stateDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			return fmt.Errorf("dirwatch: %w", err)
+		}
+	}
+	return nil
+}
+
+// Consume scans the directory every PollInterval until ctx is done
+// it waits for the pending acknowledgements before returning
+func (c *Consumer) Consume(ctx context.Context, h ziggurat.Handler) error {
+	if err := c.init(); err != nil {
+		return err
+	}
+	defer c.pending.Wait()
+
+	ticker := time.NewTicker(c.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.scan(ctx, h); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Consumer) scan(ctx context.Context, h ziggurat.Handler) error {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return fmt.Errorf("dirwatch: %w", err)
+	}
+	// ReadDir returns the entries sorted by file name
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return nil
+		}
+		name := e.Name()
+		if !e.Type().IsRegular() || strings.HasPrefix(name, ".") || c.isActive(name) {
+			continue
+		}
+		if ok, _ := filepath.Match(c.Pattern, name); !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			// the file was moved since the directory was read
+			continue
+		}
+		if c.MinAge > 0 && time.Since(info.ModTime()) < c.MinAge {
+			continue
+		}
+		if err := c.processFile(ctx, h, name, info); err != nil {
+			c.Logger.Error("dirwatch error processing file", err, map[string]any{"file": name})
+		}
+	}
+	return nil
+}
+
+func (c *Consumer) isActive(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.active[name]
+	return ok
+}
+
+func (c *Consumer) setActive(name string, active bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if active {
+		c.active[name] = struct{}{}
+		return
+	}
+	delete(c.active, name)
+}
+
+func (c *Consumer) processFile(ctx context.Context, h ziggurat.Handler, name string, info os.FileInfo) error {
+	fs, err := newFileState(c.Dir, c.stateDir, name, info)
+	if err != nil {
+		return err
+	}
+	c.setActive(name, true)
+	if fs.stale {
+		c.Logger.Info("dirwatch discarding checkpoint of another file", map[string]any{"file": name})
+	}
+	if fs.skip > 0 {
+		c.Logger.Info("dirwatch resuming file", map[string]any{"file": name, "acknowledged": fs.skip})
+	} else {
+		c.Logger.Info("dirwatch processing file", map[string]any{"file": name})
+	}
+
+	var readErr error
+	if c.Mode == ModeFile {
+		readErr = c.emitFile(ctx, h, fs, info)
+	} else {
+		readErr = c.emitLines(ctx, h, fs, info)
+	}
+	// a file which was not fully read is left in place and resumed by the next scan or after a restart
+	if fs.finish(readErr != nil || ctx.Err() != nil) {
+		c.complete(fs)
+	}
+	return readErr
+}
+
+func (c *Consumer) emitFile(ctx context.Context, h ziggurat.Handler, fs *fileState, info os.FileInfo) error {
+	if fs.skip > 0 {
+		return nil
+	}
+	value, err := os.ReadFile(fs.path)
+	if err != nil {
+		return err
+	}
+	c.emit(ctx, h, fs, value, 0, info)
+	return nil
+}
+
+func (c *Consumer) emitLines(ctx context.Context, h ziggurat.Handler, fs *fileState, info os.FileInfo) error {
+	f, err := os.Open(fs.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	seen := 0
+	for lineNum := 1; ; lineNum++ {
+		line, err := r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if len(bytes.TrimSpace(line)) > 0 {
+			if seen >= fs.skip {
+				if ctx.Err() != nil {
+					return nil
+				}
+				c.emit(ctx, h, fs, line, lineNum, info)
+			}
+			seen++
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+func (c *Consumer) emit(ctx context.Context, h ziggurat.Handler, fs *fileState, value []byte, line int, info os.FileInfo) {
+	now := time.Now()
+	event := &ziggurat.Event{
+		Metadata: map[string]any{
+			KeyPath:    fs.path,
+			KeyName:    fs.name,
+			KeyModTime: info.ModTime(),
+		},
+		Value:             value,
+		Key:               []byte(fs.name),
+		RoutingPath:       fs.name,
+		ProducerTimestamp: info.ModTime(),
+		ReceivedTimestamp: now,
+		EventType:         EventType,
+	}
+	if c.Mode == ModeLines {
+		event.Metadata[KeyLine] = line
+	}
+
+	seq := fs.next()
+	c.pending.Add(1)
+	actx, ack := ziggurat.WithAck(ctx, func(err error) {
+		defer c.pending.Done()
+		kvs := map[string]any{"file": fs.name, "line": line}
+		c.Logger.Error("dirwatch event processing failed", err, kvs)
+		complete, cpErr := fs.ack(seq, err != nil)
+		if cpErr != nil {
+			c.Logger.Error("dirwatch error saving checkpoint", cpErr, kvs)
+		}
+		if complete {
+			c.complete(fs)
+		}
+	})
+	h.Handle(actx, event)
+	ack.Release()
+}
+
+// complete is called once every event emitted for the file is acknowledged
+// it moves a fully read file to the done or failed directory and removes its checkpoint
+func (c *Consumer) complete(fs *fileState) {
+	if fs.abandoned {
+		c.setActive(fs.name, false)
+		return
+	}
+	dir := c.DoneDir
+	if fs.cp.Failed {
+		dir = c.FailedDir
+	}
+	dest := uniquePath(dir, fs.name)
+	kvs := map[string]any{"file": fs.name, "dest": dest}
+	if err := os.Rename(fs.path, dest); err != nil {
+		c.Logger.Error("dirwatch error moving file", err, kvs)
+		c.setActive(fs.name, false)
+		return
+	}
+	if err := os.Remove(fs.checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.Logger.Error("dirwatch error removing checkpoint", err, kvs)
+	}
+	c.setActive(fs.name, false)
+	c.Logger.Info("dirwatch moved file", kvs)
+}
+
+// uniquePath appends a timestamp to the file name when a file with the same name was moved before
+func uniquePath(dir, name string) string {
+	dest := filepath.Join(dir, name)
+	if _, err := os.Stat(dest); errors.Is(err, os.ErrNotExist) {
+		return dest
+	}
+	ext := filepath.Ext(name)
+	return filepath.Join(dir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), time.Now().UnixNano(), ext))
+}
@@ -0,0 +1,220 @@
+package dirwatch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/ziggurattest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fileNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func assertFiles(t *testing.T, dir string, want ...string) {
+	t.Helper()
+	if want == nil {
+		want = []string{}
+	}
+	if diff := cmp.Diff(want, fileNames(t, dir)); diff != "" {
+		t.Errorf("unexpected files in %s (-want +got):\n%s", dir, diff)
+	}
+}
+
+// run consumes until the handler received n events and returns once the consumer is done
+func run(t *testing.T, c *Consumer, h ziggurat.Handler, r *ziggurattest.Recorder, n int) {
+	t.Helper()
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Consume(ctx, h)
+	}()
+	r.WaitFor(t, n, 5*time.Second)
+	// give the consumer time to move the files
+	time.Sleep(50 * time.Millisecond)
+	cfn()
+	if err := <-done; err != nil {
+		t.Fatalf("expected nil error got %v", err)
+	}
+}
+
+// saveCheckpoint saves the checkpoint of the file as of now
+func saveCheckpoint(t *testing.T, dir, name string, cp checkpoint) {
+	t.Helper()
+	info, err := os.Stat(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp.identify(info)
+	if err := cp.save(filepath.Join(dir, stateDirName, name+".json")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsumer_Files(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "b.csv", "bar")
+	writeFile(t, dir, "a.csv", "foo")
+	writeFile(t, dir, "ignored.txt", "ignored")
+	writeFile(t, dir, ".hidden.csv", "ignored")
+
+	r := ziggurattest.NewRecorder()
+	c := &Consumer{Dir: dir, Pattern: "*.csv", PollInterval: 10 * time.Millisecond}
+	run(t, c, r, r, 2)
+
+	r.AssertValues(t, "foo", "bar")
+	r.AssertRoutingPaths(t, "a.csv", "b.csv")
+	assertFiles(t, dir, ".hidden.csv", "ignored.txt")
+	assertFiles(t, filepath.Join(dir, "done"), "a.csv", "b.csv")
+	assertFiles(t, filepath.Join(dir, stateDirName))
+}
+
+func TestConsumer_LinesWithFailure(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "ok.log", "a\n\nb\n")
+	writeFile(t, dir, "bad.log", "c\nfail\nd")
+
+	r := ziggurattest.NewRecorder()
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		r.Handle(ctx, event)
+		if string(event.Value) == "fail" {
+			ack, _ := ziggurat.DeferAck(ctx)
+			ack(errors.New("processing failed"))
+		}
+	})
+	c := &Consumer{Dir: dir, Mode: ModeLines, PollInterval: 10 * time.Millisecond}
+	run(t, c, h, r, 5)
+
+	r.AssertValues(t, "c", "fail", "d", "a", "b")
+	if line := r.Events()[4].Metadata[KeyLine]; line != 3 {
+		t.Errorf("expected line 3 got %v", line)
+	}
+	assertFiles(t, filepath.Join(dir, "done"), "ok.log")
+	assertFiles(t, filepath.Join(dir, "failed"), "bad.log")
+}
+
+func TestConsumer_WaitsForDeferredAcks(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.log", "a\nb")
+
+	r := ziggurattest.NewRecorder()
+	acks := make(chan ziggurat.AckFunc, 2)
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		ack, _ := ziggurat.DeferAck(ctx)
+		acks <- ack
+		r.Handle(ctx, event)
+	})
+	c := &Consumer{Dir: dir, Mode: ModeLines, PollInterval: 10 * time.Millisecond}
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Consume(ctx, h)
+	}()
+	r.WaitFor(t, 2, 5*time.Second)
+	time.Sleep(50 * time.Millisecond)
+	assertFiles(t, dir, "a.log")
+
+	// acknowledged out of order
+	first, second := <-acks, <-acks
+	second(nil)
+	time.Sleep(20 * time.Millisecond)
+	assertFiles(t, dir, "a.log")
+	first(nil)
+	cfn()
+	<-done
+	assertFiles(t, filepath.Join(dir, "done"), "a.log")
+	r.AssertCount(t, 2)
+}
+
+func TestConsumer_ResumesFromCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, stateDirName)
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// a.log was partially processed and b.log was fully processed before a crash
+	writeFile(t, dir, "a.log", "a\nb\nc\nd")
+	writeFile(t, dir, "b.log", "e\nf")
+	saveCheckpoint(t, dir, "a.log", checkpoint{Acked: 2})
+	saveCheckpoint(t, dir, "b.log", checkpoint{Acked: 2, Failed: true})
+
+	r := ziggurattest.NewRecorder()
+	c := &Consumer{Dir: dir, Mode: ModeLines, PollInterval: 10 * time.Millisecond}
+	run(t, c, r, r, 2)
+
+	r.AssertValues(t, "c", "d")
+	assertFiles(t, filepath.Join(dir, "done"), "a.log")
+	assertFiles(t, filepath.Join(dir, "failed"), "b.log")
+	assertFiles(t, stateDir)
+}
+
+func TestConsumer_DiscardsCheckpointOfAnotherFile(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, stateDirName)
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// a.log was moved before its checkpoint was removed and a new a.log was dropped
+	writeFile(t, dir, "a.log", "a\nb\nc")
+	saveCheckpoint(t, dir, "a.log", checkpoint{Acked: 2})
+	if err := os.Rename(filepath.Join(dir, "a.log"), filepath.Join(dir, "moved.log")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "a.log", "d\ne\nf")
+
+	r := ziggurattest.NewRecorder()
+	c := &Consumer{Dir: dir, Pattern: "a.log", Mode: ModeLines, PollInterval: 10 * time.Millisecond}
+	run(t, c, r, r, 3)
+
+	r.AssertValues(t, "d", "e", "f")
+	assertFiles(t, filepath.Join(dir, "done"), "a.log")
+	assertFiles(t, stateDir)
+}
+
+func TestConsumer_DuplicateNames(t *testing.T) {
+	dir := t.TempDir()
+	doneDir := filepath.Join(dir, "done")
+	if err := os.MkdirAll(doneDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, doneDir, "a.csv", "old")
+	writeFile(t, dir, "a.csv", "new")
+
+	r := ziggurattest.NewRecorder()
+	c := &Consumer{Dir: dir, PollInterval: 10 * time.Millisecond}
+	run(t, c, r, r, 1)
+
+	names := fileNames(t, doneDir)
+	if len(names) != 2 || !strings.HasPrefix(names[0], "a-") || names[1] != "a.csv" {
+		t.Errorf("expected the moved file to be renamed got %v", names)
+	}
+}
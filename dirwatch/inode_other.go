@@ -0,0 +1,10 @@
+//go:build !unix
+
+package dirwatch
+
+import "os"
+
+// inode is not available, the checkpoint is identified by the size and the modification time of the file
+func inode(os.FileInfo) uint64 {
+	return 0
+}
@@ -1,6 +1,9 @@
 package ziggurat
 
-import "time"
+import (
+	"maps"
+	"time"
+)
 
 // Event is a generic event
 // ReceivedTimestamp holds the timestamp of the message when it was received
@@ -18,3 +21,27 @@ type Event struct {
 	ReceivedTimestamp time.Time `json:"received_timestamp"`
 	EventType         string    `json:"event_type"`
 }
+
+// Clone returns a deep copy of the event
+// message consumers may reuse the event once it is acknowledged,
+// handlers which retain an event beyond that must clone it
+func (e *Event) Clone() *Event {
+	c := *e
+	if e.Metadata != nil {
+		c.Metadata = make(map[string]any, len(e.Metadata))
+		for k, v := range e.Metadata {
+			// header maps are reused along with the event by the message consumers
+			if headers, ok := v.(map[string]string); ok {
+				v = maps.Clone(headers)
+			}
+			c.Metadata[k] = v
+		}
+	}
+	if e.Value != nil {
+		c.Value = append(make([]byte, 0, len(e.Value)), e.Value...)
+	}
+	if e.Key != nil {
+		c.Key = append(make([]byte, 0, len(e.Key)), e.Key...)
+	}
+	return &c
+}
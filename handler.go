@@ -17,3 +17,19 @@ func (h HandlerFunc) Handle(ctx context.Context, event *Event) {
 type Handler interface {
 	Handle(ctx context.Context, event *Event)
 }
+
+// BatchHandlerFunc serves as an adapter to convert
+// regular functions of the signature f(context.Context,[]*ziggurat.Event) error
+// to implement the ziggurat.BatchHandler interface
+type BatchHandlerFunc func(ctx context.Context, events []*Event) error
+
+func (h BatchHandlerFunc) HandleBatch(ctx context.Context, events []*Event) error {
+	return h(ctx, events)
+}
+
+// BatchHandler is an interface which can be implemented
+// to handle a batch of events at once, a non nil error
+// fails the whole batch
+type BatchHandler interface {
+	HandleBatch(ctx context.Context, events []*Event) error
+}
@@ -0,0 +1,255 @@
+package httpconsumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+)
+
+const (
+	EventType = "http"
+	// ContentTypeEvent marks the body as a JSON encoded ziggurat.Event or a JSON array of them
+	ContentTypeEvent = "application/vnd.ziggurat.event+json"
+	// KeyHeader sets the key of raw events
+	KeyHeader = "X-Ziggurat-Key"
+	// KeyHeaders holds the request headers of raw events as a map[string]string
+	KeyHeaders = "http-headers"
+)
+
+const defaultMaxBodyBytes = 10 * 1024 * 1024
+
+type ingestResp struct {
+	Count int    `json:"count"`
+	Error string `json:"error,omitempty"`
+}
+
+// Consumer is a ziggurat.MessageConsumer which accepts events over HTTP
+//
+// A POST request with the content type ContentTypeEvent carries a single event or a batch of events
+// in the ziggurat.Event JSON shape, any other content type is treated as the value of a single raw event.
+// The request headers of raw events are stored in the metadata under KeyHeaders.
+// The URL path without the PathPrefix and the leading slash is used as the RoutingPath
+// of raw events and of JSON events which do not have one.
+//
+// The response is sent once the handler is done with every event of the request
+// including deferred acknowledgements, a failed acknowledgement results in a 500.
+type Consumer struct {
+	// Addr is the address to listen on, it defaults to localhost:8080
+	Addr string
+	// PathPrefix is stripped from the URL path before it is used as the RoutingPath
+	PathPrefix string
+	// MaxBodyBytes limits the size of the request body, it defaults to 10MB
+	MaxBodyBytes int64
+	// ShutdownTimeout is the time given to in-flight requests once the context is done, it defaults to 5 seconds
+	ShutdownTimeout time.Duration
+	Logger          ziggurat.StructuredLogger
+	mu              sync.Mutex
+	listener        net.Listener
+	ready           chan struct{}
+	once            sync.Once
+}
+
+func (c *Consumer) init() {
+	c.once.Do(func() {
+		if c.Logger == nil {
+			c.Logger = logger.NOOP
+		}
+		if c.Addr == "" {
+			c.Addr = "localhost:8080"
+		}
+		if c.MaxBodyBytes <= 0 {
+			c.MaxBodyBytes = defaultMaxBodyBytes
+		}
+		if c.ShutdownTimeout <= 0 {
+			c.ShutdownTimeout = 5 * time.Second
+		}
+		c.ready = make(chan struct{})
+	})
+}
+
+// ListenAddr blocks until the server is listening and returns its address
+// it is useful when Addr uses port 0
+func (c *Consumer) ListenAddr(ctx context.Context) (string, error) {
+	c.init()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-c.readyCh():
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.listener.Addr().String(), nil
+	}
+}
+
+func (c *Consumer) readyCh() chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ready
+}
+
+// Consume starts the HTTP server and blocks until ctx is done, it can be called again once it returns
+func (c *Consumer) Consume(ctx context.Context, h ziggurat.Handler) error {
+	c.init()
+	l, err := net.Listen("tcp", c.Addr)
+	if err != nil {
+		return fmt.Errorf("http consumer: %w", err)
+	}
+	c.mu.Lock()
+	c.listener = l
+	select {
+	case <-c.ready:
+	default:
+		close(c.ready)
+	}
+	c.mu.Unlock()
+	defer func() {
+		// ListenAddr waits for the next call of Consume
+		c.mu.Lock()
+		c.ready = make(chan struct{})
+		c.mu.Unlock()
+	}()
+
+	server := &http.Server{Handler: c.Handler(ctx, h)}
+	errCh := make(chan error, 1)
+	go func() {
+		c.Logger.Info("http consumer listening", map[string]any{"addr": l.Addr().String()})
+		errCh <- server.Serve(l)
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cfn := context.WithTimeout(context.WithoutCancel(ctx), c.ShutdownTimeout)
+		defer cfn()
+		return server.Shutdown(shutdownCtx)
+	case err := <-errCh:
+		return err
+	}
+}
+
+// Handler returns the http.Handler used by Consume, it can be mounted on an existing server
+// ctx is passed on to the ziggurat.Handler
+func (c *Consumer) Handler(ctx context.Context, h ziggurat.Handler) http.Handler {
+	c.init()
+	f := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeResp(w, http.StatusMethodNotAllowed, ingestResp{Error: "method not allowed"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, c.MaxBodyBytes))
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.As(err, new(*http.MaxBytesError)) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeResp(w, status, ingestResp{Error: err.Error()})
+			return
+		}
+
+		path := strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, c.PathPrefix), "/")
+		events, err := decodeEvents(req, body, path)
+		if err != nil {
+			writeResp(w, http.StatusBadRequest, ingestResp{Error: err.Error()})
+			return
+		}
+
+		if err := c.handle(ctx, h, events); err != nil {
+			writeResp(w, http.StatusInternalServerError, ingestResp{Count: len(events), Error: err.Error()})
+			return
+		}
+		writeResp(w, http.StatusOK, ingestResp{Count: len(events)})
+	}
+	return http.HandlerFunc(f)
+}
+
+// handle hands the events to the handler in order and waits for all of them to be acknowledged
+func (c *Consumer) handle(ctx context.Context, h ziggurat.Handler, events []*ziggurat.Event) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for _, e := range events {
+		wg.Add(1)
+		actx, ack := ziggurat.WithAck(ctx, func(err error) {
+			defer wg.Done()
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		})
+		h.Handle(actx, e)
+		ack.Release()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func decodeEvents(req *http.Request, body []byte, path string) ([]*ziggurat.Event, error) {
+	now := time.Now()
+	// the media type is compared without its parameters such as the charset
+	if mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err != nil || mediaType != ContentTypeEvent {
+		headers := make(map[string]string, len(req.Header))
+		for k := range req.Header {
+			headers[k] = req.Header.Get(k)
+		}
+		return []*ziggurat.Event{{
+			Metadata:          map[string]any{KeyHeaders: headers},
+			Key:               []byte(req.Header.Get(KeyHeader)),
+			Value:             body,
+			RoutingPath:       path,
+			ProducerTimestamp: now,
+			ReceivedTimestamp: now,
+			EventType:         EventType,
+		}}, nil
+	}
+
+	var events []*ziggurat.Event
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &events); err != nil {
+			return nil, fmt.Errorf("invalid event batch: %w", err)
+		}
+	} else {
+		var e ziggurat.Event
+		if err := json.Unmarshal(trimmed, &e); err != nil {
+			return nil, fmt.Errorf("invalid event: %w", err)
+		}
+		events = append(events, &e)
+	}
+
+	for i, e := range events {
+		if e == nil {
+			return nil, fmt.Errorf("invalid event at index %d: null", i)
+		}
+		if e.RoutingPath == "" {
+			e.RoutingPath = path
+		}
+		if e.EventType == "" {
+			e.EventType = EventType
+		}
+		if e.Metadata == nil {
+			e.Metadata = map[string]any{}
+		}
+		e.ReceivedTimestamp = now
+	}
+	return events, nil
+}
+
+func writeResp(w http.ResponseWriter, status int, resp ingestResp) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,217 @@
+package httpconsumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/ziggurattest"
+)
+
+func post(t *testing.T, url, contentType, body string, headers map[string]string) (int, ingestResp) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var ir ingestResp
+	if err := json.NewDecoder(resp.Body).Decode(&ir); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, ir
+}
+
+func TestConsumer_Raw(t *testing.T) {
+	r := ziggurattest.NewRecorder()
+	c := Consumer{PathPrefix: "/ingest"}
+	srv := httptest.NewServer(c.Handler(context.Background(), r))
+	defer srv.Close()
+
+	status, _ := post(t, srv.URL+"/ingest/orders/created", "text/plain", "foo",
+		map[string]string{KeyHeader: "k1", "X-Request-Id": "abc"})
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200 got %d", status)
+	}
+
+	r.AssertValues(t, "foo")
+	r.AssertRoutingPaths(t, "orders/created")
+	e := r.Events()[0]
+	if string(e.Key) != "k1" {
+		t.Errorf("expected key k1 got %q", e.Key)
+	}
+	if e.EventType != EventType {
+		t.Errorf("expected event type %q got %q", EventType, e.EventType)
+	}
+	headers := e.Metadata[KeyHeaders].(map[string]string)
+	if headers["X-Request-Id"] != "abc" {
+		t.Errorf("expected header X-Request-Id to be abc got %q", headers["X-Request-Id"])
+	}
+}
+
+func TestConsumer_Envelope(t *testing.T) {
+	r := ziggurattest.NewRecorder()
+	c := Consumer{}
+	srv := httptest.NewServer(c.Handler(context.Background(), r))
+	defer srv.Close()
+
+	single := `{"value":"Zm9v","routing_path":"custom"}`
+	status, resp := post(t, srv.URL+"/events", ContentTypeEvent, single, nil)
+	if status != http.StatusOK || resp.Count != 1 {
+		t.Fatalf("expected status 200 with count 1 got %d %+v", status, resp)
+	}
+
+	batch := `[{"value":"YmFy"},{"value":"YmF6"}]`
+	status, resp = post(t, srv.URL+"/events", ContentTypeEvent, batch, nil)
+	if status != http.StatusOK || resp.Count != 2 {
+		t.Fatalf("expected status 200 with count 2 got %d %+v", status, resp)
+	}
+
+	status, resp = post(t, srv.URL+"/events", ContentTypeEvent+"; charset=utf-8", `{"value":"cXV4"}`, nil)
+	if status != http.StatusOK || resp.Count != 1 {
+		t.Fatalf("expected status 200 with count 1 got %d %+v", status, resp)
+	}
+
+	r.AssertValues(t, "foo", "bar", "baz", "qux")
+	r.AssertRoutingPaths(t, "custom", "events", "events", "events")
+
+	status, _ = post(t, srv.URL+"/events", ContentTypeEvent, "not json", nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("expected status 400 got %d", status)
+	}
+}
+
+func TestConsumer_WaitsForDeferredAck(t *testing.T) {
+	var acked atomic.Bool
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		ack, _ := ziggurat.DeferAck(ctx)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			acked.Store(true)
+			if string(event.Value) == "fail" {
+				ack(errors.New("processing failed"))
+				return
+			}
+			ack(nil)
+		}()
+	})
+	c := Consumer{}
+	srv := httptest.NewServer(c.Handler(context.Background(), h))
+	defer srv.Close()
+
+	status, _ := post(t, srv.URL+"/foo", "text/plain", "ok", nil)
+	if status != http.StatusOK {
+		t.Errorf("expected status 200 got %d", status)
+	}
+	if !acked.Load() {
+		t.Errorf("expected the response to be sent after the ack")
+	}
+
+	status, resp := post(t, srv.URL+"/foo", "text/plain", "fail", nil)
+	if status != http.StatusInternalServerError {
+		t.Errorf("expected status 500 got %d", status)
+	}
+	if resp.Error != "processing failed" {
+		t.Errorf("expected error %q got %q", "processing failed", resp.Error)
+	}
+}
+
+func TestConsumer_Consume(t *testing.T) {
+	ctx, cfn := context.WithCancel(context.Background())
+	r := ziggurattest.NewRecorder()
+	c := Consumer{Addr: "localhost:0"}
+	done := make(chan error)
+	go func() {
+		done <- c.Consume(ctx, r)
+	}()
+
+	addr, err := c.ListenAddr(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, _ := post(t, "http://"+addr+"/foo", "text/plain", "foo", nil)
+	if status != http.StatusOK {
+		t.Errorf("expected status 200 got %d", status)
+	}
+
+	resp, err := http.Get("http://" + addr + "/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405 got %d", resp.StatusCode)
+	}
+
+	cfn()
+	if err := <-done; err != nil {
+		t.Errorf("expected nil error got %v", err)
+	}
+	r.AssertCount(t, 1)
+}
+
+func TestConsumer_ConsumeAgain(t *testing.T) {
+	r := ziggurattest.NewRecorder()
+	c := Consumer{Addr: "localhost:0"}
+	for i := 0; i < 2; i++ {
+		ctx, cfn := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- c.Consume(ctx, r)
+		}()
+		// the consumer is restarted once it returns
+		addr, err := c.ListenAddr(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status, _ := post(t, "http://"+addr+"/foo", "text/plain", "foo", nil); status != http.StatusOK {
+			t.Errorf("expected status 200 got %d", status)
+		}
+		cfn()
+		if err := <-done; err != nil {
+			t.Errorf("expected nil error got %v", err)
+		}
+	}
+	r.AssertCount(t, 2)
+}
+
+type failingBody struct{}
+
+func (failingBody) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestConsumer_BodyErrors(t *testing.T) {
+	c := Consumer{MaxBodyBytes: 4}
+	h := c.Handler(context.Background(), ziggurattest.NewRecorder())
+	cases := map[string]struct {
+		body   io.Reader
+		status int
+	}{
+		"body too large":    {body: strings.NewReader("foobar"), status: http.StatusRequestEntityTooLarge},
+		"body read failure": {body: failingBody{}, status: http.StatusBadRequest},
+	}
+	for name, tc := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", tc.body))
+		if rec.Code != tc.status {
+			t.Errorf("%s: expected status %d got %d", name, tc.status, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Package configfile decodes the configuration files of the consumers
+package configfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Decode decodes a YAML or JSON file into v, JSON files are decoded as YAML which is a superset of JSON,
+// unknown fields are errors so that typos are not silently ignored
+func Decode(path string, v any) error {
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml", ".json":
+	default:
+		return fmt.Errorf("unsupported config file extension %q, expected .yaml, .yml or .json", ext)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("config file %s is empty", path)
+		}
+		return fmt.Errorf("error decoding config file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	var v struct {
+		Name string `yaml:"name"`
+	}
+
+	cases := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "yaml", path: write("a.yml", "name: foo\n")},
+		{name: "json", path: write("a.json", `{"name": "foo"}`)},
+		{name: "empty file", path: write("empty.yaml", ""), wantErr: "is empty"},
+		{name: "unknown field", path: write("b.yaml", "nmae: foo\n"), wantErr: "field nmae not found"},
+		{name: "unsupported extension", path: write("a.ini", "name=foo"), wantErr: "unsupported config file extension"},
+		{name: "missing file", path: filepath.Join(dir, "missing.yaml"), wantErr: "no such file"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := Decode(c.path, &v)
+			switch {
+			case c.wantErr == "" && err != nil:
+				t.Errorf("expected no error got %v", err)
+			case c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)):
+				t.Errorf("expected an error containing %q got %v", c.wantErr, err)
+			case c.wantErr == "" && v.Name != "foo":
+				t.Errorf("expected the name foo got %q", v.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package jsonl
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+)
+
+const (
+	EventType = "jsonl"
+	// FormatEvent expects every line to be a JSON encoded ziggurat.Event
+	FormatEvent = "event"
+	// FormatRaw wraps every line as the value of a new event
+	FormatRaw = "raw"
+)
+
+var ErrNoFiles = errors.New("jsonl: no files matched the given paths")
+
+// PathData is passed to the PathTemplate to render the routing path of an event
+type PathData struct {
+	File  string
+	Line  int
+	Event *ziggurat.Event
+}
+
+// Consumer replays newline delimited events from files
+// lines which cannot be decoded are logged and skipped
+type Consumer struct {
+	// Paths is a list of files or glob patterns, files are read in the given order
+	// the files matched by a single glob are read in lexical order, files ending with .gz are decompressed
+	Paths []string
+	// Format is either FormatEvent or FormatRaw, it defaults to FormatEvent
+	Format string
+	// RatePerSecond limits the number of events handed to the handler every second, 0 disables the limit
+	RatePerSecond int
+	// Loop replays the files from the start once all of them are read, until the context is done
+	Loop bool
+	// PathTemplate is a text/template which renders the routing path of every event
+	// for example "replay/{{.File}}" or "{{.Event.RoutingPath}}"
+	// the routing path of JSON events is left as is and raw events use the file name when it is empty
+	PathTemplate string
+	Logger       ziggurat.StructuredLogger
+	tmpl         *template.Template
+	pending      sync.WaitGroup
+}
+
+func (c *Consumer) init() error {
+	if c.Logger == nil {
+		c.Logger = logger.NOOP
+	}
+	if c.Format == "" {
+		c.Format = FormatEvent
+	}
+	if c.Format != FormatEvent && c.Format != FormatRaw {
+		return fmt.Errorf("jsonl: unknown format %q", c.Format)
+	}
+	if c.PathTemplate != "" {
+		tmpl, err := template.New("path").Parse(c.PathTemplate)
+		if err != nil {
+			return fmt.Errorf("jsonl: invalid path template: %w", err)
+		}
+		c.tmpl = tmpl
+	}
+	return nil
+}
+
+func expandPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("jsonl: invalid pattern %q: %w", p, err)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, ErrNoFiles
+	}
+	return files, nil
+}
+
+// Consume reads the files and hands every event to the handler
+// it returns nil once all the files are read or when ctx is done
+func (c *Consumer) Consume(ctx context.Context, h ziggurat.Handler) error {
+	if err := c.init(); err != nil {
+		return err
+	}
+	files, err := expandPaths(c.Paths)
+	if err != nil {
+		return err
+	}
+	defer c.pending.Wait()
+
+	var tick <-chan time.Time
+	if c.RatePerSecond > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(c.RatePerSecond))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		for _, f := range files {
+			if err := c.replayFile(ctx, f, tick, h); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+		if !c.Loop || ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (c *Consumer) replayFile(ctx context.Context, path string, tick <-chan time.Time, h ziggurat.Handler) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("jsonl: %w", err)
+	}
+	defer f.Close()
+
+	var src io.Reader = f
+	base := filepath.Base(path)
+	// gzip compressed files like the ones written by the capture middleware
+	if strings.HasSuffix(base, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("jsonl: error reading %s: %w", path, err)
+		}
+		defer gz.Close()
+		src = gz
+		base = strings.TrimSuffix(base, ".gz")
+	}
+
+	c.Logger.Info("jsonl replaying file", map[string]any{"file": path})
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	r := bufio.NewReader(src)
+	for lineNum := 1; ; lineNum++ {
+		line, readErr := r.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return fmt.Errorf("jsonl: error reading %s: %w", path, readErr)
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			if tick != nil {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-tick:
+				}
+			} else if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.handleLine(ctx, h, line, PathData{File: name, Line: lineNum})
+		}
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+	}
+}
+
+func (c *Consumer) handleLine(ctx context.Context, h ziggurat.Handler, line []byte, pd PathData) {
+	kvs := map[string]any{"file": pd.File, "line": pd.Line}
+	event, err := c.decode(line, pd.File)
+	if err != nil {
+		c.Logger.Error("jsonl could not decode line", err, kvs)
+		return
+	}
+	pd.Event = event
+	if c.tmpl != nil {
+		var sb strings.Builder
+		if err := c.tmpl.Execute(&sb, pd); err != nil {
+			c.Logger.Error("jsonl could not render routing path", err, kvs)
+			return
+		}
+		event.RoutingPath = sb.String()
+	}
+
+	c.pending.Add(1)
+	actx, ack := ziggurat.WithAck(ctx, func(err error) {
+		defer c.pending.Done()
+		c.Logger.Error("jsonl event processing failed", err, kvs)
+	})
+	h.Handle(actx, event)
+	ack.Release()
+}
+
+func (c *Consumer) decode(line []byte, file string) (*ziggurat.Event, error) {
+	now := time.Now()
+	if c.Format == FormatRaw {
+		return &ziggurat.Event{
+			Metadata:          map[string]any{},
+			Key:               []byte{},
+			Value:             append([]byte{}, line...),
+			RoutingPath:       file,
+			ProducerTimestamp: now,
+			ReceivedTimestamp: now,
+			EventType:         EventType,
+		}, nil
+	}
+	var e ziggurat.Event
+	if err := json.Unmarshal(line, &e); err != nil {
+		return nil, err
+	}
+	if e.Metadata == nil {
+		e.Metadata = map[string]any{}
+	}
+	e.ReceivedTimestamp = now
+	return &e, nil
+}
@@ -0,0 +1,105 @@
+package jsonl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/ziggurattest"
+)
+
+func writeFile(t *testing.T, dir, name string, lines ...string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func eventLine(t *testing.T, e ziggurat.Event) string {
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestConsumer_Events(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "b.jsonl",
+		eventLine(t, ziggurat.Event{Value: []byte("b1"), RoutingPath: "foo.id/orders/1", EventType: "kafka"}),
+	)
+	writeFile(t, dir, "a.jsonl",
+		eventLine(t, ziggurat.Event{Value: []byte("a1"), RoutingPath: "foo.id/orders/0", EventType: "kafka"}),
+		"",
+		"not json",
+		eventLine(t, ziggurat.Event{Value: []byte("a2"), RoutingPath: "foo.id/orders/0", EventType: "kafka"}),
+	)
+
+	r := ziggurattest.NewRecorder()
+	c := Consumer{Paths: []string{filepath.Join(dir, "*.jsonl")}}
+	if err := c.Consume(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	r.AssertValues(t, "a1", "a2", "b1")
+	r.AssertRoutingPaths(t, "foo.id/orders/0", "foo.id/orders/0", "foo.id/orders/1")
+}
+
+func TestConsumer_RawWithTemplate(t *testing.T) {
+	dir := t.TempDir()
+	p := writeFile(t, dir, "webhooks.log", "foo", "bar")
+
+	r := ziggurattest.NewRecorder()
+	c := Consumer{
+		Paths:        []string{p},
+		Format:       FormatRaw,
+		PathTemplate: "replay/{{.File}}/{{.Line}}",
+	}
+	if err := c.Consume(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	r.AssertValues(t, "foo", "bar")
+	r.AssertRoutingPaths(t, "replay/webhooks/1", "replay/webhooks/2")
+	if r.Events()[0].EventType != EventType {
+		t.Errorf("expected event type %s got %s", EventType, r.Events()[0].EventType)
+	}
+}
+
+func TestConsumer_RateAndLoop(t *testing.T) {
+	dir := t.TempDir()
+	p := writeFile(t, dir, "raw.txt", "1", "2")
+
+	r := ziggurattest.NewRecorder()
+	c := Consumer{Paths: []string{p}, Format: FormatRaw, Loop: true, RatePerSecond: 50}
+	ctx, cfn := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cfn()
+	start := time.Now()
+	if err := c.Consume(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 250*time.Millisecond {
+		t.Error("expected loop mode to run until the context is done")
+	}
+	// 50 events per second for 250ms
+	if n := r.Len(); n < 6 || n > 14 {
+		t.Errorf("expected around 12 events got %d", n)
+	}
+}
+
+func TestConsumer_Errors(t *testing.T) {
+	c := Consumer{Paths: []string{filepath.Join(t.TempDir(), "*.jsonl")}}
+	if err := c.Consume(context.Background(), ziggurattest.NewRecorder()); !errors.Is(err, ErrNoFiles) {
+		t.Errorf("expected %v got %v", ErrNoFiles, err)
+	}
+	c = Consumer{Paths: []string{"foo"}, Format: "xml"}
+	if err := c.Consume(context.Background(), ziggurattest.NewRecorder()); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -5,13 +5,30 @@ import (
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 )
 
+// ConsumerHandle returns the consumer of the first worker of the group
+//
+// Deprecated: every worker owns a consumer, use ConsumerHandles
 func ConsumerHandle(group *ConsumerGroup) (*kafka.Consumer, error) {
-	if group.c == nil {
+	handles, err := ConsumerHandles(group)
+	if err != nil {
+		return nil, err
+	}
+	return handles[0], nil
+}
+
+// ConsumerHandles returns the consumers of the workers of the group
+func ConsumerHandles(group *ConsumerGroup) ([]*kafka.Consumer, error) {
+	workers := group.spawned()
+	if len(workers) == 0 {
 		return nil, errors.New("consumer handle not initialized")
 	}
-	kc, ok := group.c.(*kafka.Consumer)
-	if !ok {
-		return nil, errors.New("could not assert to *kafka.Consumer")
+	handles := make([]*kafka.Consumer, 0, len(workers))
+	for _, w := range workers {
+		kc, ok := w.consumer.(*kafka.Consumer)
+		if !ok {
+			return nil, errors.New("could not assert to *kafka.Consumer")
+		}
+		handles = append(handles, kc)
 	}
-	return kc, nil
+	return handles, nil
 }
@@ -1,19 +1,164 @@
 package kafka
 
-import "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
 
 type ConsumerConfig struct {
-	BootstrapServers      string
-	DebugLevel            string
-	GroupID               string
-	Topics                []string
-	AutoCommitInterval    int
-	ConsumerCount         int
-	PollTimeout           int
-	AutoOffsetReset       string
-	PartitionAssignment   string
-	MaxPollIntervalMS     int
-	AllowAutoCreateTopics bool
+	BootstrapServers      string   `yaml:"bootstrap_servers"`
+	DebugLevel            string   `yaml:"debug_level"`
+	GroupID               string   `yaml:"group_id"`
+	Topics                []string `yaml:"topics"`
+	AutoCommitInterval    int      `yaml:"auto_commit_interval"`
+	ConsumerCount         int      `yaml:"consumer_count"`
+	PollTimeout           int      `yaml:"poll_timeout"`
+	AutoOffsetReset       string   `yaml:"auto_offset_reset"`
+	PartitionAssignment   string   `yaml:"partition_assignment"`
+	MaxPollIntervalMS     int      `yaml:"max_poll_interval_ms"`
+	AllowAutoCreateTopics bool     `yaml:"allow_auto_create_topics"`
+	// TransactionalID enables the transactional mode, every worker processes a message in a transaction which
+	// commits the events sent using TransactionFrom along with the offset of the message.
+	// The transactional.id of a worker's producer is <TransactionalID>-<worker index>,
+	// TransactionalID must be unique per instance of the application
+	TransactionalID string `yaml:"transactional_id"`
+	// InFlightHighWaterMark pauses the partitions of a worker once the number of its unacknowledged events reaches it,
+	// the worker keeps polling while its partitions are paused. Zero disables the backpressure
+	InFlightHighWaterMark int `yaml:"in_flight_high_water_mark"`
+	// InFlightLowWaterMark resumes the partitions once the unacknowledged events drop to it,
+	// it defaults to half of InFlightHighWaterMark
+	InFlightLowWaterMark int `yaml:"in_flight_low_water_mark"`
+	// MaxRedeliveries stops a worker once an event fails to be processed more than MaxRedeliveries times in a row,
+	// the partition of a failed event is paused and rewound to the event after a backoff. It defaults to 10,
+	// -1 redelivers the event until it succeeds
+	MaxRedeliveries int `yaml:"max_redeliveries"`
+	// RedeliveryBackoffMS is the delay before a failed event is redelivered, it doubles every time the event fails
+	// up to a minute. It defaults to 1000
+	RedeliveryBackoffMS int `yaml:"redelivery_backoff_ms"`
+	// StatisticsIntervalMS enables the librdkafka statistics which are passed to ConsumerGroup.OnStats
+	StatisticsIntervalMS int `yaml:"statistics_interval_ms"`
+	// SASL and SSL set the security.protocol to SASL_PLAINTEXT, SSL or SASL_SSL
+	// the transactional producers use the same settings
+	SASL *SASLConfig `yaml:"sasl"`
+	SSL  *SSLConfig  `yaml:"ssl"`
+	// Overrides are librdkafka consumer properties which are applied last,
+	// they take precedence over the framework defaults and the typed settings above.
+	// The properties the consumer group relies on cannot be overridden, the transactional producers use the same Overrides
+	Overrides kafka.ConfigMap `yaml:"overrides"`
+}
+
+// reservedProperties cannot be overridden as the consumer group relies on them
+var reservedProperties = map[string]string{
+	"group.id":                        "use GroupID, it is part of the routing path",
+	"bootstrap.servers":               "use BootstrapServers",
+	"enable.auto.offset.store":        "offsets are stored once the events are acknowledged",
+	"go.logs.channel.enable":          "the logs are passed to the Logger",
+	"go.events.channel.enable":        "the workers poll for events",
+	"go.application.rebalance.enable": "the rebalances are handled by the consumer group",
+}
+
+// transactionalProperties cannot be overridden in the transactional mode
+var transactionalProperties = map[string]string{
+	"enable.auto.commit": "offsets are committed by the transactions",
+	"isolation.level":    "uncommitted events are never consumed in the transactional mode",
+	"transactional.id":   "use TransactionalID, the producer of every worker has its own id",
+}
+
+// Validate returns an error listing every invalid setting of the config
+func (c ConsumerConfig) Validate() error {
+	var errs []error
+	if c.BootstrapServers == "" {
+		errs = append(errs, errors.New("kafka: BootstrapServers is required"))
+	}
+	if c.GroupID == "" {
+		errs = append(errs, errors.New("kafka: GroupID is required"))
+	}
+	if len(c.Topics) == 0 {
+		errs = append(errs, errors.New("kafka: Topics must not be empty"))
+	}
+	for i, topic := range c.Topics {
+		if topic == "" {
+			errs = append(errs, fmt.Errorf("kafka: Topics[%d] is empty", i))
+		}
+	}
+	// a zero ConsumerCount starts a single consumer
+	if c.ConsumerCount < 0 {
+		errs = append(errs, fmt.Errorf("kafka: ConsumerCount must not be negative, got %d", c.ConsumerCount))
+	}
+	if c.PollTimeout < -1 {
+		errs = append(errs, fmt.Errorf("kafka: PollTimeout must be -1 or more, got %d", c.PollTimeout))
+	}
+	if c.MaxRedeliveries < -1 {
+		errs = append(errs, fmt.Errorf("kafka: MaxRedeliveries must be -1 or more, got %d", c.MaxRedeliveries))
+	}
+	switch c.AutoOffsetReset {
+	case "", "earliest", "latest", "smallest", "largest", "beginning", "end", "error":
+	default:
+		errs = append(errs, fmt.Errorf("kafka: unknown AutoOffsetReset %q", c.AutoOffsetReset))
+	}
+	nonNegative := []struct {
+		name  string
+		value int
+	}{
+		{"AutoCommitInterval", c.AutoCommitInterval},
+		{"MaxPollIntervalMS", c.MaxPollIntervalMS},
+		{"InFlightHighWaterMark", c.InFlightHighWaterMark},
+		{"InFlightLowWaterMark", c.InFlightLowWaterMark},
+		{"RedeliveryBackoffMS", c.RedeliveryBackoffMS},
+		{"StatisticsIntervalMS", c.StatisticsIntervalMS},
+	}
+	for _, f := range nonNegative {
+		if f.value < 0 {
+			errs = append(errs, fmt.Errorf("kafka: %s must not be negative, got %d", f.name, f.value))
+		}
+	}
+	errs = append(errs, validateSecurity(c.SASL, c.SSL), c.validateOverrides())
+	return errors.Join(errs...)
+}
+
+func (c ConsumerConfig) validateOverrides() error {
+	var errs []error
+	keys := make([]string, 0, len(c.Overrides))
+	for k := range c.Overrides {
+		keys = append(keys, k)
+	}
+	// sorted so that the error is stable
+	sort.Strings(keys)
+	for _, k := range keys {
+		if reason, ok := reservedProperties[k]; ok {
+			errs = append(errs, fmt.Errorf("kafka: %q cannot be overridden, %s", k, reason))
+			continue
+		}
+		if reason, ok := transactionalProperties[k]; ok && c.TransactionalID != "" {
+			errs = append(errs, fmt.Errorf("kafka: %q cannot be overridden, %s", k, reason))
+			continue
+		}
+		switch c.Overrides[k].(type) {
+		case string, bool, int, fmt.Stringer:
+		default:
+			errs = append(errs, fmt.Errorf("kafka: override %q has an unsupported value type %T", k, c.Overrides[k]))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// defaultMaxPollIntervalMS is the librdkafka default of max.poll.interval.ms
+const defaultMaxPollIntervalMS = 300000
+
+// ackTimeout bounds the waits for acknowledgements which block the poll loop, it is half of max.poll.interval.ms
+// so that the worker polls again before it is removed from the group
+func (c ConsumerConfig) ackTimeout() time.Duration {
+	// the overrides can set the property as a string
+	ms, err := strconv.Atoi(fmt.Sprint(c.toConfigMap()["max.poll.interval.ms"]))
+	if err != nil || ms <= 0 {
+		ms = defaultMaxPollIntervalMS
+	}
+	return time.Duration(ms) * time.Millisecond / 2
 }
 
 func (c ConsumerConfig) toConfigMap() kafka.ConfigMap {
@@ -51,5 +196,21 @@ func (c ConsumerConfig) toConfigMap() kafka.ConfigMap {
 
 	kafkaConfMap["allow.auto.create.topics"] = c.AllowAutoCreateTopics
 
+	if c.StatisticsIntervalMS > 0 {
+		kafkaConfMap["statistics.interval.ms"] = c.StatisticsIntervalMS
+	}
+
+	if c.TransactionalID != "" {
+		// the offsets are committed by the transactions
+		kafkaConfMap["enable.auto.commit"] = false
+		kafkaConfMap["isolation.level"] = "read_committed"
+	}
+
+	setSecurity(kafkaConfMap, c.SASL, c.SSL)
+
+	for k, v := range c.Overrides {
+		kafkaConfMap[k] = v
+	}
+
 	return kafkaConfMap
 }
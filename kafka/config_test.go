@@ -0,0 +1,222 @@
+package kafka
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConsumerConfig_ToConfigMap(t *testing.T) {
+	t.Run("sets the security protocol from the SASL and SSL settings", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			sasl     *SASLConfig
+			ssl      *SSLConfig
+			protocol any
+		}{
+			{name: "plaintext"},
+			{name: "sasl", sasl: &SASLConfig{Mechanism: SASLMechanismPlain, Username: "u", Password: "p"}, protocol: "SASL_PLAINTEXT"},
+			{name: "ssl", ssl: &SSLConfig{}, protocol: "SSL"},
+			{name: "sasl over ssl", sasl: &SASLConfig{Mechanism: SASLMechanismScramSHA512, Username: "u", Password: "p"}, ssl: &SSLConfig{}, protocol: "SASL_SSL"},
+		}
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				cm := ConsumerConfig{SASL: c.sasl, SSL: c.ssl}.toConfigMap()
+				if got := cm["security.protocol"]; got != c.protocol {
+					t.Errorf("expected security.protocol %v got %v", c.protocol, got)
+				}
+			})
+		}
+	})
+
+	t.Run("sets the SASL and SSL properties", func(t *testing.T) {
+		cm := ConsumerConfig{
+			SASL: &SASLConfig{Mechanism: SASLMechanismScramSHA256, Username: "user", Password: "secret"},
+			SSL:  &SSLConfig{CALocation: "/etc/ca.pem", CertificateLocation: "/etc/client.pem", KeyLocation: "/etc/client.key"},
+		}.toConfigMap()
+		want := map[string]any{
+			"sasl.mechanism":           "SCRAM-SHA-256",
+			"sasl.username":            "user",
+			"sasl.password":            "secret",
+			"ssl.ca.location":          "/etc/ca.pem",
+			"ssl.certificate.location": "/etc/client.pem",
+			"ssl.key.location":         "/etc/client.key",
+		}
+		for k, v := range want {
+			if cm[k] != v {
+				t.Errorf("expected %s to be %v got %v", k, v, cm[k])
+			}
+		}
+		if _, ok := cm["ssl.key.password"]; ok {
+			t.Error("expected an empty KeyPassword to be left unset")
+		}
+	})
+
+	t.Run("OAUTHBEARER does not set a username and a password", func(t *testing.T) {
+		c := validConfig()
+		c.SASL = &SASLConfig{Mechanism: SASLMechanismOAuthBearer}
+		c.SSL = &SSLConfig{}
+		if err := c.Validate(); err != nil {
+			t.Errorf("expected no error got %v", err)
+		}
+		cm := c.toConfigMap()
+		if cm["sasl.mechanism"] != "OAUTHBEARER" {
+			t.Errorf("expected the OAUTHBEARER mechanism got %v", cm["sasl.mechanism"])
+		}
+		if _, ok := cm["sasl.username"]; ok {
+			t.Error("expected no sasl.username")
+		}
+	})
+
+	t.Run("overrides take precedence over the defaults and the typed settings", func(t *testing.T) {
+		cm := ConsumerConfig{
+			AutoOffsetReset: "earliest",
+			Overrides: kafka.ConfigMap{
+				"auto.offset.reset":       "latest",
+				"auto.commit.interval.ms": 1000,
+				"client.id":               "ziggurat",
+				"session.timeout.ms":      10000,
+			},
+		}.toConfigMap()
+		got := map[string]any{
+			"auto.offset.reset":       cm["auto.offset.reset"],
+			"auto.commit.interval.ms": cm["auto.commit.interval.ms"],
+			"client.id":               cm["client.id"],
+			"session.timeout.ms":      cm["session.timeout.ms"],
+		}
+		want := map[string]any{
+			"auto.offset.reset":       "latest",
+			"auto.commit.interval.ms": 1000,
+			"client.id":               "ziggurat",
+			"session.timeout.ms":      10000,
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected properties (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func validConfig() ConsumerConfig {
+	return ConsumerConfig{
+		BootstrapServers: "localhost:9092",
+		GroupID:          "orders",
+		Topics:           []string{"orders"},
+		ConsumerCount:    1,
+	}
+}
+
+func TestConsumerConfig_Validate(t *testing.T) {
+	valid := validConfig()
+	valid.SASL = &SASLConfig{Mechanism: SASLMechanismPlain, Username: "u", Password: "p"}
+	valid.SSL = &SSLConfig{CALocation: "/etc/ca.pem"}
+	valid.Overrides = kafka.ConfigMap{"fetch.min.bytes": 1024, "isolation.level": "read_uncommitted"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected no error got %v", err)
+	}
+	// a zero ConsumerCount starts a single consumer
+	valid.ConsumerCount = 0
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected no error for a zero ConsumerCount got %v", err)
+	}
+
+	invalid := ConsumerConfig{
+		Topics:               []string{"orders", ""},
+		ConsumerCount:        -1,
+		PollTimeout:          -2,
+		AutoOffsetReset:      "oldest",
+		InFlightLowWaterMark: -1,
+		MaxRedeliveries:      -2,
+		TransactionalID:      "tx",
+		SASL:                 &SASLConfig{Mechanism: "GSSAPI"},
+		SSL:                  &SSLConfig{CertificateLocation: "/etc/client.pem"},
+		Overrides: kafka.ConfigMap{
+			"enable.auto.offset.store": true,
+			"isolation.level":          "read_uncommitted",
+			"transactional.id":         "tx-0",
+			"fetch.wait.max.ms":        float64(100),
+		},
+	}
+	err := invalid.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		"BootstrapServers is required",
+		"GroupID is required",
+		"Topics[1] is empty",
+		"ConsumerCount must not be negative, got -1",
+		"PollTimeout must be -1 or more, got -2",
+		`unknown AutoOffsetReset "oldest"`,
+		"InFlightLowWaterMark must not be negative, got -1",
+		"MaxRedeliveries must be -1 or more, got -2",
+		`unsupported SASL mechanism "GSSAPI"`,
+		"requires a Username and a Password",
+		"requires both a CertificateLocation and a KeyLocation",
+		`"enable.auto.offset.store" cannot be overridden`,
+		`"isolation.level" cannot be overridden`,
+		`"transactional.id" cannot be overridden`,
+		`"fetch.wait.max.ms" has an unsupported value type float64`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to contain %q got:\n%v", want, err)
+		}
+	}
+}
+
+func TestConsumerGroup_ConsumeInvalidConfig(t *testing.T) {
+	cg := ConsumerGroup{GroupConfig: validConfig()}
+	cg.GroupConfig.Overrides = kafka.ConfigMap{"group.id": "other"}
+	cg.consumerMakeFunc = func(*kafka.ConfigMap, []string, kafka.RebalanceCb) confluentConsumer {
+		t.Fatal("expected no consumer to be created")
+		return nil
+	}
+	err := cg.Consume(context.Background(), ziggurat.HandlerFunc(func(context.Context, *ziggurat.Event) {}))
+	if err == nil || !strings.Contains(err.Error(), `"group.id" cannot be overridden`) {
+		t.Errorf("expected an override error got %v", err)
+	}
+}
+
+func TestConsumerConfig_AckTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config ConsumerConfig
+		want   time.Duration
+	}{
+		{name: "default", want: 150 * time.Second},
+		{name: "max poll interval", config: ConsumerConfig{MaxPollIntervalMS: 60000}, want: 30 * time.Second},
+		{name: "override", config: ConsumerConfig{MaxPollIntervalMS: 60000, Overrides: kafka.ConfigMap{"max.poll.interval.ms": "20000"}}, want: 10 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.config.ackTimeout(); got != tc.want {
+				t.Errorf("expected %v got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConsumerGroup_ZeroConsumerCount(t *testing.T) {
+	cg := &ConsumerGroup{GroupConfig: validConfig()}
+	cg.GroupConfig.ConsumerCount = 0
+	var created atomic.Int32
+	cg.consumerMakeFunc = func(*kafka.ConfigMap, []string, kafka.RebalanceCb) confluentConsumer {
+		created.Add(1)
+		return &pausableConsumer{paused: true}
+	}
+	ctx, cfn := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- cg.Consume(ctx, ziggurat.HandlerFunc(func(context.Context, *ziggurat.Event) {}))
+	}()
+	eventually(t, "expected a consumer to be started", func() bool { return len(cg.spawned()) > 0 })
+	cfn()
+	<-done
+	if got := created.Load(); got != 1 {
+		t.Errorf("expected a single consumer got %d", got)
+	}
+}
@@ -12,6 +12,16 @@ type confluentConsumer interface {
 	Logs() chan kafka.LogEvent
 	Commit() ([]kafka.TopicPartition, error)
 	Close() error
+	Seek(kafka.TopicPartition, int) error
+	GetConsumerGroupMetadata() (*kafka.ConsumerGroupMetadata, error)
+	Pause([]kafka.TopicPartition) error
+	Resume([]kafka.TopicPartition) error
+	Assignment() ([]kafka.TopicPartition, error)
+	SetOAuthBearerToken(kafka.OAuthBearerToken) error
+	SetOAuthBearerTokenFailure(string) error
+	QueryWatermarkOffsets(string, int32, int) (int64, int64, error)
+	OffsetsForTimes([]kafka.TopicPartition, int) ([]kafka.TopicPartition, error)
+	GetMetadata(*string, bool, int) (*kafka.Metadata, error)
 }
 
 type MockConsumer struct {
@@ -41,3 +51,48 @@ func (m *MockConsumer) Close() error {
 func (m *MockConsumer) Logs() chan kafka.LogEvent {
 	return m.Called().Get(0).(chan kafka.LogEvent)
 }
+
+func (m *MockConsumer) Seek(partition kafka.TopicPartition, timeoutMs int) error {
+	return m.Called(partition, timeoutMs).Error(0)
+}
+
+func (m *MockConsumer) GetConsumerGroupMetadata() (*kafka.ConsumerGroupMetadata, error) {
+	args := m.Called()
+	return args.Get(0).(*kafka.ConsumerGroupMetadata), args.Error(1)
+}
+
+func (m *MockConsumer) Pause(partitions []kafka.TopicPartition) error {
+	return m.Called(partitions).Error(0)
+}
+
+func (m *MockConsumer) Resume(partitions []kafka.TopicPartition) error {
+	return m.Called(partitions).Error(0)
+}
+
+func (m *MockConsumer) Assignment() ([]kafka.TopicPartition, error) {
+	args := m.Called()
+	return args.Get(0).([]kafka.TopicPartition), args.Error(1)
+}
+
+func (m *MockConsumer) SetOAuthBearerToken(token kafka.OAuthBearerToken) error {
+	return m.Called(token).Error(0)
+}
+
+func (m *MockConsumer) SetOAuthBearerTokenFailure(errstr string) error {
+	return m.Called(errstr).Error(0)
+}
+
+func (m *MockConsumer) QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (int64, int64, error) {
+	args := m.Called(topic, partition, timeoutMs)
+	return args.Get(0).(int64), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockConsumer) OffsetsForTimes(times []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error) {
+	args := m.Called(times, timeoutMs)
+	return args.Get(0).([]kafka.TopicPartition), args.Error(1)
+}
+
+func (m *MockConsumer) GetMetadata(topic *string, allTopics bool, timeoutMs int) (*kafka.Metadata, error) {
+	args := m.Called(topic, allTopics, timeoutMs)
+	return args.Get(0).(*kafka.Metadata), args.Error(1)
+}
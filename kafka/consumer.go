@@ -1,34 +1,53 @@
 package kafka
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/gojekfarm/ziggurat/v2"
 	"github.com/gojekfarm/ziggurat/v2/logger"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 )
 
 var ErrCleanShutdown = errors.New("error: clean shutdown of kafka consumers")
 
 type ConsumerGroup struct {
-	workers          []*worker
-	Logger           ziggurat.StructuredLogger
-	GroupConfig      ConsumerConfig
+	// workers is set once every worker is created, Pending, Pause, Resume and Seek read it while the group consumes
+	workers     []*worker
+	workersMu   sync.RWMutex
+	Logger      ziggurat.StructuredLogger
+	GroupConfig ConsumerConfig
+	// OnAssigned is invoked once partitions are assigned to the group
+	OnAssigned RebalanceHook
+	// OnRevoked is invoked once the in-flight events of the revoked partitions
+	// are acknowledged and their offsets are committed
+	OnRevoked RebalanceHook
+	// OnLost is invoked instead of OnRevoked when the partitions were lost without a rebalance,
+	// for example after a session timeout, their offsets cannot be committed
+	OnLost RebalanceHook
+	// OnStats is invoked with the statistics of every worker's consumer, it requires GroupConfig.StatisticsIntervalMS
+	OnStats StatsHook
+	// TokenProvider sets the OAUTHBEARER tokens of the consumers and the transactional producers,
+	// it is required for the OAUTHBEARER mechanism unless sasl.oauthbearer.method is set to oidc using Overrides
+	TokenProvider    TokenProvider
 	wg               *sync.WaitGroup
-	c                confluentConsumer
-	consumerMakeFunc func(*kafka.ConfigMap, []string) confluentConsumer
+	paused           pausedPartitions
+	seeks            pendingSeeks
+	consumerMakeFunc func(*kafka.ConfigMap, []string, kafka.RebalanceCb) confluentConsumer
 }
 
 func (cg *ConsumerGroup) Consume(ctx context.Context, handler ziggurat.Handler) error {
+	if err := cg.GroupConfig.Validate(); err != nil {
+		return err
+	}
 
 	cg.init()
 
-	if cg.GroupConfig.ConsumerCount < 1 {
-		cg.Logger.Warn("ConsumerConfig.GroupCount < 1, no consumers will be started")
-	}
-
 	grpConfig := cg.GroupConfig
 	groupID := grpConfig.GroupID
 	// sets default pollTimeout of 100ms
@@ -39,23 +58,54 @@ func (cg *ConsumerGroup) Consume(ctx context.Context, handler ziggurat.Handler)
 	}
 
 	cm := cg.GroupConfig.toConfigMap()
+	lowWaterMark := grpConfig.InFlightLowWaterMark
+	if lowWaterMark <= 0 || lowWaterMark >= grpConfig.InFlightHighWaterMark {
+		lowWaterMark = grpConfig.InFlightHighWaterMark / 2
+	}
 
-	confCons := cg.consumerMakeFunc(&cm, cg.GroupConfig.Topics)
+	var producers []*TransactionalProducer
+	if grpConfig.TransactionalID != "" {
+		var err error
+		if producers, err = cg.transactionalProducers(ctx); err != nil {
+			return err
+		}
+	}
 
-	cg.c = confCons
-	for i := 0; i < grpConfig.ConsumerCount; i++ {
+	workers := make([]*worker, grpConfig.ConsumerCount)
+	for i := range workers {
 		workerID := fmt.Sprintf("%s_%d", groupID, i)
 		cg.Logger.Info("spawning kafka worker", map[string]any{"id": workerID})
-		w := worker{
-			handler:     handler,
-			logger:      cg.Logger,
-			consumer:    confCons,
-			routeGroup:  cg.GroupConfig.GroupID,
-			pollTimeout: pollTimeout,
-			killSig:     make(chan struct{}),
-			id:          workerID,
+		// every worker owns a consumer, a partition is assigned to a single worker
+		// which hands its messages to the handler in order
+		w := &worker{
+			handler:       handler,
+			logger:        cg.Logger,
+			routeGroup:    cg.GroupConfig.GroupID,
+			pollTimeout:   pollTimeout,
+			killSig:       make(chan struct{}),
+			id:            workerID,
+			offsets:       newOffsetTracker(),
+			paused:        &cg.paused,
+			seeks:         &cg.seeks,
+			highWaterMark: grpConfig.InFlightHighWaterMark,
+			lowWaterMark:  lowWaterMark,
+			onStats:       cg.OnStats,
+			tokens:        cg.TokenProvider,
+			ackTimeout:    grpConfig.ackTimeout(),
+			// zero values are defaulted by the worker
+			maxRedeliveries:   grpConfig.MaxRedeliveries,
+			redeliveryBackoff: time.Duration(grpConfig.RedeliveryBackoffMS) * time.Millisecond,
 		}
-		cg.workers[i] = &w
+		w.consumer = cg.consumerMakeFunc(&cm, cg.GroupConfig.Topics, cg.rebalanceCb(ctx, w))
+		if producers != nil {
+			w.tx = producers[i]
+		}
+		workers[i] = w
+	}
+	cg.workersMu.Lock()
+	cg.workers = workers
+	cg.workersMu.Unlock()
+	for _, w := range workers {
 		cg.wg.Add(1)
 		go func() {
 			w.run(ctx)
@@ -66,10 +116,10 @@ func (cg *ConsumerGroup) Consume(ctx context.Context, handler ziggurat.Handler)
 	cg.wg.Wait()
 	cg.Logger.Info("kafka worker wait complete")
 	var causes string
-	for _, w := range cg.workers {
+	for _, w := range workers {
 		switch {
-		case !errors.Is(w.err, context.Canceled), !errors.Is(w.err, context.DeadlineExceeded):
-		case w.err != nil:
+		case w.err == nil, errors.Is(w.err, context.Canceled), errors.Is(w.err, context.DeadlineExceeded):
+		default:
 			err := fmt.Errorf("%s worker failed with error: %w\n", w.id, w.err)
 			causes = causes + err.Error()
 		}
@@ -80,15 +130,69 @@ func (cg *ConsumerGroup) Consume(ctx context.Context, handler ziggurat.Handler)
 	return errors.New(causes)
 }
 
+// Pending returns the partitions of the group whose offsets are held back by unacknowledged events,
+// sorted by topic and partition. Events are acknowledged out of order, the offset of a partition is only stored
+// once every earlier event of the partition is acknowledged so that no event is skipped after a restart
+func (cg *ConsumerGroup) Pending() []PartitionPending {
+	var pending []PartitionPending
+	for _, w := range cg.spawned() {
+		pending = append(pending, w.offsets.snapshot()...)
+	}
+	slices.SortFunc(pending, func(a, b PartitionPending) int {
+		if c := strings.Compare(a.Topic, b.Topic); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Partition, b.Partition)
+	})
+	return pending
+}
+
+// transactionalProducers creates a transactional producer for every worker
+func (cg *ConsumerGroup) transactionalProducers(ctx context.Context) ([]*TransactionalProducer, error) {
+	producers := make([]*TransactionalProducer, cg.GroupConfig.ConsumerCount)
+	for i := range producers {
+		p, err := NewTransactionalProducer(ctx, cg.transactionalProducerConfig(i), WithProducerLogger(cg.Logger), WithTokenProvider(cg.TokenProvider))
+		if err != nil {
+			for _, p := range producers[:i] {
+				cg.Logger.Error("error closing transactional producer", p.Close())
+			}
+			return nil, err
+		}
+		producers[i] = p
+	}
+	return producers, nil
+}
+
+// spawned returns the workers of the group, it is empty until Consume creates them
+func (cg *ConsumerGroup) spawned() []*worker {
+	cg.workersMu.RLock()
+	defer cg.workersMu.RUnlock()
+	return cg.workers
+}
+
+// transactionalProducerConfig returns the config of the transactional producer of the worker,
+// it connects to the cluster of the group with the same security settings and overrides
+func (cg *ConsumerGroup) transactionalProducerConfig(worker int) ProducerConfig {
+	return ProducerConfig{
+		BootstrapServers: cg.GroupConfig.BootstrapServers,
+		TransactionalID:  fmt.Sprintf("%s-%d", cg.GroupConfig.TransactionalID, worker),
+		SASL:             cg.GroupConfig.SASL,
+		SSL:              cg.GroupConfig.SSL,
+		Overrides:        cg.GroupConfig.Overrides,
+	}
+}
+
 func (cg *ConsumerGroup) init() {
 	var wg sync.WaitGroup
 	cg.wg = &wg
 
-	cg.workers = make([]*worker, cg.GroupConfig.ConsumerCount)
 	if cg.Logger == nil {
 		cg.Logger = logger.NOOP
 	}
 	if cg.consumerMakeFunc == nil {
 		cg.consumerMakeFunc = createConsumer
 	}
+	if cg.GroupConfig.ConsumerCount == 0 {
+		cg.GroupConfig.ConsumerCount = 1
+	}
 }
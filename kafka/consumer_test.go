@@ -6,6 +6,9 @@ import (
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/gojekfarm/ziggurat/v2"
 	"github.com/gojekfarm/ziggurat/v2/logger"
+	"github.com/google/go-cmp/cmp"
+	"math/rand"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -22,7 +25,7 @@ func TestWorkerOrchestration(t *testing.T) {
 				Topics:           []string{"foo"},
 				ConsumerCount:    5,
 			},
-			consumerMakeFunc: func(configMap *kafka.ConfigMap, strings []string) confluentConsumer {
+			consumerMakeFunc: func(configMap *kafka.ConfigMap, strings []string, rebalanceCb kafka.RebalanceCb) confluentConsumer {
 				return &mc
 			},
 		}
@@ -56,6 +59,215 @@ func TestWorkerOrchestration(t *testing.T) {
 
 }
 
+// partitionConsumer is a confluentConsumer which is assigned a single partition
+type partitionConsumer struct {
+	nopConsumer
+	partition int32
+	count     int
+	next      int
+	mu        sync.Mutex
+	stored    kafka.Offset
+}
+
+func (pc *partitionConsumer) Poll(int) kafka.Event {
+	if pc.next == pc.count {
+		time.Sleep(time.Millisecond)
+		return nil
+	}
+	m := &kafka.Message{TopicPartition: kafka.TopicPartition{
+		Topic:     makePtr("foo"),
+		Partition: pc.partition,
+		Offset:    kafka.Offset(pc.next),
+	}}
+	pc.next++
+	return m
+}
+
+func (pc *partitionConsumer) StoreOffsets(tps []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.stored = tps[0].Offset
+	return tps, nil
+}
+
+func TestConsumerGroup_PartitionOrdering(t *testing.T) {
+	const workers, messages = 4, 50
+	var mu sync.Mutex
+	var consumers []*partitionConsumer
+	cg := ConsumerGroup{
+		GroupConfig: ConsumerConfig{BootstrapServers: "localhost:9092", GroupID: "order-test", Topics: []string{"foo"}, ConsumerCount: workers},
+		consumerMakeFunc: func(*kafka.ConfigMap, []string, kafka.RebalanceCb) confluentConsumer {
+			mu.Lock()
+			defer mu.Unlock()
+			pc := &partitionConsumer{partition: int32(len(consumers)), count: messages}
+			consumers = append(consumers, pc)
+			return pc
+		},
+	}
+
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	seen := map[any][]int64{}
+	var total int
+	var active, maxActive int32
+	err := cg.Consume(ctx, ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			m := atomic.LoadInt32(&maxActive)
+			if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+				break
+			}
+		}
+		// handlers of different partitions run concurrently and finish in any order
+		time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+		mu.Lock()
+		defer mu.Unlock()
+		p := event.Metadata["kafka-partition"]
+		seen[p] = append(seen[p], event.Metadata["kafka-offset"].(int64))
+		total++
+		if total == workers*messages {
+			cfn()
+		}
+	}))
+	if !errors.Is(err, ErrCleanShutdown) {
+		t.Fatalf("expected a clean shutdown got %v", err)
+	}
+
+	if len(consumers) != workers {
+		t.Fatalf("expected a consumer per worker got %d", len(consumers))
+	}
+	for _, pc := range consumers {
+		offsets := seen[int(pc.partition)]
+		if len(offsets) != messages {
+			t.Fatalf("expected %d events for partition %d got %d", messages, pc.partition, len(offsets))
+		}
+		for i, o := range offsets {
+			if o != int64(i) {
+				t.Fatalf("expected partition %d to be handled in order got %v", pc.partition, offsets)
+			}
+		}
+		if pc.stored != messages {
+			t.Errorf("expected offset %d to be stored for partition %d got %d", messages, pc.partition, pc.stored)
+		}
+	}
+	if atomic.LoadInt32(&maxActive) < 2 {
+		t.Errorf("expected the partitions to be handled concurrently")
+	}
+}
+
+func TestConsumerGroup_WorkerErrors(t *testing.T) {
+	mc := MockConsumer{}
+	mc.On("Poll", 100).Return(kafka.NewError(kafka.ErrFatal, "fatal", true))
+	mc.On("Close").Return(nil)
+	mc.On("Commit").Return([]kafka.TopicPartition{}, nil)
+	mc.On("Logs").Return(make(chan kafka.LogEvent))
+	cg := ConsumerGroup{
+		GroupConfig: ConsumerConfig{BootstrapServers: "localhost:9092", GroupID: "error-test", Topics: []string{"foo"}, ConsumerCount: 1},
+		consumerMakeFunc: func(*kafka.ConfigMap, []string, kafka.RebalanceCb) confluentConsumer {
+			return &mc
+		},
+	}
+	err := cg.Consume(context.Background(), ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {}))
+	if err == nil || errors.Is(err, ErrCleanShutdown) {
+		t.Errorf("expected the worker error got %v", err)
+	}
+}
+
 func makePtr[V any](v V) *V {
 	return &v
 }
+
+func TestConsumerGroup_Pending(t *testing.T) {
+	acks := make(chan ziggurat.AckFunc, 100)
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		ack, _ := ziggurat.DeferAck(ctx)
+		acks <- ack
+	})
+	pc := &pausableConsumer{}
+	// the high water mark stops the worker at 4 in-flight events, it stays paused above 1
+	cg := &ConsumerGroup{GroupConfig: ConsumerConfig{InFlightHighWaterMark: 4, InFlightLowWaterMark: 1}}
+	cfn, done := consumeWith(t, cg, pc, h)
+
+	eventually(t, "expected the partition to be paused at the high water mark", func() bool {
+		paused, _ := pc.state()
+		return paused
+	})
+	want := []PartitionPending{{Topic: "foo", InFlight: 4}}
+	if diff := cmp.Diff(want, cg.Pending()); diff != "" {
+		t.Errorf("unexpected pending offsets (-want +got):\n%s", diff)
+	}
+
+	// the second event is acknowledged before the first, its offset is not stored
+	first, second := <-acks, <-acks
+	second(nil)
+	want = []PartitionPending{{Topic: "foo", InFlight: 3, Completed: 1}}
+	if diff := cmp.Diff(want, cg.Pending()); diff != "" {
+		t.Errorf("unexpected pending offsets (-want +got):\n%s", diff)
+	}
+	first(nil)
+	want = []PartitionPending{{Topic: "foo", InFlight: 2, Oldest: 2}}
+	if diff := cmp.Diff(want, cg.Pending()); diff != "" {
+		t.Errorf("unexpected pending offsets (-want +got):\n%s", diff)
+	}
+
+	cfn()
+	for {
+		select {
+		case ack := <-acks:
+			ack(nil)
+		case err := <-done:
+			if !errors.Is(err, ErrCleanShutdown) {
+				t.Errorf("expected a clean shutdown got %v", err)
+			}
+			return
+		}
+	}
+}
+
+func TestConsumerGroup_ControlWhileSpawning(t *testing.T) {
+	cg := &ConsumerGroup{
+		GroupConfig: ConsumerConfig{
+			BootstrapServers: "localhost:9092",
+			GroupID:          "control-test",
+			Topics:           []string{"foo"},
+			ConsumerCount:    8,
+			PollTimeout:      1,
+		},
+		consumerMakeFunc: func(*kafka.ConfigMap, []string, kafka.RebalanceCb) confluentConsumer {
+			return &pausableConsumer{}
+		},
+	}
+	foo0 := []kafka.TopicPartition{{Topic: makePtr("foo")}}
+	stop := make(chan struct{})
+	controlled := make(chan struct{})
+	// Pending, Pause, Resume, Seek and ConsumerHandles are called from other goroutines while the workers are created
+	go func() {
+		defer close(controlled)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			cg.Pending()
+			_ = cg.Pause(foo0)
+			_ = cg.Resume(foo0)
+			_, _ = cg.Seek(SeekTarget{Topic: "foo", Partition: kafka.PartitionAny, Offset: kafka.OffsetBeginning})
+			_, _ = ConsumerHandles(cg)
+		}
+	}()
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	done := make(chan error, 1)
+	go func() {
+		done <- cg.Consume(ctx, ziggurat.HandlerFunc(func(context.Context, *ziggurat.Event) {}))
+	}()
+	eventually(t, "expected the workers to be spawned", func() bool { return len(cg.spawned()) == 8 })
+	close(stop)
+	<-controlled
+	cfn()
+	if err := <-done; !errors.Is(err, ErrCleanShutdown) {
+		t.Errorf("expected a clean shutdown got %v", err)
+	}
+}
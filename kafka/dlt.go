@@ -0,0 +1,269 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+)
+
+// dltReadTimeout bounds the time spent reading a dead letter topic
+const dltReadTimeout = 10 * time.Second
+
+type dsViewResp struct {
+	Events []*ziggurat.Event `json:"events"`
+	Count  int               `json:"count"`
+}
+
+type dsReplayResp struct {
+	ReplayCount int `json:"replay_count"`
+	ErrorCount  int `json:"error_count"`
+}
+
+func validateQueryParams(req *http.Request) (string, int, error) {
+	queryParams := req.URL.Query()
+	topic := queryParams.Get("topic")
+	if topic == "" {
+		return "", 0, fmt.Errorf("expected query param: topic")
+	}
+	c := queryParams.Get("count")
+	if c == "" {
+		return "", 0, fmt.Errorf("expected query param: count")
+	}
+	count, err := strconv.Atoi(c)
+	if err != nil {
+		return "", 0, fmt.Errorf("expected count to be a number: %v", err)
+	}
+	return topic, count, nil
+}
+
+// dltReader reads the dead letter topic from the position stored by the replay group
+type dltReader struct {
+	consumer  *kafka.Consumer
+	positions map[partitionKey]kafka.TopicPartition
+}
+
+func (r *ARetry) readDLT(ctx context.Context, topicKey string, count int) (*dltReader, []*kafka.Message, error) {
+	rt, ok := r.topics[topicKey]
+	if !ok {
+		return nil, nil, fmt.Errorf("kafka: unknown retry topic key %s", topicKey)
+	}
+	cm := r.dltConfigMap()
+	c, err := kafka.NewConsumer(&cm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("kafka: error creating dead letter consumer: %w", err)
+	}
+	go func() {
+		for evt := range c.Logs() {
+			r.logger.Info(evt.Message, map[string]any{"client": evt.Name, "lvl": evt.Level})
+		}
+	}()
+	dr := &dltReader{consumer: c, positions: map[partitionKey]kafka.TopicPartition{}}
+	// the metadata requests below need a token before the consumer is polled
+	if r.tokens != nil {
+		if err := refreshToken(ctx, c, r.tokens); err != nil {
+			dr.close()
+			return nil, nil, err
+		}
+	}
+	if count < 1 {
+		return dr, nil, nil
+	}
+
+	topic := rt.DeadLetterTopic()
+	timeoutMS := int(dltReadTimeout.Milliseconds())
+	md, err := c.GetMetadata(&topic, false, timeoutMS)
+	if err != nil {
+		dr.close()
+		return nil, nil, fmt.Errorf("kafka: error fetching dead letter topic metadata: %w", err)
+	}
+	tm := md.Topics[topic]
+	if tm.Error.Code() == kafka.ErrUnknownTopicOrPart || len(tm.Partitions) == 0 {
+		return dr, nil, nil
+	}
+	tps := make([]kafka.TopicPartition, len(tm.Partitions))
+	for i, p := range tm.Partitions {
+		tps[i] = kafka.TopicPartition{Topic: &topic, Partition: p.ID}
+	}
+	committed, err := c.Committed(tps, timeoutMS)
+	if err != nil {
+		dr.close()
+		return nil, nil, fmt.Errorf("kafka: error fetching dead letter offsets: %w", err)
+	}
+
+	// every partition is read from its committed offset up to its high watermark
+	ends := map[partitionKey]kafka.Offset{}
+	var assignment []kafka.TopicPartition
+	for _, tp := range committed {
+		low, high, err := c.QueryWatermarkOffsets(topic, tp.Partition, timeoutMS)
+		if err != nil {
+			dr.close()
+			return nil, nil, fmt.Errorf("kafka: error fetching dead letter watermarks: %w", err)
+		}
+		start := kafka.Offset(low)
+		if tp.Offset >= 0 && tp.Offset > start {
+			start = tp.Offset
+		}
+		if start >= kafka.Offset(high) {
+			continue
+		}
+		tp.Offset = start
+		ends[keyFor(tp)] = kafka.Offset(high)
+		assignment = append(assignment, tp)
+	}
+	if err := c.Assign(assignment); err != nil {
+		dr.close()
+		return nil, nil, fmt.Errorf("kafka: error assigning dead letter partitions: %w", err)
+	}
+
+	var msgs []*kafka.Message
+	deadline := time.Now().Add(dltReadTimeout)
+	for len(msgs) < count && len(ends) > 0 && ctx.Err() == nil && time.Now().Before(deadline) {
+		switch e := c.Poll(100).(type) {
+		case *kafka.Message:
+			k := keyFor(e.TopicPartition)
+			end, ok := ends[k]
+			if !ok {
+				continue
+			}
+			msgs = append(msgs, e)
+			next := e.TopicPartition
+			next.Offset++
+			dr.positions[k] = next
+			if next.Offset >= end {
+				delete(ends, k)
+			}
+		case kafka.Error:
+			if e.IsFatal() {
+				dr.close()
+				return nil, nil, e
+			}
+			r.logger.Error("kafka dead letter poll error", e)
+		case kafka.OAuthBearerTokenRefresh:
+			r.logger.Error("kafka oauthbearer token refresh error", refreshToken(ctx, c, r.tokens))
+		}
+	}
+	return dr, msgs, nil
+}
+
+// commit stores the position after the messages which were read
+func (dr *dltReader) commit() error {
+	if len(dr.positions) == 0 {
+		return nil
+	}
+	offsets := make([]kafka.TopicPartition, 0, len(dr.positions))
+	for _, tp := range dr.positions {
+		offsets = append(offsets, tp)
+	}
+	_, err := dr.consumer.CommitOffsets(offsets)
+	return err
+}
+
+func (dr *dltReader) close() {
+	_ = dr.consumer.Close()
+}
+
+func (r *ARetry) view(ctx context.Context, topicKey string, count int) ([]*ziggurat.Event, error) {
+	dr, msgs, err := r.readDLT(ctx, topicKey, count)
+	if err != nil {
+		return nil, err
+	}
+	defer dr.close()
+	events := make([]*ziggurat.Event, 0, len(msgs))
+	for _, m := range msgs {
+		var e ziggurat.Event
+		if err := json.Unmarshal(m.Value, &e); err != nil {
+			return nil, fmt.Errorf("kafka: error decoding dead letter message: %w", err)
+		}
+		events = append(events, &e)
+	}
+	return events, nil
+}
+
+// replay sends the messages of the dead letter topic to the first retry topic with their retry count reset,
+// the replay position is committed for the messages which were sent
+func (r *ARetry) replay(ctx context.Context, topicKey string, count int) (int, int, error) {
+	dr, msgs, err := r.readDLT(ctx, topicKey, count)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer dr.close()
+	rt := r.topics[topicKey]
+
+	var replayCount, errorCount int
+	for i, m := range msgs {
+		var e ziggurat.Event
+		if err := json.Unmarshal(m.Value, &e); err != nil {
+			// an undecodable message is skipped, replaying it again would fail as well
+			r.logger.Error("kafka dead letter replay: skipping message", err, map[string]any{"offset": m.TopicPartition.Offset})
+			errorCount++
+			continue
+		}
+		if err := r.send(ctx, rt.retryTopic(0), &e, 0, time.Time{}); err != nil {
+			// the position is moved only up to the messages which were replayed
+			dr.positions = map[partitionKey]kafka.TopicPartition{}
+			for _, sent := range msgs[:i] {
+				next := sent.TopicPartition
+				next.Offset++
+				dr.positions[keyFor(next)] = next
+			}
+			if cerr := dr.commit(); cerr != nil {
+				r.logger.Error("kafka dead letter replay: commit error", cerr)
+			}
+			return replayCount, errorCount, fmt.Errorf("kafka: error replaying dead letter message: %w", err)
+		}
+		replayCount++
+	}
+	if err := dr.commit(); err != nil {
+		return replayCount, errorCount, fmt.Errorf("kafka: error committing dead letter offsets: %w", err)
+	}
+	return replayCount, errorCount, nil
+}
+
+// DSViewHandler allows you to peek into the dead letter topic of a topic key,
+// the events are returned from the position up to which the topic has been replayed
+func (r *ARetry) DSViewHandler(ctx context.Context) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		topicKey, count, err := validateQueryParams(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		events, err := r.view(ctx, topicKey, count)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("couldn't view messages from dlt: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(dsViewResp{Events: events, Count: len(events)})
+		r.logger.Error("json encode error", err)
+	}
+	return http.HandlerFunc(f)
+}
+
+// DSReplayHandler replays the events of the dead letter topic of a topic key through the retry topics
+func (r *ARetry) DSReplayHandler(ctx context.Context) http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		topicKey, count, err := validateQueryParams(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		replayCount, errorCount, err := r.replay(ctx, topicKey, count)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(dsReplayResp{ReplayCount: replayCount, ErrorCount: errorCount})
+		r.logger.Error("json encode error", err)
+	}
+	return http.HandlerFunc(f)
+}
@@ -5,12 +5,12 @@ import (
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 )
 
-func createConsumer(consumerConfig *kafka.ConfigMap, topics []string) confluentConsumer {
+func createConsumer(consumerConfig *kafka.ConfigMap, topics []string, rebalanceCb kafka.RebalanceCb) confluentConsumer {
 	consumer, err := kafka.NewConsumer(consumerConfig)
 	if err != nil {
 		panic("error creating consumer:" + err.Error())
 	}
-	subscribeErr := consumer.SubscribeTopics(topics, nil)
+	subscribeErr := consumer.SubscribeTopics(topics, rebalanceCb)
 	if subscribeErr != nil {
 		panic("error subscribing to topics:" + subscribeErr.Error())
 	}
@@ -20,10 +20,15 @@ func createConsumer(consumerConfig *kafka.ConfigMap, topics []string) confluentC
 // storeOffsets ensures at least once delivery
 // offsets are stored in memory and are later flushed by the auto-commit timer
 func storeOffsets(consumer confluentConsumer, partition kafka.TopicPartition) error {
+	return storeOffsetsIn(consumer, make([]kafka.TopicPartition, 1), partition)
+}
+
+// storeOffsetsIn stores the offset using the single element slice offsets
+func storeOffsetsIn(consumer confluentConsumer, offsets []kafka.TopicPartition, partition kafka.TopicPartition) error {
 	if partition.Error != nil {
 		return fmt.Errorf("error storing offsets:%w", partition.Error)
 	}
-	offsets := []kafka.TopicPartition{partition}
+	offsets[0] = partition
 	offsets[0].Offset++
 	if _, err := consumer.StoreOffsets(offsets); err != nil {
 		return err
@@ -0,0 +1,153 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2/internal/configfile"
+)
+
+// envReader reads the variables of a prefix and collects the parse errors
+type envReader struct {
+	prefix string
+	errs   []error
+}
+
+func (r *envReader) name(key string) string {
+	if r.prefix == "" {
+		return key
+	}
+	return r.prefix + "_" + key
+}
+
+func (r *envReader) str(key string) string {
+	return os.Getenv(r.name(key))
+}
+
+func (r *envReader) integer(key string) int {
+	v := r.str(key)
+	if v == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("kafka: %s must be an integer, got %q", r.name(key), v))
+	}
+	return i
+}
+
+func (r *envReader) boolean(key string) bool {
+	v := r.str(key)
+	if v == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("kafka: %s must be a boolean, got %q", r.name(key), v))
+	}
+	return b
+}
+
+func (r *envReader) list(key string) []string {
+	var values []string
+	for _, v := range strings.Split(r.str(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// overrides maps the variables <PREFIX>_OVERRIDE_<PROPERTY> to librdkafka properties,
+// the property is lower cased and its underscores are replaced with dots
+func (r *envReader) overrides() kafka.ConfigMap {
+	overridePrefix := r.name("OVERRIDE_")
+	var cm kafka.ConfigMap
+	env := os.Environ()
+	sort.Strings(env)
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		property, ok := strings.CutPrefix(k, overridePrefix)
+		if !ok || property == "" {
+			continue
+		}
+		if cm == nil {
+			cm = kafka.ConfigMap{}
+		}
+		cm[strings.ReplaceAll(strings.ToLower(property), "_", ".")] = v
+	}
+	return cm
+}
+
+// ConfigFromEnv reads a ConsumerConfig from the environment variables of the prefix,
+// the variable of a field is <PREFIX>_<FIELD> where FIELD is the upper cased YAML name of the field,
+// for example KAFKA_BOOTSTRAP_SERVERS, KAFKA_TOPICS is a comma separated list.
+// The SASL and SSL fields are read from <PREFIX>_SASL_<FIELD> and <PREFIX>_SSL_<FIELD>,
+// and <PREFIX>_OVERRIDE_SESSION_TIMEOUT_MS overrides session.timeout.ms.
+//
+// The returned error lists every variable which cannot be parsed and every invalid setting
+func ConfigFromEnv(prefix string) (ConsumerConfig, error) {
+	r := &envReader{prefix: prefix}
+	c := ConsumerConfig{
+		BootstrapServers:      r.str("BOOTSTRAP_SERVERS"),
+		DebugLevel:            r.str("DEBUG_LEVEL"),
+		GroupID:               r.str("GROUP_ID"),
+		Topics:                r.list("TOPICS"),
+		AutoCommitInterval:    r.integer("AUTO_COMMIT_INTERVAL"),
+		ConsumerCount:         r.integer("CONSUMER_COUNT"),
+		PollTimeout:           r.integer("POLL_TIMEOUT"),
+		AutoOffsetReset:       r.str("AUTO_OFFSET_RESET"),
+		PartitionAssignment:   r.str("PARTITION_ASSIGNMENT"),
+		MaxPollIntervalMS:     r.integer("MAX_POLL_INTERVAL_MS"),
+		AllowAutoCreateTopics: r.boolean("ALLOW_AUTO_CREATE_TOPICS"),
+		TransactionalID:       r.str("TRANSACTIONAL_ID"),
+		InFlightHighWaterMark: r.integer("IN_FLIGHT_HIGH_WATER_MARK"),
+		InFlightLowWaterMark:  r.integer("IN_FLIGHT_LOW_WATER_MARK"),
+		MaxRedeliveries:       r.integer("MAX_REDELIVERIES"),
+		RedeliveryBackoffMS:   r.integer("REDELIVERY_BACKOFF_MS"),
+		StatisticsIntervalMS:  r.integer("STATISTICS_INTERVAL_MS"),
+		Overrides:             r.overrides(),
+	}
+	if mechanism := r.str("SASL_MECHANISM"); mechanism != "" {
+		c.SASL = &SASLConfig{
+			Mechanism: mechanism,
+			Username:  r.str("SASL_USERNAME"),
+			Password:  r.str("SASL_PASSWORD"),
+		}
+	}
+	ssl := SSLConfig{
+		CALocation:          r.str("SSL_CA_LOCATION"),
+		CertificateLocation: r.str("SSL_CERTIFICATE_LOCATION"),
+		KeyLocation:         r.str("SSL_KEY_LOCATION"),
+		KeyPassword:         r.str("SSL_KEY_PASSWORD"),
+	}
+	// SSL with the system CA certificates is enabled using <PREFIX>_SSL_ENABLED
+	if ssl != (SSLConfig{}) || r.boolean("SSL_ENABLED") {
+		c.SSL = &ssl
+	}
+	// the parse errors are listed along with the invalid settings
+	return c, errors.Join(append(r.errs, c.Validate())...)
+}
+
+// ConfigFromFile reads a ConsumerConfig from a YAML or JSON file, the fields use their YAML names
+//
+//	bootstrap_servers: localhost:9092
+//	group_id: orders
+//	topics: [orders]
+//	consumer_count: 4
+//	overrides:
+//	  session.timeout.ms: 10000
+//
+// Unknown fields are errors, the returned error lists every invalid setting
+func ConfigFromFile(path string) (ConsumerConfig, error) {
+	var c ConsumerConfig
+	if err := configfile.Decode(path, &c); err != nil {
+		return c, fmt.Errorf("kafka: %w", err)
+	}
+	return c, c.Validate()
+}
@@ -0,0 +1,165 @@
+package kafka
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Run("reads every field of the prefix", func(t *testing.T) {
+		for k, v := range map[string]string{
+			"ORDERS_BOOTSTRAP_SERVERS":           "broker-1:9092,broker-2:9092",
+			"ORDERS_GROUP_ID":                    "orders",
+			"ORDERS_TOPICS":                      "orders, refunds",
+			"ORDERS_CONSUMER_COUNT":              "4",
+			"ORDERS_AUTO_OFFSET_RESET":           "earliest",
+			"ORDERS_ALLOW_AUTO_CREATE_TOPICS":    "true",
+			"ORDERS_IN_FLIGHT_HIGH_WATER_MARK":   "100",
+			"ORDERS_MAX_REDELIVERIES":            "-1",
+			"ORDERS_SASL_MECHANISM":              "SCRAM-SHA-512",
+			"ORDERS_SASL_USERNAME":               "orders",
+			"ORDERS_SASL_PASSWORD":               "secret",
+			"ORDERS_SSL_ENABLED":                 "true",
+			"ORDERS_OVERRIDE_SESSION_TIMEOUT_MS": "10000",
+			"ORDERS_OVERRIDE_CLIENT_ID":          "orders-consumer",
+			"REFUNDS_GROUP_ID":                   "refunds",
+			"REFUNDS_OVERRIDE_FETCH_WAIT_MAX_MS": "50",
+		} {
+			t.Setenv(k, v)
+		}
+		got, err := ConfigFromEnv("ORDERS")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := ConsumerConfig{
+			BootstrapServers:      "broker-1:9092,broker-2:9092",
+			GroupID:               "orders",
+			Topics:                []string{"orders", "refunds"},
+			ConsumerCount:         4,
+			AutoOffsetReset:       "earliest",
+			AllowAutoCreateTopics: true,
+			InFlightHighWaterMark: 100,
+			MaxRedeliveries:       -1,
+			SASL:                  &SASLConfig{Mechanism: SASLMechanismScramSHA512, Username: "orders", Password: "secret"},
+			SSL:                   &SSLConfig{},
+			Overrides:             kafka.ConfigMap{"session.timeout.ms": "10000", "client.id": "orders-consumer"},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected config (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("lists every invalid variable and setting", func(t *testing.T) {
+		t.Setenv("ORDERS_CONSUMER_COUNT", "four")
+		t.Setenv("ORDERS_POLL_TIMEOUT", "1s")
+		t.Setenv("ORDERS_ALLOW_AUTO_CREATE_TOPICS", "yes please")
+		t.Setenv("ORDERS_OVERRIDE_GROUP_ID", "other")
+		_, err := ConfigFromEnv("ORDERS")
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		for _, want := range []string{
+			`ORDERS_CONSUMER_COUNT must be an integer, got "four"`,
+			`ORDERS_POLL_TIMEOUT must be an integer, got "1s"`,
+			`ORDERS_ALLOW_AUTO_CREATE_TOPICS must be a boolean, got "yes please"`,
+			"BootstrapServers is required",
+			"GroupID is required",
+			"Topics must not be empty",
+			`"group.id" cannot be overridden`,
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected the error to contain %q got:\n%v", want, err)
+			}
+		}
+	})
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFromFile(t *testing.T) {
+	want := ConsumerConfig{
+		BootstrapServers: "localhost:9092",
+		GroupID:          "orders",
+		Topics:           []string{"orders"},
+		ConsumerCount:    2,
+		SSL:              &SSLConfig{CALocation: "/etc/kafka/ca.pem"},
+		Overrides:        kafka.ConfigMap{"session.timeout.ms": 10000, "enable.partition.eof": true},
+	}
+
+	t.Run("reads a YAML file", func(t *testing.T) {
+		path := writeFile(t, "kafka.yaml", `
+bootstrap_servers: localhost:9092
+group_id: orders
+topics: [orders]
+consumer_count: 2
+ssl:
+  ca_location: /etc/kafka/ca.pem
+overrides:
+  session.timeout.ms: 10000
+  enable.partition.eof: true
+`)
+		got, err := ConfigFromFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected config (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("reads a JSON file", func(t *testing.T) {
+		path := writeFile(t, "kafka.json", `{
+  "bootstrap_servers": "localhost:9092",
+  "group_id": "orders",
+  "topics": ["orders"],
+  "consumer_count": 2,
+  "ssl": {"ca_location": "/etc/kafka/ca.pem"},
+  "overrides": {"session.timeout.ms": 10000, "enable.partition.eof": true}
+}`)
+		got, err := ConfigFromFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected config (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		path := writeFile(t, "kafka.yaml", "bootstrap_servers: localhost:9092\ngroupid: orders\n")
+		if _, err := ConfigFromFile(path); err == nil || !strings.Contains(err.Error(), "field groupid not found") {
+			t.Errorf("expected an unknown field error got %v", err)
+		}
+	})
+
+	t.Run("lists every invalid setting", func(t *testing.T) {
+		path := writeFile(t, "kafka.yaml", "bootstrap_servers: localhost:9092\nconsumer_count: -1\n")
+		_, err := ConfigFromFile(path)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		for _, want := range []string{"GroupID is required", "Topics must not be empty", "ConsumerCount must not be negative"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected the error to contain %q got:\n%v", want, err)
+			}
+		}
+	})
+
+	t.Run("rejects unsupported extensions", func(t *testing.T) {
+		if _, err := ConfigFromFile(writeFile(t, "kafka.toml", "")); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// TokenProvider returns the OAUTHBEARER token of a client, it is invoked whenever
+// librdkafka requests a token refresh, which happens once the client is created
+// and at 80% of the lifetime of the previous token
+type TokenProvider interface {
+	Token(ctx context.Context) (kafka.OAuthBearerToken, error)
+}
+
+type TokenProviderFunc func(ctx context.Context) (kafka.OAuthBearerToken, error)
+
+func (f TokenProviderFunc) Token(ctx context.Context) (kafka.OAuthBearerToken, error) {
+	return f(ctx)
+}
+
+// tokenSetter is implemented by both the kafka consumer and the producer
+type tokenSetter interface {
+	SetOAuthBearerToken(kafka.OAuthBearerToken) error
+	SetOAuthBearerTokenFailure(string) error
+}
+
+// refreshToken sets the token returned by the provider, a provider error is reported to librdkafka
+// which requests a refresh again after a while, the provider error is returned as is
+func refreshToken(ctx context.Context, c tokenSetter, tp TokenProvider) error {
+	if tp == nil {
+		return c.SetOAuthBearerTokenFailure("no TokenProvider is configured")
+	}
+	token, err := tp.Token(ctx)
+	if err != nil {
+		if ferr := c.SetOAuthBearerTokenFailure(err.Error()); ferr != nil {
+			return fmt.Errorf("kafka: error reporting token failure %q: %w", err.Error(), ferr)
+		}
+		return fmt.Errorf("kafka: token provider error: %w", err)
+	}
+	if err := c.SetOAuthBearerToken(token); err != nil {
+		return fmt.Errorf("kafka: error setting token: %w", err)
+	}
+	return nil
+}
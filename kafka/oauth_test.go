@@ -0,0 +1,74 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+	"github.com/stretchr/testify/mock"
+)
+
+// runTokenWorker runs a worker whose consumer requests a token refresh on every poll until ctx is done
+func runTokenWorker(t *testing.T, mc *MockConsumer, tp TokenProvider) {
+	t.Helper()
+	w := worker{
+		logger:      logger.NOOP,
+		consumer:    mc,
+		pollTimeout: 1,
+		killSig:     make(chan struct{}),
+		id:          "token-worker",
+		tokens:      tp,
+	}
+	mc.On("Logs").Return(make(chan kafka.LogEvent))
+	mc.On("Poll", 1).Return(kafka.OAuthBearerTokenRefresh{})
+	mc.On("Commit").Return([]kafka.TopicPartition{}, nil)
+	mc.On("Close").Return(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	w.run(ctx)
+}
+
+func TestWorker_OAuthBearerTokenRefresh(t *testing.T) {
+	t.Run("sets the token of the provider", func(t *testing.T) {
+		token := kafka.OAuthBearerToken{TokenValue: "token", Expiration: time.Now().Add(time.Hour), Principal: "ziggurat"}
+		mc := &MockConsumer{}
+		mc.On("SetOAuthBearerToken", token).Return(nil)
+		runTokenWorker(t, mc, TokenProviderFunc(func(ctx context.Context) (kafka.OAuthBearerToken, error) {
+			return token, nil
+		}))
+		mc.AssertCalled(t, "SetOAuthBearerToken", token)
+		mc.AssertNotCalled(t, "SetOAuthBearerTokenFailure", mock.Anything)
+	})
+
+	t.Run("reports the provider error as a token failure", func(t *testing.T) {
+		mc := &MockConsumer{}
+		mc.On("SetOAuthBearerTokenFailure", "identity provider unavailable").Return(nil)
+		runTokenWorker(t, mc, TokenProviderFunc(func(ctx context.Context) (kafka.OAuthBearerToken, error) {
+			return kafka.OAuthBearerToken{}, errors.New("identity provider unavailable")
+		}))
+		mc.AssertCalled(t, "SetOAuthBearerTokenFailure", "identity provider unavailable")
+		mc.AssertNotCalled(t, "SetOAuthBearerToken", mock.Anything)
+	})
+
+	t.Run("reports a failure without a provider", func(t *testing.T) {
+		mc := &MockConsumer{}
+		mc.On("SetOAuthBearerTokenFailure", mock.AnythingOfType("string")).Return(nil)
+		runTokenWorker(t, mc, nil)
+		mc.AssertCalled(t, "SetOAuthBearerTokenFailure", "no TokenProvider is configured")
+	})
+}
+
+func TestRefreshToken_Errors(t *testing.T) {
+	mc := &MockConsumer{}
+	rejected := errors.New("token rejected")
+	mc.On("SetOAuthBearerToken", mock.Anything).Return(rejected)
+	err := refreshToken(context.Background(), mc, TokenProviderFunc(func(ctx context.Context) (kafka.OAuthBearerToken, error) {
+		return kafka.OAuthBearerToken{TokenValue: "expired"}, nil
+	}))
+	if !errors.Is(err, rejected) {
+		t.Errorf("expected the set token error got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package kafka
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+type pendingOffset struct {
+	offset kafka.Offset
+	done   bool
+}
+
+// offsetTracker tracks the in-flight offsets of every partition
+// events can complete out of order, the tracker only hands out
+// the highest offset below which every tracked offset has completed
+type offsetTracker struct {
+	mu      sync.Mutex
+	pending map[partitionKey][]pendingOffset
+	// inflight counts the tracked offsets which are neither completed nor failed
+	inflight map[partitionKey]int
+	// total is the sum of the in-flight offsets of every partition
+	total    int
+	released *sync.Cond
+	// failed holds the lowest failed offset of every partition until the partition is forgotten
+	failed map[partitionKey]kafka.Offset
+	// failures changes whenever an offset fails so that the worker only looks for failed partitions after a failure
+	failures atomic.Uint64
+}
+
+func newOffsetTracker() *offsetTracker {
+	ot := &offsetTracker{
+		pending:  map[partitionKey][]pendingOffset{},
+		inflight: map[partitionKey]int{},
+		failed:   map[partitionKey]kafka.Offset{},
+	}
+	ot.released = sync.NewCond(&ot.mu)
+	return ot
+}
+
+func keyFor(tp kafka.TopicPartition) partitionKey {
+	var topic string
+	if tp.Topic != nil {
+		topic = *tp.Topic
+	}
+	return partitionKey{topic: topic, partition: tp.Partition}
+}
+
+// track marks the offset as in-flight
+func (ot *offsetTracker) track(tp kafka.TopicPartition) {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+	k := keyFor(tp)
+	ps := ot.pending[k]
+	// offsets are mostly tracked in order, sort.Search keeps it correct when they are not
+	i := sort.Search(len(ps), func(i int) bool {
+		return ps[i].offset > tp.Offset
+	})
+	ps = append(ps, pendingOffset{})
+	copy(ps[i+1:], ps[i:])
+	ps[i] = pendingOffset{offset: tp.Offset}
+	ot.pending[k] = ps
+	ot.inflight[k]++
+	ot.total++
+}
+
+// inFlight returns the number of offsets which are neither completed nor failed
+func (ot *offsetTracker) inFlight() int {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+	return ot.total
+}
+
+// partitionInFlight returns the number of in-flight offsets of the partition
+func (ot *offsetTracker) partitionInFlight(tp kafka.TopicPartition) int {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+	return ot.inflight[keyFor(tp)]
+}
+
+// PartitionPending holds the events of a partition whose offsets are not stored yet
+type PartitionPending struct {
+	Topic     string
+	Partition int32
+	// InFlight events are neither acknowledged nor failed
+	InFlight int
+	// Completed events are acknowledged but wait for an earlier event of the partition
+	Completed int
+	// Failed events hold back the offsets of the partition until they are redelivered
+	Failed int
+	// Oldest is the earliest offset which is not completed, the partition is consumed again from it after a restart
+	Oldest kafka.Offset
+}
+
+// snapshot returns the partitions with pending offsets
+func (ot *offsetTracker) snapshot() []PartitionPending {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+	var pending []PartitionPending
+	for k, ps := range ot.pending {
+		// the completed offsets at the start of a partition are removed once they are stored
+		if len(ps) == 0 {
+			continue
+		}
+		pp := PartitionPending{Topic: k.topic, Partition: k.partition, InFlight: ot.inflight[k], Oldest: ps[0].offset}
+		for _, p := range ps {
+			if p.done {
+				pp.Completed++
+			}
+		}
+		pp.Failed = len(ps) - pp.Completed - pp.InFlight
+		pending = append(pending, pp)
+	}
+	return pending
+}
+
+// release must be called with the lock held
+func (ot *offsetTracker) release(k partitionKey) {
+	// the partition may have been forgotten while the offset was in-flight
+	if ot.inflight[k] == 0 {
+		return
+	}
+	ot.inflight[k]--
+	ot.total--
+	if ot.inflight[k] == 0 {
+		ot.released.Broadcast()
+	}
+}
+
+// fail releases the offset without completing it, the offsets of the partition
+// are not stored beyond it until the partition is forgotten
+func (ot *offsetTracker) fail(tp kafka.TopicPartition) {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+	k := keyFor(tp)
+	for _, p := range ot.pending[k] {
+		if p.offset != tp.Offset || p.done {
+			continue
+		}
+		if failed, ok := ot.failed[k]; !ok || tp.Offset < failed {
+			ot.failed[k] = tp.Offset
+		}
+		ot.failures.Add(1)
+		break
+	}
+	ot.release(k)
+}
+
+// failedPartitions returns the partitions with a failed offset along with their lowest failed offset
+func (ot *offsetTracker) failedPartitions() []kafka.TopicPartition {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+	partitions := make([]kafka.TopicPartition, 0, len(ot.failed))
+	for k, offset := range ot.failed {
+		topic := k.topic
+		partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: k.partition, Offset: offset})
+	}
+	return partitions
+}
+
+// drain waits until the in-flight offsets of the partitions are completed or failed,
+// it returns the error of ctx if ctx is done before
+func (ot *offsetTracker) drain(ctx context.Context, partitions []kafka.TopicPartition) error {
+	stop := context.AfterFunc(ctx, func() {
+		ot.mu.Lock()
+		ot.released.Broadcast()
+		ot.mu.Unlock()
+	})
+	defer stop()
+
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+	for _, tp := range partitions {
+		k := keyFor(tp)
+		for ot.inflight[k] > 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			ot.released.Wait()
+		}
+	}
+	return nil
+}
+
+// forget drops the offsets of the partitions once they are revoked,
+// the next owner of a partition starts from its committed offset
+func (ot *offsetTracker) forget(partitions []kafka.TopicPartition) {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+	for _, tp := range partitions {
+		k := keyFor(tp)
+		ot.total -= ot.inflight[k]
+		delete(ot.pending, k)
+		delete(ot.inflight, k)
+		delete(ot.failed, k)
+	}
+}
+
+// complete marks the offset as completed, it returns the highest offset
+// of the contiguous completed range at the start of the partition's in-flight offsets
+// the bool return value is false when there is no new offset to store
+func (ot *offsetTracker) complete(tp kafka.TopicPartition) (kafka.TopicPartition, bool) {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+	k := keyFor(tp)
+	ps := ot.pending[k]
+	for i := range ps {
+		if ps[i].offset == tp.Offset && !ps[i].done {
+			ps[i].done = true
+			ot.release(k)
+			break
+		}
+	}
+
+	var n int
+	for n < len(ps) && ps[n].done {
+		n++
+	}
+	if n == 0 {
+		return tp, false
+	}
+	last := ps[n-1].offset
+	// shift the remaining offsets to the front to reuse the backing array
+	ot.pending[k] = ps[:copy(ps, ps[n:])]
+	tp.Offset = last
+	return tp, true
+}
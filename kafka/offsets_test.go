@@ -0,0 +1,168 @@
+package kafka
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	tp := func(topic string, part int32, off int64) kafka.TopicPartition {
+		return kafka.TopicPartition{Topic: &topic, Partition: part, Offset: kafka.Offset(off)}
+	}
+
+	t.Run("stores only contiguously completed offsets", func(t *testing.T) {
+		ot := newOffsetTracker()
+		for _, o := range []int64{10, 11, 12, 13} {
+			ot.track(tp("foo", 0, o))
+		}
+
+		if _, ok := ot.complete(tp("foo", 0, 12)); ok {
+			t.Error("expected no offset to store when 10 and 11 are pending")
+		}
+		if _, ok := ot.complete(tp("foo", 0, 11)); ok {
+			t.Error("expected no offset to store when 10 is pending")
+		}
+		got, ok := ot.complete(tp("foo", 0, 10))
+		if !ok || got.Offset != 12 {
+			t.Errorf("expected offset 12 got %v %v", got.Offset, ok)
+		}
+		got, ok = ot.complete(tp("foo", 0, 13))
+		if !ok || got.Offset != 13 {
+			t.Errorf("expected offset 13 got %v %v", got.Offset, ok)
+		}
+		if n := len(ot.pending[keyFor(tp("foo", 0, 0))]); n != 0 {
+			t.Errorf("expected no pending offsets got %d", n)
+		}
+	})
+
+	t.Run("partitions are tracked independently", func(t *testing.T) {
+		ot := newOffsetTracker()
+		ot.track(tp("foo", 0, 1))
+		ot.track(tp("foo", 1, 5))
+		ot.track(tp("bar", 0, 7))
+
+		got, ok := ot.complete(tp("foo", 1, 5))
+		if !ok || got.Offset != 5 || got.Partition != 1 {
+			t.Errorf("expected foo/1 offset 5 got %v", got)
+		}
+		got, ok = ot.complete(tp("bar", 0, 7))
+		if !ok || got.Offset != 7 || *got.Topic != "bar" {
+			t.Errorf("expected bar/0 offset 7 got %v", got)
+		}
+	})
+
+	t.Run("offsets tracked out of order are sorted", func(t *testing.T) {
+		ot := newOffsetTracker()
+		ot.track(tp("foo", 0, 2))
+		ot.track(tp("foo", 0, 1))
+		if _, ok := ot.complete(tp("foo", 0, 2)); ok {
+			t.Error("expected no offset to store when 1 is pending")
+		}
+		got, ok := ot.complete(tp("foo", 0, 1))
+		if !ok || got.Offset != 2 {
+			t.Errorf("expected offset 2 got %v", got.Offset)
+		}
+	})
+
+	t.Run("drain waits for the in-flight offsets of the partitions", func(t *testing.T) {
+		ot := newOffsetTracker()
+		ot.track(tp("foo", 0, 1))
+		ot.track(tp("foo", 0, 2))
+		ot.track(tp("foo", 1, 1))
+
+		drained := make(chan struct{})
+		go func() {
+			ot.drain(context.Background(), []kafka.TopicPartition{tp("foo", 0, 0)})
+			close(drained)
+		}()
+		ot.complete(tp("foo", 0, 2))
+		select {
+		case <-drained:
+			t.Fatal("expected drain to wait for offset 1")
+		case <-time.After(20 * time.Millisecond):
+		}
+		// a failed offset is released without being completed
+		ot.fail(tp("foo", 0, 1))
+		select {
+		case <-drained:
+		case <-time.After(time.Second):
+			t.Fatal("expected drain to return once the offsets are released")
+		}
+		if ot.inflight[keyFor(tp("foo", 1, 0))] != 1 {
+			t.Error("expected the other partition to be in-flight")
+		}
+	})
+
+	t.Run("drain returns once ctx is done", func(t *testing.T) {
+		ot := newOffsetTracker()
+		ot.track(tp("foo", 0, 1))
+		ctx, cfn := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cfn()
+		ot.drain(ctx, []kafka.TopicPartition{tp("foo", 0, 0)})
+	})
+
+	t.Run("forgotten partitions start afresh", func(t *testing.T) {
+		ot := newOffsetTracker()
+		ot.track(tp("foo", 0, 1))
+		ot.track(tp("foo", 0, 2))
+		ot.fail(tp("foo", 0, 1))
+		ot.forget([]kafka.TopicPartition{tp("foo", 0, 0)})
+		// the acknowledgement of an offset tracked before the partition was forgotten is ignored
+		if _, ok := ot.complete(tp("foo", 0, 2)); ok {
+			t.Error("expected no offset to store for a forgotten offset")
+		}
+		ot.track(tp("foo", 0, 5))
+		got, ok := ot.complete(tp("foo", 0, 5))
+		if !ok || got.Offset != 5 {
+			t.Errorf("expected offset 5 got %v %v", got.Offset, ok)
+		}
+	})
+
+	t.Run("fail records the lowest failed offset until the partition is forgotten", func(t *testing.T) {
+		ot := newOffsetTracker()
+		for _, o := range []int64{1, 2, 3} {
+			ot.track(tp("foo", 0, o))
+		}
+		ot.fail(tp("foo", 0, 3))
+		ot.fail(tp("foo", 0, 2))
+		// an offset of a forgotten partition is not recorded
+		ot.fail(tp("bar", 0, 7))
+		if diff := cmp.Diff([]kafka.TopicPartition{tp("foo", 0, 2)}, ot.failedPartitions()); diff != "" {
+			t.Errorf("unexpected failed partitions (-want +got):\n%s", diff)
+		}
+		if got := ot.failures.Load(); got != 2 {
+			t.Errorf("expected 2 failures got %d", got)
+		}
+		ot.forget([]kafka.TopicPartition{tp("foo", 0, 0)})
+		if got := ot.failedPartitions(); len(got) != 0 {
+			t.Errorf("expected no failed partitions got %v", got)
+		}
+	})
+
+	t.Run("snapshot counts the pending offsets of every partition", func(t *testing.T) {
+		ot := newOffsetTracker()
+		for _, o := range []int64{1, 2, 3, 4} {
+			ot.track(tp("foo", 0, o))
+		}
+		ot.track(tp("foo", 1, 9))
+		ot.track(tp("bar", 0, 3))
+		ot.fail(tp("foo", 0, 1))
+		ot.complete(tp("foo", 0, 3))
+		ot.complete(tp("bar", 0, 3))
+
+		got := ot.snapshot()
+		sort.Slice(got, func(i, j int) bool { return got[i].Partition < got[j].Partition })
+		want := []PartitionPending{
+			{Topic: "foo", Partition: 0, InFlight: 2, Completed: 1, Failed: 1, Oldest: 1},
+			{Topic: "foo", Partition: 1, InFlight: 1, Oldest: 9},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected pending offsets (-want +got):\n%s", diff)
+		}
+	})
+}
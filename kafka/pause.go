@@ -0,0 +1,156 @@
+package kafka
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// pausedPartitions holds the partitions paused using ConsumerGroup.Pause
+// the lock is held while the consumers are paused or resumed so that
+// the workers and the group do not resume a partition paused by the other
+type pausedPartitions struct {
+	mu         sync.Mutex
+	partitions map[partitionKey]kafka.TopicPartition
+	// resumes changes on every ConsumerGroup.Resume, the workers resume their partitions once they see it change
+	resumes uint64
+}
+
+// filter returns the partitions which are paused if paused is true and the others otherwise,
+// it must be called with the lock held
+func (pp *pausedPartitions) filter(partitions []kafka.TopicPartition, paused bool) []kafka.TopicPartition {
+	var filtered []kafka.TopicPartition
+	for _, tp := range partitions {
+		if _, ok := pp.partitions[keyFor(tp)]; ok == paused {
+			filtered = append(filtered, tp)
+		}
+	}
+	return filtered
+}
+
+// Pause stops fetching the partitions until they are resumed, the workers keep polling to stay in the group
+// the partitions stay paused when they are reassigned within the group
+func (cg *ConsumerGroup) Pause(partitions []kafka.TopicPartition) error {
+	cg.paused.mu.Lock()
+	defer cg.paused.mu.Unlock()
+	if cg.paused.partitions == nil {
+		cg.paused.partitions = map[partitionKey]kafka.TopicPartition{}
+	}
+	for _, tp := range partitions {
+		tp.Offset = kafka.OffsetInvalid
+		cg.paused.partitions[keyFor(tp)] = tp
+	}
+	var errs []error
+	for _, w := range cg.spawned() {
+		// partitions which are not assigned to the worker's consumer are ignored
+		errs = append(errs, w.consumer.Pause(partitions))
+	}
+	return errors.Join(errs...)
+}
+
+// Resume resumes the partitions paused using Pause, every worker resumes its partitions before its next poll
+// partitions of a worker which is applying backpressure are resumed once its in-flight events drop to the low water mark,
+// partitions which are being seeked or rewound are resumed once they are seeked or rewound
+func (cg *ConsumerGroup) Resume(partitions []kafka.TopicPartition) error {
+	cg.paused.mu.Lock()
+	defer cg.paused.mu.Unlock()
+	for _, tp := range partitions {
+		delete(cg.paused.partitions, keyFor(tp))
+	}
+	cg.paused.resumes++
+	return nil
+}
+
+// applyPauses pauses the partitions of the worker once its in-flight events reach the high water mark
+// and resumes them at the low water mark, the pauses are applied again after an assignment
+// and the partitions are resumed after a ConsumerGroup.Resume
+func (w *worker) applyPauses() {
+	if w.paused == nil {
+		return
+	}
+	w.paused.mu.Lock()
+	defer w.paused.mu.Unlock()
+
+	throttled := w.throttled.Load()
+	resumed := w.resumes != w.paused.resumes
+	w.resumes = w.paused.resumes
+	if w.highWaterMark > 0 {
+		n := w.offsets.inFlight()
+		switch {
+		case !throttled && n >= w.highWaterMark:
+			w.logger.Info("kafka worker in-flight events reached the high water mark, pausing", map[string]any{"Worker-ID": w.id, "in-flight": n})
+			w.throttled.Store(true)
+			w.pauseAssignment(nil)
+			return
+		case throttled && n <= w.lowWaterMark:
+			w.logger.Info("kafka worker in-flight events reached the low water mark, resuming", map[string]any{"Worker-ID": w.id, "in-flight": n})
+			w.throttled.Store(false)
+			w.resumeAssignment()
+			return
+		}
+	}
+	if w.assigned {
+		w.pauseAssignment(func(partitions []kafka.TopicPartition) []kafka.TopicPartition {
+			if throttled {
+				return partitions
+			}
+			return w.paused.filter(partitions, true)
+		})
+	}
+	if resumed && !throttled {
+		w.resumeAssignment()
+	}
+}
+
+// pauseAssignment pauses the assigned partitions which pass the filter, a nil filter pauses every partition
+func (w *worker) pauseAssignment(filter func([]kafka.TopicPartition) []kafka.TopicPartition) {
+	w.assigned = false
+	partitions, err := w.consumer.Assignment()
+	if err != nil {
+		w.logger.Error("kafka error fetching the assignment", err, map[string]any{"Worker-ID": w.id})
+		return
+	}
+	if filter != nil {
+		partitions = filter(partitions)
+	}
+	if len(partitions) == 0 {
+		return
+	}
+	w.logger.Error("kafka error pausing partitions", w.consumer.Pause(partitions), map[string]any{"Worker-ID": w.id})
+}
+
+// resumeAssignment resumes the assigned partitions which were not paused using ConsumerGroup.Pause
+func (w *worker) resumeAssignment() {
+	partitions, err := w.consumer.Assignment()
+	if err != nil {
+		w.logger.Error("kafka error fetching the assignment", err, map[string]any{"Worker-ID": w.id})
+		return
+	}
+	// the partitions being seeked or rewound are resumed once they are seeked or rewound
+	partitions = slices.DeleteFunc(partitions, func(tp kafka.TopicPartition) bool {
+		_, ok := w.seeking[keyFor(tp)]
+		return ok || w.redelivery.redelivering(tp)
+	})
+	if partitions = w.paused.filter(partitions, false); len(partitions) == 0 {
+		return
+	}
+	w.logger.Error("kafka error resuming partitions", w.consumer.Resume(partitions), map[string]any{"Worker-ID": w.id})
+}
+
+// resumePartition resumes a partition once it is seeked or rewound, unless it was paused using ConsumerGroup.Pause,
+// the worker is applying backpressure or the partition is still being seeked or rewound
+func (w *worker) resumePartition(tp kafka.TopicPartition) {
+	if w.paused != nil {
+		w.paused.mu.Lock()
+		defer w.paused.mu.Unlock()
+		if len(w.paused.filter([]kafka.TopicPartition{tp}, true)) > 0 {
+			return
+		}
+	}
+	if _, ok := w.seeking[keyFor(tp)]; ok || w.throttled.Load() || w.redelivery.redelivering(tp) {
+		return
+	}
+	w.logger.Error("kafka error resuming partitions", w.consumer.Resume([]kafka.TopicPartition{tp}), map[string]any{"Worker-ID": w.id})
+}
@@ -0,0 +1,223 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+	"github.com/google/go-cmp/cmp"
+)
+
+// pausableConsumer is assigned foo/0 and returns a message on every poll while the partition is not paused
+type pausableConsumer struct {
+	nopConsumer
+	mu     sync.Mutex
+	paused bool
+	polls  int
+	next   int
+}
+
+func (pc *pausableConsumer) Poll(int) kafka.Event {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.polls++
+	if pc.paused {
+		return nil
+	}
+	m := &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: makePtr("foo"), Offset: kafka.Offset(pc.next)}}
+	pc.next++
+	return m
+}
+
+func (pc *pausableConsumer) Assignment() ([]kafka.TopicPartition, error) {
+	return []kafka.TopicPartition{{Topic: makePtr("foo")}}, nil
+}
+
+func (pc *pausableConsumer) Pause(partitions []kafka.TopicPartition) error {
+	return pc.set(partitions, true)
+}
+
+func (pc *pausableConsumer) Resume(partitions []kafka.TopicPartition) error {
+	return pc.set(partitions, false)
+}
+
+func (pc *pausableConsumer) set(partitions []kafka.TopicPartition, paused bool) error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	for _, tp := range partitions {
+		if *tp.Topic != "foo" || tp.Partition != 0 {
+			return errors.New("unknown partition")
+		}
+	}
+	pc.paused = paused
+	return nil
+}
+
+func (pc *pausableConsumer) state() (paused bool, polls int) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return pc.paused, pc.polls
+}
+
+func consumeWith(t *testing.T, cg *ConsumerGroup, pc *pausableConsumer, h ziggurat.Handler) (context.CancelFunc, chan error) {
+	t.Helper()
+	cg.GroupConfig.BootstrapServers = "localhost:9092"
+	cg.GroupConfig.GroupID = "pause-test"
+	cg.GroupConfig.Topics = []string{"foo"}
+	cg.GroupConfig.ConsumerCount = 1
+	cg.GroupConfig.PollTimeout = 1
+	cg.consumerMakeFunc = func(*kafka.ConfigMap, []string, kafka.RebalanceCb) confluentConsumer {
+		return pc
+	}
+	ctx, cfn := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- cg.Consume(ctx, h)
+	}()
+	return cfn, done
+}
+
+func eventually(t *testing.T, msg string, f func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConsumerGroup_Backpressure(t *testing.T) {
+	acks := make(chan ziggurat.AckFunc, 100)
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		ack, _ := ziggurat.DeferAck(ctx)
+		acks <- ack
+	})
+	pc := &pausableConsumer{}
+	cg := &ConsumerGroup{GroupConfig: ConsumerConfig{InFlightHighWaterMark: 4, InFlightLowWaterMark: 1}}
+	cfn, done := consumeWith(t, cg, pc, h)
+
+	eventually(t, "expected the partition to be paused at the high water mark", func() bool {
+		paused, _ := pc.state()
+		return paused
+	})
+	if len(acks) != 4 {
+		t.Errorf("expected 4 in-flight events got %d", len(acks))
+	}
+	_, polls := pc.state()
+	eventually(t, "expected the worker to keep polling while paused", func() bool {
+		_, n := pc.state()
+		return n > polls+5
+	})
+
+	// 2 in-flight events are above the low water mark
+	(<-acks)(nil)
+	(<-acks)(nil)
+	time.Sleep(10 * time.Millisecond)
+	if paused, _ := pc.state(); !paused {
+		t.Error("expected the partition to stay paused above the low water mark")
+	}
+	(<-acks)(nil)
+	eventually(t, "expected the partition to be resumed at the low water mark", func() bool {
+		return len(acks) == 4
+	})
+
+	cfn()
+	for {
+		select {
+		case ack := <-acks:
+			ack(nil)
+		case err := <-done:
+			if !errors.Is(err, ErrCleanShutdown) {
+				t.Errorf("expected a clean shutdown got %v", err)
+			}
+			return
+		}
+	}
+}
+
+func TestConsumerGroup_PauseResume(t *testing.T) {
+	var mu sync.Mutex
+	var handled int
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		mu.Lock()
+		handled++
+		mu.Unlock()
+	})
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled
+	}
+	pc := &pausableConsumer{}
+	cg := &ConsumerGroup{}
+	foo0 := []kafka.TopicPartition{{Topic: makePtr("foo")}}
+	cfn, done := consumeWith(t, cg, pc, h)
+	defer func() {
+		cfn()
+		<-done
+	}()
+
+	eventually(t, "expected events to be handled", func() bool { return count() > 0 })
+	if err := cg.Pause(foo0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	n := count()
+	time.Sleep(20 * time.Millisecond)
+	if count() != n {
+		t.Error("expected no events while the partition is paused")
+	}
+	if err := cg.Resume(foo0); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "expected events once the partition is resumed", func() bool { return count() > n })
+}
+
+func TestWorker_PausesAfterAssignment(t *testing.T) {
+	foo0 := kafka.TopicPartition{Topic: makePtr("foo")}
+	pc := &pausableConsumer{}
+	pp := &pausedPartitions{partitions: map[partitionKey]kafka.TopicPartition{keyFor(foo0): foo0}}
+	w := &worker{consumer: pc, logger: logger.NOOP, offsets: newOffsetTracker(), paused: pp}
+
+	cg := &ConsumerGroup{Logger: logger.NOOP}
+	cg.handleRebalance(context.Background(), &fakeRebalanceConsumer{}, w, kafka.AssignedPartitions{Partitions: []kafka.TopicPartition{foo0}})
+	w.applyPauses()
+	if paused, _ := pc.state(); !paused {
+		t.Error("expected the paused partition to be paused again once it is assigned")
+	}
+}
+
+func TestConsumerGroup_ResumeKeepsSeekingPartitionsPaused(t *testing.T) {
+	cg := &ConsumerGroup{}
+	sc := &seekConsumer{}
+	w := newSeekWorker(sc, cg)
+	cg.workers = []*worker{w}
+	foo0 := kafka.TopicPartition{Topic: makePtr("foo")}
+	w.offsets.track(kafka.TopicPartition{Topic: makePtr("foo"), Offset: 3})
+
+	if _, err := cg.Seek(SeekTarget{Topic: "foo", Partition: 0, Offset: 7}); err != nil {
+		t.Fatal(err)
+	}
+	w.applySeeks()
+	if err := cg.Pause([]kafka.TopicPartition{foo0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cg.Resume([]kafka.TopicPartition{foo0}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"pause foo[0]@unset", "pause foo[0]@0"}, sc.recorded()); diff != "" {
+		t.Errorf("expected the partitions to be resumed by the worker (-want +got):\n%s", diff)
+	}
+	w.applyPauses()
+	want := []string{"pause foo[0]@unset", "pause foo[0]@0", "resume foo[1]@0"}
+	if diff := cmp.Diff(want, sc.recorded()); diff != "" {
+		t.Errorf("expected the partition being seeked to stay paused (-want +got):\n%s", diff)
+	}
+}
@@ -17,26 +17,106 @@ func constructPath(rg string, topic string, part int32) string {
 	return fmt.Sprintf("%s/%s/%d", rg, topic, part)
 }
 
-func processMessage(ctx context.Context, msg *kafka.Message, h ziggurat.Handler, route string) {
-	//copy kvs into new slices
-	key := make([]byte, len(msg.Key))
-	value := make([]byte, len(msg.Value))
-
-	copy(key, msg.Key)
-	copy(value, msg.Value)
-
-	event := ziggurat.Event{
-		Value: value,
-		Key:   key,
-		Metadata: map[string]interface{}{
-			"kafka-topic":     *msg.TopicPartition.Topic,
-			"kafka-partition": int(msg.TopicPartition.Partition),
-		},
-		RoutingPath:       constructPath(route, *msg.TopicPartition.Topic, msg.TopicPartition.Partition),
-		ProducerTimestamp: msg.Timestamp,
-		ReceivedTimestamp: time.Now(),
-		EventType:         EventType,
+// route holds the per partition values of an event
+// they are computed once per partition instead of once per message
+type route struct {
+	path      string
+	topic     any
+	partition any
+}
+
+// message is a pooled event along with its acknowledgement
+// it is put back into the pool once the event is acknowledged
+type message struct {
+	event ziggurat.Event
+	ack   ziggurat.Ack
+	tp    kafka.TopicPartition
+	done  ziggurat.AckFunc
+	// headers holds the kafka-headers metadata, it is reused by the events which carry headers
+	headers map[string]string
+	// stored is handed to StoreOffsets so that storing the offset of the message does not allocate
+	stored [1]kafka.TopicPartition
+}
+
+func (m *message) reset() {
+	metadata := m.event.Metadata
+	if metadata == nil {
+		metadata = make(map[string]any, 4)
+	}
+	clear(metadata)
+	m.event = ziggurat.Event{Metadata: metadata}
+	m.tp = kafka.TopicPartition{}
+}
+
+func (w *worker) routeFor(tp kafka.TopicPartition) *route {
+	k := keyFor(tp)
+	if r, ok := w.routes[k]; ok {
+		return r
+	}
+	r := &route{
+		path:      constructPath(w.routeGroup, k.topic, k.partition),
+		topic:     k.topic,
+		partition: int(k.partition),
+	}
+	w.routes[k] = r
+	return r
+}
+
+// processMessage hands the message over to the handler
+// the event is owned by the worker and is reused once it is acknowledged
+func (w *worker) processMessage(ctx context.Context, msg *kafka.Message) {
+	m := w.messages.Get().(*message)
+	m.tp = msg.TopicPartition
+	w.fillEvent(m, msg)
+
+	w.inflight.Add(1)
+	w.offsets.track(m.tp)
+	actx := m.ack.Reset(ctx, m.done)
+	w.handler.Handle(actx, &m.event)
+	m.ack.Release()
+}
+
+// fillEvent sets the fields and the kafka metadata of the event from the message
+func (w *worker) fillEvent(m *message, msg *kafka.Message) {
+	e := &m.event
+	r := w.routeFor(msg.TopicPartition)
+	// the key and value are allocated by the confluent client for every message
+	// they are not reused and can be retained by handlers
+	e.Key = msg.Key
+	if e.Key == nil {
+		e.Key = []byte{}
+	}
+	e.Value = msg.Value
+	if e.Value == nil {
+		e.Value = []byte{}
 	}
-	h.Handle(ctx, &event)
+	e.Metadata["kafka-topic"] = r.topic
+	e.Metadata["kafka-partition"] = r.partition
+	e.Metadata["kafka-offset"] = int64(msg.TopicPartition.Offset)
+	if len(msg.Headers) > 0 {
+		if m.headers == nil {
+			m.headers = make(map[string]string, len(msg.Headers))
+		}
+		clear(m.headers)
+		fillHeaders(m.headers, msg.Headers)
+		e.Metadata[KeyHeaders] = m.headers
+	}
+	e.RoutingPath = r.path
+	e.ProducerTimestamp = msg.Timestamp
+	e.ReceivedTimestamp = time.Now()
+	e.EventType = EventType
+}
 
+// headersToMap converts the kafka message headers to a map,
+// if a header key is repeated the last value wins
+func headersToMap(headers []kafka.Header) map[string]string {
+	m := make(map[string]string, len(headers))
+	fillHeaders(m, headers)
+	return m
+}
+
+func fillHeaders(m map[string]string, headers []kafka.Header) {
+	for _, h := range headers {
+		m[h.Key] = string(h.Value)
+	}
 }
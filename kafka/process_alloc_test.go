@@ -0,0 +1,41 @@
+//go:build !race
+
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+)
+
+// the race detector drops the events put back into the pool, they are allocated again
+func TestProcessMessage_Allocs(t *testing.T) {
+	w := newBenchWorker(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {}))
+	topic := "foo"
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 300},
+		Key:            []byte("key"),
+		Value:          []byte("value"),
+	}
+	ctx := context.Background()
+	allocs := testing.AllocsPerRun(1000, func() {
+		msg.TopicPartition.Offset++
+		w.processMessage(ctx, msg)
+	})
+	// the offset is boxed into the metadata
+	if allocs > 1 {
+		t.Errorf("expected at most 1 allocation per message got %v", allocs)
+	}
+
+	// the headers map is reused, only the header values are copied
+	msg.Headers = []kafka.Header{{Key: "traceparent", Value: []byte("00-foo")}, {Key: "tenant", Value: []byte("acme")}}
+	allocs = testing.AllocsPerRun(1000, func() {
+		msg.TopicPartition.Offset++
+		w.processMessage(ctx, msg)
+	})
+	if allocs > 3 {
+		t.Errorf("expected at most 3 allocations per message with 2 headers got %v", allocs)
+	}
+}
@@ -0,0 +1,135 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+)
+
+// nopConsumer is a confluentConsumer which does not allocate
+type nopConsumer struct{}
+
+func (nopConsumer) Poll(int) kafka.Event { return nil }
+func (nopConsumer) StoreOffsets(tps []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	return tps, nil
+}
+func (nopConsumer) Logs() chan kafka.LogEvent               { return nil }
+func (nopConsumer) Commit() ([]kafka.TopicPartition, error) { return nil, nil }
+func (nopConsumer) Close() error                            { return nil }
+func (nopConsumer) Seek(kafka.TopicPartition, int) error    { return nil }
+func (nopConsumer) GetConsumerGroupMetadata() (*kafka.ConsumerGroupMetadata, error) {
+	return nil, nil
+}
+func (nopConsumer) Pause([]kafka.TopicPartition) error               { return nil }
+func (nopConsumer) Resume([]kafka.TopicPartition) error              { return nil }
+func (nopConsumer) Assignment() ([]kafka.TopicPartition, error)      { return nil, nil }
+func (nopConsumer) SetOAuthBearerToken(kafka.OAuthBearerToken) error { return nil }
+func (nopConsumer) SetOAuthBearerTokenFailure(string) error          { return nil }
+func (nopConsumer) QueryWatermarkOffsets(string, int32, int) (int64, int64, error) {
+	return 0, 0, nil
+}
+func (nopConsumer) OffsetsForTimes(tps []kafka.TopicPartition, _ int) ([]kafka.TopicPartition, error) {
+	return tps, nil
+}
+func (nopConsumer) GetMetadata(*string, bool, int) (*kafka.Metadata, error) {
+	return &kafka.Metadata{}, nil
+}
+
+func newBenchWorker(h ziggurat.Handler) *worker {
+	w := &worker{
+		handler:    h,
+		logger:     logger.NOOP,
+		consumer:   nopConsumer{},
+		routeGroup: "foo-group",
+		offsets:    newOffsetTracker(),
+	}
+	w.init()
+	return w
+}
+
+func TestProcessMessage_Event(t *testing.T) {
+	var got *ziggurat.Event
+	w := newBenchWorker(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		got = event.Clone()
+	}))
+	topic := "foo"
+	w.processMessage(context.Background(), &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 2, Offset: 42},
+		Value:          []byte("bar"),
+		Headers:        []kafka.Header{{Key: "traceparent", Value: []byte("00-foo")}},
+	})
+	if got.RoutingPath != "foo-group/foo/2" {
+		t.Errorf("expected routing path foo-group/foo/2 got %s", got.RoutingPath)
+	}
+	if got.Metadata["kafka-topic"] != "foo" || got.Metadata["kafka-partition"] != 2 || got.Metadata["kafka-offset"] != int64(42) {
+		t.Errorf("unexpected metadata %v", got.Metadata)
+	}
+	if h, _ := got.Metadata["kafka-headers"].(map[string]string); h["traceparent"] != "00-foo" {
+		t.Errorf("expected the traceparent header got %v", got.Metadata["kafka-headers"])
+	}
+	if string(got.Value) != "bar" || got.Key == nil {
+		t.Errorf("unexpected key value %q %q", got.Key, got.Value)
+	}
+}
+
+func TestProcessMessage_ClonedHeaders(t *testing.T) {
+	var clones []*ziggurat.Event
+	w := newBenchWorker(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		clones = append(clones, event.Clone())
+	}))
+	for _, v := range []string{"foo", "bar"} {
+		w.processMessage(context.Background(), &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: makePtr("foo")},
+			Headers:        []kafka.Header{{Key: "tenant", Value: []byte(v)}},
+		})
+	}
+	// the headers map of the pooled event is reused, a clone keeps the headers of its event
+	for i, want := range []string{"foo", "bar"} {
+		if got := clones[i].Metadata[KeyHeaders].(map[string]string)["tenant"]; got != want {
+			t.Errorf("expected the clone of event %d to have tenant %s got %s", i, want, got)
+		}
+	}
+}
+
+func TestProcessMessage_StaleDeferredAck(t *testing.T) {
+	var first ziggurat.AckFunc
+	var w *worker
+	w = newBenchWorker(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		ack, _ := ziggurat.DeferAck(ctx)
+		if first == nil {
+			first = ack
+			ack(nil)
+			return
+		}
+		// the event and the ack of the first message are reused, calling its AckFunc again
+		// must not acknowledge the second message before it is processed
+		first(nil)
+		if n := w.offsets.partitionInFlight(kafka.TopicPartition{Topic: makePtr("foo")}); n != 1 {
+			t.Errorf("expected the second message to be in-flight got %d in-flight messages", n)
+		}
+		ack(nil)
+	}))
+	for _, offset := range []kafka.Offset{1, 2} {
+		w.processMessage(context.Background(), &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: makePtr("foo"), Offset: offset}})
+	}
+}
+
+func BenchmarkProcessMessage(b *testing.B) {
+	w := newBenchWorker(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {}))
+	topic := "foo"
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1},
+		Key:            []byte("key"),
+		Value:          []byte("value"),
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		msg.TopicPartition.Offset = kafka.Offset(i)
+		w.processMessage(ctx, msg)
+	}
+}
@@ -0,0 +1,305 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+)
+
+// KeyHeaders is the metadata key which holds the message headers as a map[string]string
+// the consumer sets it for every message and the producer sends it as the message headers
+const KeyHeaders = "kafka-headers"
+
+var ErrProducerClosed = errors.New("kafka: producer closed")
+
+type ProducerConfig struct {
+	BootstrapServers string
+	ClientID         string
+	DebugLevel       string
+	// Acks defaults to all
+	Acks string
+	// EnableIdempotence ensures that messages are written exactly once and in order per partition
+	EnableIdempotence bool
+	LingerMS          int
+	CompressionType   string
+	// MessageTimeoutMS bounds the time a message can take to be delivered, including retries
+	MessageTimeoutMS int
+	// FlushTimeout is the time Close waits for the outstanding messages to be delivered, it defaults to 10s
+	FlushTimeout time.Duration
+	// TransactionalID is required by NewTransactionalProducer and must be unique per producer instance
+	TransactionalID string
+	// SASL and SSL set the security.protocol to SASL_PLAINTEXT, SSL or SASL_SSL
+	SASL *SASLConfig
+	SSL  *SSLConfig
+	// Overrides are librdkafka producer properties which are applied last,
+	// the transactional producers of a ConsumerGroup use the Overrides of its ConsumerConfig
+	Overrides kafka.ConfigMap
+}
+
+func (c ProducerConfig) toConfigMap() kafka.ConfigMap {
+	cm := kafka.ConfigMap{
+		"bootstrap.servers":      c.BootstrapServers,
+		"acks":                   "all",
+		"go.logs.channel.enable": true,
+	}
+	if c.ClientID != "" {
+		cm["client.id"] = c.ClientID
+	}
+	if c.DebugLevel != "" {
+		cm["debug"] = c.DebugLevel
+	}
+	if c.Acks != "" {
+		cm["acks"] = c.Acks
+	}
+	if c.EnableIdempotence {
+		cm["enable.idempotence"] = true
+	}
+	if c.LingerMS > 0 {
+		cm["linger.ms"] = c.LingerMS
+	}
+	if c.CompressionType != "" {
+		cm["compression.type"] = c.CompressionType
+	}
+	if c.MessageTimeoutMS > 0 {
+		cm["message.timeout.ms"] = c.MessageTimeoutMS
+	}
+	if c.TransactionalID != "" {
+		cm["transactional.id"] = c.TransactionalID
+	}
+	setSecurity(cm, c.SASL, c.SSL)
+	for k, v := range c.Overrides {
+		cm[k] = v
+	}
+	return cm
+}
+
+// DeliveryFunc is invoked with the delivery report of a message, the partition holds the offset of the message
+type DeliveryFunc func(tp kafka.TopicPartition, err error)
+
+// HeaderInjector adds headers to every message, for example to propagate the trace context
+type HeaderInjector func(ctx context.Context, headers map[string]string)
+
+type ProducerOpts func(p *Producer)
+
+func WithProducerLogger(l ziggurat.StructuredLogger) ProducerOpts {
+	return func(p *Producer) {
+		p.logger = l
+	}
+}
+
+// WithMetadataHeader sends the value of the metadata key as the header
+// string and []byte values are sent as is and other values are formatted using fmt
+func WithMetadataHeader(metadataKey, header string) ProducerOpts {
+	return func(p *Producer) {
+		p.metadataHeaders[metadataKey] = header
+	}
+}
+
+func WithProducerHeaderInjector(f HeaderInjector) ProducerOpts {
+	return func(p *Producer) {
+		p.injectHeaders = f
+	}
+}
+
+// WithProducerTimestamp sends the ProducerTimestamp of the event as the timestamp of the message,
+// the messages are otherwise timestamped when they are produced. A forwarded event keeps the timestamp
+// of the message it was consumed from, which can make the message expire early under the retention of the topic
+func WithProducerTimestamp() ProducerOpts {
+	return func(p *Producer) {
+		p.keepTimestamps = true
+	}
+}
+
+// WithTokenProvider sets the OAUTHBEARER tokens of the producer
+func WithTokenProvider(tp TokenProvider) ProducerOpts {
+	return func(p *Producer) {
+		p.tokens = tp
+	}
+}
+
+// Producer sends ziggurat events to kafka
+// the key and value of the event are sent as is,
+// the headers are taken from the KeyHeaders metadata and the metadata mapped using WithMetadataHeader
+//
+// Close flushes the outstanding messages, the producer can be added to the Ziggurat.Closers
+// so that it is closed once the consumers return
+type Producer struct {
+	p               *kafka.Producer
+	config          ProducerConfig
+	logger          ziggurat.StructuredLogger
+	metadataHeaders map[string]string
+	injectHeaders   HeaderInjector
+	tokens          TokenProvider
+	keepTimestamps  bool
+	mu              sync.RWMutex
+	closed          bool
+	done            chan struct{}
+}
+
+func NewProducer(config ProducerConfig, opts ...ProducerOpts) (*Producer, error) {
+	if err := validateSecurity(config.SASL, config.SSL); err != nil {
+		return nil, err
+	}
+	if config.FlushTimeout <= 0 {
+		config.FlushTimeout = 10 * time.Second
+	}
+	pr := &Producer{
+		config:          config,
+		logger:          logger.NOOP,
+		metadataHeaders: map[string]string{},
+		done:            make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(pr)
+	}
+
+	cm := config.toConfigMap()
+	p, err := kafka.NewProducer(&cm)
+	if err != nil {
+		return nil, fmt.Errorf("kafka: error creating producer: %w", err)
+	}
+	pr.p = p
+
+	go pr.logs()
+	go pr.deliveryReports()
+	return pr, nil
+}
+
+func (pr *Producer) logs() {
+	for evt := range pr.p.Logs() {
+		pr.logger.Info(evt.Message, map[string]any{"client": evt.Name, "lvl": evt.Level})
+	}
+}
+
+// deliveryReports invokes the DeliveryFunc stored as the opaque of every message
+func (pr *Producer) deliveryReports() {
+	defer close(pr.done)
+	for e := range pr.p.Events() {
+		switch ev := e.(type) {
+		case *kafka.Message:
+			if f, ok := ev.Opaque.(DeliveryFunc); ok && f != nil {
+				f(ev.TopicPartition, ev.TopicPartition.Error)
+				continue
+			}
+			pr.logger.Error("kafka delivery failed", ev.TopicPartition.Error, map[string]any{"topic": topicName(ev.TopicPartition)})
+		case kafka.Error:
+			pr.logger.Error("kafka producer error", ev)
+		case kafka.OAuthBearerTokenRefresh:
+			pr.logger.Error("kafka oauthbearer token refresh error", refreshToken(context.Background(), pr.p, pr.tokens))
+		}
+	}
+}
+
+func topicName(tp kafka.TopicPartition) string {
+	if tp.Topic == nil {
+		return ""
+	}
+	return *tp.Topic
+}
+
+func (pr *Producer) message(ctx context.Context, topic string, e *ziggurat.Event) *kafka.Message {
+	headers := map[string]string{}
+	if h, ok := e.Metadata[KeyHeaders].(map[string]string); ok {
+		for k, v := range h {
+			headers[k] = v
+		}
+	}
+	for mk, header := range pr.metadataHeaders {
+		v, ok := e.Metadata[mk]
+		if !ok {
+			continue
+		}
+		switch val := v.(type) {
+		case string:
+			headers[header] = val
+		case []byte:
+			headers[header] = string(val)
+		default:
+			headers[header] = fmt.Sprint(val)
+		}
+	}
+	if pr.injectHeaders != nil {
+		pr.injectHeaders(ctx, headers)
+	}
+
+	m := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            e.Key,
+		Value:          e.Value,
+	}
+	if pr.keepTimestamps {
+		m.Timestamp = e.ProducerTimestamp
+	}
+	for k, v := range headers {
+		m.Headers = append(m.Headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	return m
+}
+
+// SendAsync enqueues the event, f is invoked with the delivery report from the producer's event loop
+// and must not block, a nil f logs failed deliveries
+func (pr *Producer) SendAsync(ctx context.Context, topic string, e *ziggurat.Event, f DeliveryFunc) error {
+	m := pr.message(ctx, topic, e)
+	if f != nil {
+		m.Opaque = f
+	}
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+	if pr.closed {
+		return ErrProducerClosed
+	}
+	return pr.p.Produce(m, nil)
+}
+
+// Send enqueues the event and waits for its delivery report or for ctx to be done
+// a message is still delivered when ctx is done before the report arrives
+func (pr *Producer) Send(ctx context.Context, topic string, e *ziggurat.Event) (kafka.TopicPartition, error) {
+	type report struct {
+		tp  kafka.TopicPartition
+		err error
+	}
+	ch := make(chan report, 1)
+	err := pr.SendAsync(ctx, topic, e, func(tp kafka.TopicPartition, err error) {
+		ch <- report{tp: tp, err: err}
+	})
+	if err != nil {
+		return kafka.TopicPartition{}, err
+	}
+	select {
+	case r := <-ch:
+		return r.tp, r.err
+	case <-ctx.Done():
+		return kafka.TopicPartition{}, ctx.Err()
+	}
+}
+
+// Flush waits for the outstanding messages to be delivered, it returns the number of messages which were not
+func (pr *Producer) Flush(timeout time.Duration) int {
+	return pr.p.Flush(int(timeout.Milliseconds()))
+}
+
+// Close flushes the outstanding messages for up to FlushTimeout and closes the producer
+// the delivery reports of the flushed messages are invoked before Close returns
+func (pr *Producer) Close() error {
+	pr.mu.Lock()
+	if pr.closed {
+		pr.mu.Unlock()
+		return nil
+	}
+	pr.closed = true
+	pr.mu.Unlock()
+
+	remaining := pr.Flush(pr.config.FlushTimeout)
+	pr.p.Close()
+	<-pr.done
+	if remaining > 0 {
+		return fmt.Errorf("kafka: %d messages were not delivered before the producer was closed", remaining)
+	}
+	return nil
+}
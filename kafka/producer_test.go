@@ -0,0 +1,190 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+)
+
+func newMockCluster(t *testing.T) *kafka.MockCluster {
+	t.Helper()
+	mc, err := kafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mc.Close)
+	return mc
+}
+
+func readMessages(t *testing.T, bootstrap, topic string, n int) []*kafka.Message {
+	t.Helper()
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers": bootstrap,
+		"group.id":          "producer-test",
+		"auto.offset.reset": "earliest",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Subscribe(topic, nil); err != nil {
+		t.Fatal(err)
+	}
+	var msgs []*kafka.Message
+	deadline := time.Now().Add(10 * time.Second)
+	for len(msgs) < n && time.Now().Before(deadline) {
+		m, err := c.ReadMessage(100 * time.Millisecond)
+		if err != nil {
+			continue
+		}
+		msgs = append(msgs, m)
+	}
+	if len(msgs) != n {
+		t.Fatalf("expected %d messages got %d", n, len(msgs))
+	}
+	return msgs
+}
+
+func headerMap(headers []kafka.Header) map[string]string {
+	m := map[string]string{}
+	for _, h := range headers {
+		m[h.Key] = string(h.Value)
+	}
+	return m
+}
+
+func TestProducer_Send(t *testing.T) {
+	mc := newMockCluster(t)
+	p, err := NewProducer(ProducerConfig{BootstrapServers: mc.BootstrapServers()},
+		WithMetadataHeader("tenant", "x-tenant"),
+		WithMetadataHeader("attempt", "x-attempt"),
+		WithProducerHeaderInjector(func(ctx context.Context, headers map[string]string) {
+			headers["traceparent"] = "00-foo"
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ts := time.UnixMilli(1700000000000)
+	event := &ziggurat.Event{
+		Key:               []byte("k1"),
+		Value:             []byte("foo"),
+		ProducerTimestamp: ts,
+		Metadata: map[string]any{
+			KeyHeaders: map[string]string{"source": "web"},
+			"tenant":   "acme",
+			"attempt":  2,
+		},
+	}
+	sent := time.Now().Truncate(time.Millisecond)
+	tp, err := p.Send(context.Background(), "orders", event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *tp.Topic != "orders" || tp.Offset != 0 {
+		t.Errorf("unexpected delivery report %v", tp)
+	}
+
+	m := readMessages(t, mc.BootstrapServers(), "orders", 1)[0]
+	if string(m.Key) != "k1" || string(m.Value) != "foo" {
+		t.Errorf("expected key k1 and value foo got %q %q", m.Key, m.Value)
+	}
+	if m.Timestamp.Before(sent) {
+		t.Errorf("expected the message to be timestamped when it is produced got %v", m.Timestamp)
+	}
+	want := map[string]string{"source": "web", "x-tenant": "acme", "x-attempt": "2", "traceparent": "00-foo"}
+	got := headerMap(m.Headers)
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("expected header %s=%s got %v", k, v, got)
+		}
+	}
+}
+
+func TestProducer_SendAsyncAndClose(t *testing.T) {
+	mc := newMockCluster(t)
+	p, err := NewProducer(ProducerConfig{BootstrapServers: mc.BootstrapServers(), LingerMS: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reports := make(chan kafka.TopicPartition, 10)
+	for i := 0; i < 10; i++ {
+		err := p.SendAsync(context.Background(), "orders", &ziggurat.Event{Value: []byte("foo")},
+			func(tp kafka.TopicPartition, err error) {
+				if err != nil {
+					t.Errorf("unexpected delivery error %v", err)
+				}
+				reports <- tp
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the outstanding messages are flushed and reported before Close returns
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 10 {
+		t.Errorf("expected 10 delivery reports got %d", len(reports))
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("expected a second Close to be a no-op got %v", err)
+	}
+	_, err = p.Send(context.Background(), "orders", &ziggurat.Event{})
+	if !errors.Is(err, ErrProducerClosed) {
+		t.Errorf("expected %v got %v", ErrProducerClosed, err)
+	}
+}
+
+func TestProducer_WithProducerTimestamp(t *testing.T) {
+	mc := newMockCluster(t)
+	p, err := NewProducer(ProducerConfig{BootstrapServers: mc.BootstrapServers()}, WithProducerTimestamp())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ts := time.UnixMilli(1700000000000)
+	if _, err := p.Send(context.Background(), "orders", &ziggurat.Event{Value: []byte("foo"), ProducerTimestamp: ts}); err != nil {
+		t.Fatal(err)
+	}
+	if m := readMessages(t, mc.BootstrapServers(), "orders", 1)[0]; !m.Timestamp.Equal(ts) {
+		t.Errorf("expected timestamp %v got %v", ts, m.Timestamp)
+	}
+}
+
+func TestProducer_DeliveryFailure(t *testing.T) {
+	mc, err := kafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProducer(ProducerConfig{BootstrapServers: mc.BootstrapServers(), MessageTimeoutMS: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// the broker is gone before the message is sent
+	mc.Close()
+	_, err = p.Send(context.Background(), "orders", &ziggurat.Event{Value: []byte("foo")})
+	if err == nil {
+		t.Errorf("expected a delivery error")
+	}
+}
+
+func TestNewProducer_InvalidSecurity(t *testing.T) {
+	_, err := NewProducer(ProducerConfig{
+		BootstrapServers: "localhost:9092",
+		SASL:             &SASLConfig{Mechanism: SASLMechanismScramSHA512, Username: "user"},
+	})
+	if err == nil {
+		t.Error("expected an error for SASL without a password")
+	}
+}
@@ -0,0 +1,70 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// RebalanceHook is invoked from the poll loop of a worker during a rebalance,
+// the rebalance does not complete until the hook returns
+type RebalanceHook func(ctx context.Context, partitions []kafka.TopicPartition)
+
+// rebalanceConsumer is the part of the consumer used while handling a rebalance
+type rebalanceConsumer interface {
+	Commit() ([]kafka.TopicPartition, error)
+	AssignmentLost() bool
+}
+
+func (cg *ConsumerGroup) rebalanceCb(ctx context.Context, w *worker) kafka.RebalanceCb {
+	return func(c *kafka.Consumer, ev kafka.Event) error {
+		cg.handleRebalance(ctx, c, w, ev)
+		return nil
+	}
+}
+
+// handleRebalance drains the in-flight events of the revoked partitions and commits their stored offsets
+// before the partitions are released, the offsets of lost partitions are neither drained nor committed as
+// they are already assigned to another consumer. The drain blocks the poll loop, it is bounded by half of
+// max.poll.interval.ms so that an event which is never acknowledged does not stall the rebalance of the group
+func (cg *ConsumerGroup) handleRebalance(ctx context.Context, c rebalanceConsumer, w *worker, ev kafka.Event) {
+	offsets := w.offsets
+	switch e := ev.(type) {
+	case kafka.AssignedPartitions:
+		cg.Logger.Info("kafka partitions assigned", map[string]any{"partitions": e.Partitions})
+		// the partitions are assigned once the callback returns, the worker pauses them before its next poll
+		w.assigned = true
+		w.seeksAssigned = true
+		if cg.OnAssigned != nil {
+			cg.OnAssigned(ctx, e.Partitions)
+		}
+	case kafka.RevokedPartitions:
+		lost := c.AssignmentLost()
+		if !lost {
+			dctx, cancel := context.WithTimeout(ctx, cg.GroupConfig.ackTimeout())
+			if err := offsets.drain(dctx, e.Partitions); errors.Is(err, context.DeadlineExceeded) {
+				cg.Logger.Warn("kafka revoking partitions with unacknowledged events", map[string]any{"partitions": e.Partitions})
+			}
+			cancel()
+		}
+		offsets.forget(e.Partitions)
+		// the targets which were not applied are applied by the next owner of the partitions
+		w.releaseSeeks(e.Partitions)
+		w.redelivery.drop(e.Partitions)
+		if lost {
+			cg.Logger.Warn("kafka partitions lost", map[string]any{"partitions": e.Partitions})
+			if cg.OnLost != nil {
+				cg.OnLost(ctx, e.Partitions)
+			}
+			return
+		}
+		if _, err := c.Commit(); err != nil && !isNoOffset(err) {
+			cg.Logger.Error("kafka commit on revoke error", err, map[string]any{"partitions": e.Partitions})
+		}
+		cg.Logger.Info("kafka partitions revoked", map[string]any{"partitions": e.Partitions})
+		if cg.OnRevoked != nil {
+			cg.OnRevoked(ctx, e.Partitions)
+		}
+	}
+}
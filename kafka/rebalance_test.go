@@ -0,0 +1,157 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+)
+
+type fakeRebalanceConsumer struct {
+	lost    bool
+	commits int
+}
+
+func (f *fakeRebalanceConsumer) Commit() ([]kafka.TopicPartition, error) {
+	f.commits++
+	return nil, nil
+}
+
+func (f *fakeRebalanceConsumer) AssignmentLost() bool {
+	return f.lost
+}
+
+func TestConsumerGroup_HandleRebalance(t *testing.T) {
+	foo0 := kafka.TopicPartition{Topic: makePtr("foo"), Partition: 0}
+	var calls []string
+	hook := func(name string) RebalanceHook {
+		return func(ctx context.Context, partitions []kafka.TopicPartition) {
+			calls = append(calls, name)
+		}
+	}
+	cg := &ConsumerGroup{
+		Logger:     logger.NOOP,
+		OnAssigned: hook("assigned"),
+		OnRevoked:  hook("revoked"),
+		OnLost:     hook("lost"),
+	}
+
+	t.Run("revoked partitions are drained and committed", func(t *testing.T) {
+		calls = nil
+		offsets := newOffsetTracker()
+		inflight := foo0
+		inflight.Offset = 7
+		offsets.track(inflight)
+
+		c := &fakeRebalanceConsumer{}
+		done := make(chan struct{})
+		go func() {
+			cg.handleRebalance(context.Background(), c, &worker{offsets: offsets}, kafka.RevokedPartitions{Partitions: []kafka.TopicPartition{foo0}})
+			close(done)
+		}()
+		select {
+		case <-done:
+			t.Fatal("expected the revoke to wait for the in-flight event")
+		case <-time.After(20 * time.Millisecond):
+		}
+		offsets.complete(inflight)
+		<-done
+		if c.commits != 1 || len(calls) != 1 || calls[0] != "revoked" {
+			t.Errorf("expected a commit and the revoked hook got %d commits and %v", c.commits, calls)
+		}
+	})
+
+	t.Run("the drain of revoked partitions is bounded", func(t *testing.T) {
+		calls = nil
+		offsets := newOffsetTracker()
+		inflight := foo0
+		inflight.Offset = 7
+		offsets.track(inflight)
+
+		// the drain is bounded by half of max.poll.interval.ms
+		bounded := &ConsumerGroup{Logger: logger.NOOP, OnRevoked: hook("revoked"), GroupConfig: ConsumerConfig{MaxPollIntervalMS: 100}}
+		c := &fakeRebalanceConsumer{}
+		start := time.Now()
+		bounded.handleRebalance(context.Background(), c, &worker{offsets: offsets}, kafka.RevokedPartitions{Partitions: []kafka.TopicPartition{foo0}})
+		if d := time.Since(start); d < 50*time.Millisecond {
+			t.Errorf("expected the revoke to wait for the in-flight event got %v", d)
+		}
+		if c.commits != 1 || len(calls) != 1 || calls[0] != "revoked" {
+			t.Errorf("expected a commit and the revoked hook got %d commits and %v", c.commits, calls)
+		}
+	})
+
+	t.Run("lost partitions are neither drained nor committed", func(t *testing.T) {
+		calls = nil
+		offsets := newOffsetTracker()
+		inflight := foo0
+		inflight.Offset = 7
+		offsets.track(inflight)
+
+		c := &fakeRebalanceConsumer{lost: true}
+		cg.handleRebalance(context.Background(), c, &worker{offsets: offsets}, kafka.RevokedPartitions{Partitions: []kafka.TopicPartition{foo0}})
+		if c.commits != 0 || len(calls) != 1 || calls[0] != "lost" {
+			t.Errorf("expected only the lost hook got %d commits and %v", c.commits, calls)
+		}
+	})
+
+	t.Run("assigned partitions", func(t *testing.T) {
+		calls = nil
+		cg.handleRebalance(context.Background(), &fakeRebalanceConsumer{}, &worker{offsets: newOffsetTracker()}, kafka.AssignedPartitions{Partitions: []kafka.TopicPartition{foo0}})
+		if len(calls) != 1 || calls[0] != "assigned" {
+			t.Errorf("expected the assigned hook got %v", calls)
+		}
+	})
+}
+
+func TestConsumerGroup_RebalanceHooks(t *testing.T) {
+	mc := newMockCluster(t)
+	createTopics(t, mc.BootstrapServers(), "rebalance")
+
+	var mu sync.Mutex
+	var assigned, revoked []kafka.TopicPartition
+	assignedCh := make(chan struct{}, 1)
+	cg := ConsumerGroup{
+		GroupConfig: ConsumerConfig{
+			BootstrapServers: mc.BootstrapServers(),
+			GroupID:          "rebalance-group",
+			Topics:           []string{"rebalance"},
+			ConsumerCount:    1,
+		},
+		OnAssigned: func(ctx context.Context, partitions []kafka.TopicPartition) {
+			mu.Lock()
+			assigned = partitions
+			mu.Unlock()
+			assignedCh <- struct{}{}
+		},
+		OnRevoked: func(ctx context.Context, partitions []kafka.TopicPartition) {
+			mu.Lock()
+			revoked = partitions
+			mu.Unlock()
+		},
+	}
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	done := make(chan error, 1)
+	go func() {
+		done <- cg.Consume(ctx, ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {}))
+	}()
+	select {
+	case <-assignedCh:
+	case <-time.After(20 * time.Second):
+		t.Fatal("timed out waiting for the assignment")
+	}
+	cfn()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	// the mock cluster creates topics with 4 partitions
+	if len(assigned) != 4 || len(revoked) != 4 {
+		t.Errorf("expected 4 assigned and revoked partitions got %v and %v", assigned, revoked)
+	}
+}
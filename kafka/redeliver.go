@@ -0,0 +1,156 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+)
+
+const (
+	defaultMaxRedeliveries   = 10
+	defaultRedeliveryBackoff = time.Second
+	// maxRedeliveryBackoff caps the delay between the redeliveries of an event
+	maxRedeliveryBackoff = time.Minute
+)
+
+// ErrRedeliveriesExhausted stops a worker once an event fails more than MaxRedeliveries times in a row
+var ErrRedeliveriesExhausted = errors.New("kafka: event redeliveries exhausted")
+
+// redelivery is a failed event of a partition, the partition stays paused until the event is due
+// and every in-flight event of the partition is acknowledged, it is then rewound to the event
+type redelivery struct {
+	tp kafka.TopicPartition
+	// attempts counts the times the event failed in a row
+	attempts  int
+	scheduled bool
+	at        time.Time
+}
+
+// rewinder is the part of the consumer used to redeliver failed events
+type rewinder interface {
+	Pause(partitions []kafka.TopicPartition) error
+	Seek(partition kafka.TopicPartition, ignoredTimeoutMs int) error
+}
+
+// redeliverer redelivers the failed events of a worker, it is only used from the poll loop of the worker
+type redeliverer struct {
+	consumer rewinder
+	offsets  *offsetTracker
+	logger   ziggurat.StructuredLogger
+	workerID string
+	// zero maxRedeliveries and backoff use the defaults
+	maxRedeliveries int
+	backoff         time.Duration
+	// seeking reports whether the partition is rewound by a seek instead, it may be nil
+	seeking func(k partitionKey) bool
+	// resume resumes a partition once it is rewound to the failed event
+	resume func(tp kafka.TopicPartition)
+
+	partitions     map[partitionKey]*redelivery
+	failureVersion uint64
+}
+
+func (rd *redeliverer) init() {
+	if rd.maxRedeliveries == 0 {
+		rd.maxRedeliveries = defaultMaxRedeliveries
+	}
+	if rd.backoff <= 0 {
+		rd.backoff = defaultRedeliveryBackoff
+	}
+}
+
+// redelivering reports whether the partition is waiting to be rewound to a failed event,
+// the messages polled meanwhile are consumed again once it is rewound
+func (rd *redeliverer) redelivering(tp kafka.TopicPartition) bool {
+	if len(rd.partitions) == 0 {
+		return false
+	}
+	r, ok := rd.partitions[keyFor(tp)]
+	return ok && r.scheduled
+}
+
+// apply schedules the redelivery of the failed events and rewinds the partitions whose event is due,
+// it returns ErrRedeliveriesExhausted once an event failed too many times
+func (rd *redeliverer) apply() error {
+	if v := rd.offsets.failures.Load(); v != rd.failureVersion {
+		rd.failureVersion = v
+		for _, tp := range rd.offsets.failedPartitions() {
+			if err := rd.schedule(tp); err != nil {
+				return err
+			}
+		}
+	}
+	now := time.Now()
+	for k, r := range rd.partitions {
+		if !r.scheduled || now.Before(r.at) || rd.offsets.partitionInFlight(r.tp) > 0 {
+			continue
+		}
+		r.scheduled = false
+		// a seek of the partition replaces the redelivery
+		if rd.seeking != nil && rd.seeking(k) {
+			continue
+		}
+		rd.redeliver(r)
+	}
+	return nil
+}
+
+// schedule pauses the partition of the failed event and schedules its redelivery after a backoff
+// which doubles every time the same event fails
+func (rd *redeliverer) schedule(tp kafka.TopicPartition) error {
+	k := keyFor(tp)
+	r, ok := rd.partitions[k]
+	if ok && r.scheduled {
+		// an earlier event of the partition failed after the redelivery was scheduled
+		if tp.Offset < r.tp.Offset {
+			r.tp = tp
+		}
+		return nil
+	}
+	if !ok || r.tp.Offset != tp.Offset {
+		r = &redelivery{tp: tp}
+		if rd.partitions == nil {
+			rd.partitions = map[partitionKey]*redelivery{}
+		}
+		rd.partitions[k] = r
+	}
+	r.attempts++
+	if rd.maxRedeliveries >= 0 && r.attempts > rd.maxRedeliveries {
+		return fmt.Errorf("%w: %s failed %d times", ErrRedeliveriesExhausted, tp, r.attempts)
+	}
+	backoff := rd.backoff
+	for i := 1; i < r.attempts && backoff < maxRedeliveryBackoff; i++ {
+		backoff *= 2
+	}
+	r.scheduled, r.at = true, time.Now().Add(min(backoff, maxRedeliveryBackoff))
+	rd.logger.Error("kafka error pausing partitions", rd.consumer.Pause([]kafka.TopicPartition{tp}), map[string]any{"Worker-ID": rd.workerID})
+	return nil
+}
+
+// redeliver rewinds the partition to the failed event, the offsets tracked after it are dropped as they are consumed again
+func (rd *redeliverer) redeliver(r *redelivery) {
+	kvs := map[string]any{"Worker-ID": rd.workerID, "topic": topicName(r.tp), "partition": r.tp.Partition, "offset": r.tp.Offset, "attempt": r.attempts}
+	if err := rd.consumer.Seek(r.tp, 0); err != nil {
+		rd.logger.Error("kafka error rewinding partition", err, kvs)
+		r.scheduled, r.at = true, time.Now().Add(rd.backoff)
+		return
+	}
+	rd.offsets.forget([]kafka.TopicPartition{r.tp})
+	rd.logger.Info("kafka redelivering failed event", kvs)
+	rd.resume(r.tp)
+}
+
+// cancel drops the redelivery of the partition, a seek moved the partition past the failed event
+func (rd *redeliverer) cancel(k partitionKey) {
+	delete(rd.partitions, k)
+}
+
+// drop drops the redeliveries of the revoked partitions, their next owner consumes them from the committed offset
+func (rd *redeliverer) drop(partitions []kafka.TopicPartition) {
+	for _, tp := range partitions {
+		delete(rd.partitions, keyFor(tp))
+	}
+}
@@ -0,0 +1,169 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+)
+
+// rewindConsumer serves an endless partition foo[0] whose message at offset n is polled after the one at n-1,
+// seeking moves the position and pausing stops the partition after it serves one more prefetched message
+type rewindConsumer struct {
+	nopConsumer
+	mu         sync.Mutex
+	position   kafka.Offset
+	paused     bool
+	prefetched bool
+	stored     []kafka.Offset
+}
+
+func (pc *rewindConsumer) Poll(int) kafka.Event {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.paused {
+		if !pc.prefetched {
+			return nil
+		}
+		pc.prefetched = false
+	}
+	tp := kafka.TopicPartition{Topic: makePtr("foo"), Offset: pc.position}
+	pc.position++
+	return &kafka.Message{TopicPartition: tp}
+}
+
+func (pc *rewindConsumer) Pause([]kafka.TopicPartition) error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.paused, pc.prefetched = true, true
+	return nil
+}
+
+func (pc *rewindConsumer) Resume([]kafka.TopicPartition) error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.paused = false
+	return nil
+}
+
+func (pc *rewindConsumer) Seek(tp kafka.TopicPartition, _ int) error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.position = tp.Offset
+	return nil
+}
+
+func (pc *rewindConsumer) StoreOffsets(tps []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	for _, tp := range tps {
+		pc.stored = append(pc.stored, tp.Offset)
+	}
+	return tps, nil
+}
+
+func (pc *rewindConsumer) storedOffsets() []kafka.Offset {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return append([]kafka.Offset(nil), pc.stored...)
+}
+
+func newRedeliveryWorker(pc *rewindConsumer, h ziggurat.HandlerFunc) *worker {
+	return &worker{
+		handler:           h,
+		logger:            logger.NOOP,
+		consumer:          pc,
+		routeGroup:        "foo-group",
+		killSig:           make(chan struct{}),
+		id:                "redelivery-worker",
+		offsets:           newOffsetTracker(),
+		maxRedeliveries:   3,
+		redeliveryBackoff: time.Millisecond,
+	}
+}
+
+func TestWorker_RedeliversFailedEvents(t *testing.T) {
+	pc := &rewindConsumer{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var w *worker
+	attempts := map[int64]int{}
+	var maxPending int
+	var storedBeforeSuccess []kafka.Offset
+	w = newRedeliveryWorker(pc, func(ctx context.Context, event *ziggurat.Event) {
+		offset := event.Metadata["kafka-offset"].(int64)
+		attempts[offset]++
+		w.offsets.mu.Lock()
+		maxPending = max(maxPending, len(w.offsets.pending[partitionKey{topic: "foo"}]))
+		w.offsets.mu.Unlock()
+
+		ack, _ := ziggurat.DeferAck(ctx)
+		switch {
+		case offset == 5 && attempts[offset] <= 2:
+			ack(errors.New("sink unavailable"))
+		case offset == 5:
+			storedBeforeSuccess = pc.storedOffsets()
+			ack(nil)
+		default:
+			// the events polled after the failed one complete while it waits to be redelivered
+			ack(nil)
+		}
+		if offset == 1000 {
+			cancel()
+		}
+	})
+	w.run(ctx)
+
+	if attempts[5] != 3 {
+		t.Errorf("expected offset 5 to be processed 3 times got %d", attempts[5])
+	}
+	for _, offset := range storedBeforeSuccess {
+		if offset > 5 {
+			t.Errorf("expected no offset past 5 to be stored before it succeeded got %v", storedBeforeSuccess)
+			break
+		}
+	}
+	if stored := pc.storedOffsets(); len(stored) == 0 || stored[len(stored)-1] < 1000 {
+		t.Errorf("expected the offsets to be stored up to 1000 got %v", stored[max(0, len(stored)-3):])
+	}
+	// the failed partition is paused, the messages polled after the failure are not tracked
+	if maxPending > 2 {
+		t.Errorf("expected the pending offsets to stay bounded got %d", maxPending)
+	}
+}
+
+func TestWorker_RedeliveriesExhausted(t *testing.T) {
+	pc := &rewindConsumer{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var attempts int
+	w := newRedeliveryWorker(pc, func(ctx context.Context, event *ziggurat.Event) {
+		if event.Metadata["kafka-offset"].(int64) == 2 {
+			attempts++
+			ack, _ := ziggurat.DeferAck(ctx)
+			ack(errors.New("poison event"))
+		}
+	})
+	w.run(ctx)
+
+	if !errors.Is(w.err, ErrRedeliveriesExhausted) {
+		t.Fatalf("expected %v got %v", ErrRedeliveriesExhausted, w.err)
+	}
+	// the event is processed once and redelivered 3 times
+	if attempts != 4 {
+		t.Errorf("expected 4 attempts got %d", attempts)
+	}
+	for _, offset := range pc.storedOffsets() {
+		if offset > 2 {
+			t.Errorf("expected no offset past the failed event to be stored got %v", pc.storedOffsets())
+			break
+		}
+	}
+}
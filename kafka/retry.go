@@ -0,0 +1,474 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+)
+
+const (
+	// KeyRetryCount holds the number of times the event has been retried
+	KeyRetryCount = "kafka-retry-count"
+	// HeaderRetryCount is the message header which holds the retry count of a retried event
+	HeaderRetryCount = "ziggurat-retry-count"
+	// HeaderRetryDue is the message header which holds the unix time in milliseconds
+	// before which a retried event is not consumed
+	HeaderRetryDue = "ziggurat-retry-due"
+)
+
+// RetryTopic configures the retry topics of a topic key
+// an event retried for the n-th time is sent to the n-th retry topic and is consumed
+// once the n-th delay has passed, retries beyond the number of delays use the last retry topic.
+// Events retried more than MaxAttempts times are sent to the dead letter topic.
+//
+// The retry topics are named <TopicKey>-retry-<n> starting at 0 and the dead letter topic is named <TopicKey>-dlt
+type RetryTopic struct {
+	TopicKey string
+	Delays   []time.Duration
+	// MaxAttempts defaults to the number of delays
+	MaxAttempts int
+}
+
+func (rt RetryTopic) retryTopic(i int) string {
+	return fmt.Sprintf("%s-retry-%d", rt.TopicKey, i)
+}
+
+// Topics returns the names of the retry topics
+func (rt RetryTopic) Topics() []string {
+	topics := make([]string, len(rt.Delays))
+	for i := range rt.Delays {
+		topics[i] = rt.retryTopic(i)
+	}
+	return topics
+}
+
+// DeadLetterTopic returns the name of the dead letter topic
+func (rt RetryTopic) DeadLetterTopic() string {
+	return rt.TopicKey + "-dlt"
+}
+
+type RetryConfig struct {
+	BootstrapServers string
+	// GroupID is the consumer group which consumes the retry topics,
+	// the replay position of the dead letter topics is stored under the group <GroupID>-dlt
+	GroupID       string
+	ConsumerCount int
+	PollTimeout   int
+	Topics        []RetryTopic
+	// SASL, SSL and Overrides configure the retry and dead letter consumers and the retry producer
+	// the same way as the ConsumerConfig of a consumer group, WithRetryTokenProvider sets their OAUTHBEARER tokens
+	SASL      *SASLConfig
+	SSL       *SSLConfig
+	Overrides kafka.ConfigMap
+	// MaxRedeliveries and RedeliveryBackoffMS redeliver the retried events whose acknowledgement fails
+	// the same way as the ConsumerConfig of a consumer group
+	MaxRedeliveries     int
+	RedeliveryBackoffMS int
+}
+
+type RetryOpts func(r *ARetry)
+
+func WithRetryLogger(l ziggurat.StructuredLogger) RetryOpts {
+	return func(r *ARetry) {
+		r.logger = l
+	}
+}
+
+// WithRetryTokenProvider sets the OAUTHBEARER tokens of the retry and dead letter consumers and of the retry producer
+func WithRetryTokenProvider(tp TokenProvider) RetryOpts {
+	return func(r *ARetry) {
+		r.tokens = tp
+	}
+}
+
+// WithRetryProducer sends the retried events using an existing producer,
+// the producer is not closed by ARetry.Close
+func WithRetryProducer(p *Producer) RetryOpts {
+	return func(r *ARetry) {
+		r.producer = p
+	}
+}
+
+// ARetry retries events using kafka topics
+// Retry sends the event to a retry topic, Consume consumes the retry topics and hands the events
+// to the handler once they are due. The events are sent as JSON and are consumed with
+// the RoutingPath of the original event, RetryCountFor returns the number of times an event has been retried.
+//
+// Close closes the producer created by AutoRetry, ARetry can be added to the Ziggurat.Closers
+type ARetry struct {
+	config      RetryConfig
+	topics      map[string]RetryTopic
+	logger      ziggurat.StructuredLogger
+	producer    *Producer
+	ownProducer bool
+	tokens      TokenProvider
+}
+
+func AutoRetry(config RetryConfig, opts ...RetryOpts) (*ARetry, error) {
+	if config.GroupID == "" {
+		return nil, errors.New("kafka: GroupID is required for retries")
+	}
+	if config.MaxRedeliveries < -1 {
+		return nil, fmt.Errorf("kafka: MaxRedeliveries must be -1 or more, got %d", config.MaxRedeliveries)
+	}
+	if err := errors.Join(validateSecurity(config.SASL, config.SSL), ConsumerConfig{Overrides: config.Overrides}.validateOverrides()); err != nil {
+		return nil, err
+	}
+	if config.ConsumerCount < 1 {
+		config.ConsumerCount = 1
+	}
+	if config.PollTimeout == 0 {
+		config.PollTimeout = 100
+	}
+	r := &ARetry{
+		config: config,
+		topics: map[string]RetryTopic{},
+		logger: logger.NOOP,
+	}
+	for _, rt := range config.Topics {
+		if rt.TopicKey == "" || len(rt.Delays) == 0 {
+			return nil, fmt.Errorf("kafka: retry topic %q requires a TopicKey and at least one delay", rt.TopicKey)
+		}
+		if rt.MaxAttempts < 1 {
+			rt.MaxAttempts = len(rt.Delays)
+		}
+		r.topics[rt.TopicKey] = rt
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	if r.producer == nil {
+		p, err := NewProducer(r.producerConfig(), WithProducerLogger(r.logger), WithTokenProvider(r.tokens))
+		if err != nil {
+			return nil, err
+		}
+		r.producer = p
+		r.ownProducer = true
+	}
+	return r, nil
+}
+
+// RetryCountFor returns the number of times the event has been retried
+func RetryCountFor(e *ziggurat.Event) int {
+	if e.Metadata == nil {
+		return 0
+	}
+	switch count := e.Metadata[KeyRetryCount].(type) {
+	case int:
+		return count
+	// numbers are unmarshalled as float64
+	case float64:
+		return int(count)
+	default:
+		return 0
+	}
+}
+
+// Retry sends the event to the retry topic of its next attempt or to the dead letter topic
+// once the attempts are exhausted, it returns once the message is delivered
+func (r *ARetry) Retry(ctx context.Context, event *ziggurat.Event, topicKey string) error {
+	rt, ok := r.topics[topicKey]
+	if !ok {
+		return fmt.Errorf("kafka: unknown retry topic key %s", topicKey)
+	}
+	count := RetryCountFor(event) + 1
+	if count > rt.MaxAttempts {
+		return r.send(ctx, rt.DeadLetterTopic(), event, count, time.Time{})
+	}
+	i := min(count, len(rt.Delays)) - 1
+	return r.send(ctx, rt.retryTopic(i), event, count, time.Now().Add(rt.Delays[i]))
+}
+
+func (r *ARetry) send(ctx context.Context, topic string, event *ziggurat.Event, count int, due time.Time) error {
+	// the event is cloned as kafka events are reused once they are acknowledged
+	retried := event.Clone()
+	if retried.Metadata == nil {
+		retried.Metadata = map[string]any{}
+	}
+	retried.Metadata[KeyRetryCount] = count
+	value, err := json.Marshal(retried)
+	if err != nil {
+		return fmt.Errorf("kafka: error encoding retried event: %w", err)
+	}
+	headers := map[string]string{HeaderRetryCount: strconv.Itoa(count)}
+	if !due.IsZero() {
+		headers[HeaderRetryDue] = strconv.FormatInt(due.UnixMilli(), 10)
+	}
+	_, err = r.producer.Send(ctx, topic, &ziggurat.Event{
+		Key:      event.Key,
+		Value:    value,
+		Metadata: map[string]any{KeyHeaders: headers},
+	})
+	return err
+}
+
+func (r *ARetry) retryTopics() []string {
+	var topics []string
+	for _, rt := range r.topics {
+		topics = append(topics, rt.Topics()...)
+	}
+	return topics
+}
+
+// producerConfig returns the config of the producer created by AutoRetry
+func (r *ARetry) producerConfig() ProducerConfig {
+	return ProducerConfig{
+		BootstrapServers: r.config.BootstrapServers,
+		SASL:             r.config.SASL,
+		SSL:              r.config.SSL,
+		Overrides:        r.config.Overrides,
+	}
+}
+
+// consumerConfigMap returns the config of the retry and dead letter consumers, they connect to the cluster
+// with the same security settings and overrides as the consumers of a consumer group
+func (r *ARetry) consumerConfigMap(groupID string) kafka.ConfigMap {
+	return ConsumerConfig{
+		BootstrapServers: r.config.BootstrapServers,
+		GroupID:          groupID,
+		AutoOffsetReset:  "earliest",
+		SASL:             r.config.SASL,
+		SSL:              r.config.SSL,
+		Overrides:        r.config.Overrides,
+	}.toConfigMap()
+}
+
+// dltConfigMap returns the config of the dead letter consumer, the replay position is committed once the events are replayed
+func (r *ARetry) dltConfigMap() kafka.ConfigMap {
+	cm := r.consumerConfigMap(r.config.GroupID + "-dlt")
+	cm["enable.auto.commit"] = false
+	return cm
+}
+
+// Consume consumes the retry topics until ctx is done
+// a partition is paused while its next event is not yet due
+func (r *ARetry) Consume(ctx context.Context, h ziggurat.Handler) error {
+	cm := r.consumerConfigMap(r.config.GroupID)
+	workers := make([]*retryWorker, r.config.ConsumerCount)
+	for i := range workers {
+		c, err := kafka.NewConsumer(&cm)
+		if err != nil {
+			for _, w := range workers[:i] {
+				w.consumer.Close()
+			}
+			return fmt.Errorf("kafka: error creating retry consumer: %w", err)
+		}
+		w := &retryWorker{
+			consumer:    c,
+			handler:     h,
+			logger:      r.logger,
+			id:          fmt.Sprintf("%s_%d", r.config.GroupID, i),
+			pollTimeout: r.config.PollTimeout,
+			offsets:     newOffsetTracker(),
+			paused:      map[partitionKey]pausedPartition{},
+			tokens:      r.tokens,
+		}
+		w.redelivery = redeliverer{
+			consumer:        c,
+			offsets:         w.offsets,
+			logger:          r.logger,
+			workerID:        w.id,
+			maxRedeliveries: r.config.MaxRedeliveries,
+			backoff:         time.Duration(r.config.RedeliveryBackoffMS) * time.Millisecond,
+			resume:          w.resumeRewound,
+		}
+		w.redelivery.init()
+		workers[i] = w
+	}
+
+	topics := r.retryTopics()
+	errs := make([]error, len(workers))
+	var wg sync.WaitGroup
+	for i, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = w.run(ctx, topics)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Close closes the producer created by AutoRetry
+func (r *ARetry) Close() error {
+	if !r.ownProducer {
+		return nil
+	}
+	return r.producer.Close()
+}
+
+type pausedPartition struct {
+	tp    kafka.TopicPartition
+	until time.Time
+}
+
+type retryWorker struct {
+	consumer    *kafka.Consumer
+	handler     ziggurat.Handler
+	logger      ziggurat.StructuredLogger
+	id          string
+	pollTimeout int
+	offsets     *offsetTracker
+	inflight    sync.WaitGroup
+	paused      map[partitionKey]pausedPartition
+	tokens      TokenProvider
+	// redelivery rewinds the partitions of failed events like the workers of a consumer group
+	redelivery redeliverer
+}
+
+func (w *retryWorker) run(ctx context.Context, topics []string) error {
+	defer func() {
+		w.inflight.Wait()
+		if _, err := w.consumer.Commit(); err != nil && !isNoOffset(err) {
+			w.logger.Error("retry consumer pre-close commit error", err, map[string]any{"Worker-ID": w.id})
+		}
+		w.logger.Error("error closing retry consumer", w.consumer.Close(), map[string]any{"Worker-ID": w.id})
+	}()
+	go func() {
+		for evt := range w.consumer.Logs() {
+			w.logger.Info(evt.Message, map[string]any{"client": evt.Name, "lvl": evt.Level})
+		}
+	}()
+	if err := w.consumer.SubscribeTopics(topics, w.rebalance); err != nil {
+		return fmt.Errorf("kafka: error subscribing to retry topics: %w", err)
+	}
+
+	for ctx.Err() == nil {
+		if err := w.redelivery.apply(); err != nil {
+			return err
+		}
+		w.resumeDue(time.Now())
+		switch e := w.consumer.Poll(w.pollTimeout).(type) {
+		case *kafka.Message:
+			if w.redelivery.redelivering(e.TopicPartition) {
+				// the message was fetched before the partition was paused, it is consumed again once the partition is rewound
+				continue
+			}
+			w.process(ctx, e)
+		case kafka.Error:
+			if e.IsFatal() {
+				return e
+			}
+			w.logger.Error("kafka retry poll error", e, map[string]any{"Worker-ID": w.id})
+		case kafka.OAuthBearerTokenRefresh:
+			w.logger.Error("kafka oauthbearer token refresh error", refreshToken(ctx, w.consumer, w.tokens), map[string]any{"Worker-ID": w.id})
+		}
+	}
+	return nil
+}
+
+// rebalance drops the state of the revoked partitions, their next owner consumes them from the committed offset
+func (w *retryWorker) rebalance(_ *kafka.Consumer, ev kafka.Event) error {
+	if e, ok := ev.(kafka.RevokedPartitions); ok {
+		w.offsets.forget(e.Partitions)
+		w.redelivery.drop(e.Partitions)
+		for _, tp := range e.Partitions {
+			delete(w.paused, keyFor(tp))
+		}
+	}
+	return nil
+}
+
+// resumeRewound resumes a partition once it is rewound to a failed event,
+// a partition which holds an event that is not yet due is resumed once the event is due
+func (w *retryWorker) resumeRewound(tp kafka.TopicPartition) {
+	if _, ok := w.paused[keyFor(tp)]; ok {
+		return
+	}
+	w.logger.Error("error resuming retry partition", w.consumer.Resume([]kafka.TopicPartition{tp}), map[string]any{"Worker-ID": w.id})
+}
+
+func isNoOffset(err error) bool {
+	var kerr kafka.Error
+	return errors.As(err, &kerr) && kerr.Code() == kafka.ErrNoOffset
+}
+
+// hold pauses the partition until the message is due and seeks back to the message
+// so that it is fetched again once the partition is resumed
+func (w *retryWorker) hold(tp kafka.TopicPartition, until time.Time) {
+	kvs := map[string]any{"Worker-ID": w.id, "topic": topicName(tp), "partition": tp.Partition}
+	if err := w.consumer.Pause([]kafka.TopicPartition{tp}); err != nil {
+		w.logger.Error("error pausing retry partition", err, kvs)
+	}
+	if err := w.consumer.Seek(tp, 0); err != nil {
+		w.logger.Error("error seeking retry partition", err, kvs)
+	}
+	w.paused[keyFor(tp)] = pausedPartition{tp: tp, until: until}
+}
+
+func (w *retryWorker) resumeDue(now time.Time) {
+	for k, p := range w.paused {
+		if now.Before(p.until) {
+			continue
+		}
+		delete(w.paused, k)
+		// the partition stays paused while it waits to be rewound to a failed event
+		if w.redelivery.redelivering(p.tp) {
+			continue
+		}
+		// the partition may have been revoked in the meantime
+		if err := w.consumer.Resume([]kafka.TopicPartition{p.tp}); err != nil {
+			w.logger.Warn("error resuming retry partition", map[string]any{"Worker-ID": w.id, "topic": k.topic, "partition": k.partition, "error": err.Error()})
+		}
+	}
+}
+
+func (w *retryWorker) process(ctx context.Context, msg *kafka.Message) {
+	headers := headersToMap(msg.Headers)
+	if due, err := strconv.ParseInt(headers[HeaderRetryDue], 10, 64); err == nil {
+		if until := time.UnixMilli(due); time.Now().Before(until) {
+			w.hold(msg.TopicPartition, until)
+			return
+		}
+	}
+
+	w.inflight.Add(1)
+	w.offsets.track(msg.TopicPartition)
+	var e ziggurat.Event
+	if err := json.Unmarshal(msg.Value, &e); err != nil {
+		// the message can never be processed, its offset is stored so that the partition moves forward
+		w.logger.Error("dropping retry message which is not an event", err, map[string]any{"Worker-ID": w.id, "topic": topicName(msg.TopicPartition)})
+		w.acknowledge(msg.TopicPartition, nil)
+		return
+	}
+	if e.Metadata == nil {
+		e.Metadata = map[string]any{}
+	}
+	if count, err := strconv.Atoi(headers[HeaderRetryCount]); err == nil {
+		e.Metadata[KeyRetryCount] = count
+	}
+	e.ReceivedTimestamp = time.Now()
+
+	tp := msg.TopicPartition
+	actx, ack := ziggurat.WithAck(ctx, func(err error) {
+		w.acknowledge(tp, err)
+	})
+	w.handler.Handle(actx, &e)
+	ack.Release()
+}
+
+// acknowledge stores the offset once every earlier event of the partition is acknowledged
+func (w *retryWorker) acknowledge(tp kafka.TopicPartition, err error) {
+	defer w.inflight.Done()
+	if err != nil {
+		w.logger.Error("retried event processing failed, not storing offsets", err, map[string]any{"Worker-ID": w.id})
+		w.offsets.fail(tp)
+		return
+	}
+	storeTP, ok := w.offsets.complete(tp)
+	if !ok {
+		return
+	}
+	if err := storeOffsets(w.consumer, storeTP); err != nil {
+		w.logger.Error("error storing retry offsets locally", err)
+	}
+}
@@ -0,0 +1,321 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+)
+
+// createTopics creates the topics by requesting their metadata, the mock cluster creates unknown topics
+func createTopics(t *testing.T, bootstrap string, topics ...string) {
+	t.Helper()
+	p, err := kafka.NewProducer(&kafka.ConfigMap{"bootstrap.servers": bootstrap})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	for _, topic := range topics {
+		if _, err := p.GetMetadata(&topic, false, 5000); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+type retried struct {
+	count int
+	at    time.Time
+	path  string
+}
+
+func TestARetry_RetriesUntilDeadLetter(t *testing.T) {
+	mc := newMockCluster(t)
+	rt := RetryTopic{TopicKey: "orders", Delays: []time.Duration{200 * time.Millisecond, 400 * time.Millisecond}}
+	createTopics(t, mc.BootstrapServers(), append(rt.Topics(), rt.DeadLetterTopic())...)
+	ar, err := AutoRetry(RetryConfig{
+		BootstrapServers: mc.BootstrapServers(),
+		GroupID:          "retry-test",
+		Topics:           []RetryTopic{rt},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	var mu sync.Mutex
+	var got []retried
+	received := make(chan struct{}, 10)
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		mu.Lock()
+		got = append(got, retried{count: RetryCountFor(event), at: time.Now(), path: event.RoutingPath})
+		mu.Unlock()
+		if err := ar.Retry(ctx, event, "orders"); err != nil {
+			t.Errorf("retry error: %v", err)
+		}
+		received <- struct{}{}
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- ar.Consume(ctx, h)
+	}()
+
+	sent := time.Now()
+	event := &ziggurat.Event{Key: []byte("k"), Value: []byte("order-1"), RoutingPath: "orders-group/orders/0"}
+	if err := ar.Retry(ctx, event, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-received:
+		case <-time.After(20 * time.Second):
+			t.Fatalf("timed out waiting for retry %d", i+1)
+		}
+	}
+
+	mu.Lock()
+	if got[0].count != 1 || got[1].count != 2 {
+		t.Errorf("expected retry counts 1 and 2 got %+v", got)
+	}
+	if got[0].path != event.RoutingPath {
+		t.Errorf("expected routing path %s got %s", event.RoutingPath, got[0].path)
+	}
+	if got[0].at.Sub(sent) < 200*time.Millisecond || got[1].at.Sub(got[0].at) < 400*time.Millisecond {
+		t.Errorf("expected the events to be held until they are due got %+v", got)
+	}
+	mu.Unlock()
+
+	// the third attempt exceeds MaxAttempts and is sent to the dead letter topic
+	rec := httptest.NewRecorder()
+	ar.DSViewHandler(ctx).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ds_view?topic=orders&count=10", nil))
+	var view dsViewResp
+	if err := json.NewDecoder(rec.Body).Decode(&view); err != nil {
+		t.Fatal(err)
+	}
+	if view.Count != 1 || string(view.Events[0].Value) != "order-1" || RetryCountFor(view.Events[0]) != 3 {
+		t.Fatalf("unexpected dead letter events %+v", view)
+	}
+
+	rec = httptest.NewRecorder()
+	ar.DSReplayHandler(ctx).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ds_replay?topic=orders&count=10", nil))
+	var replay dsReplayResp
+	if err := json.NewDecoder(rec.Body).Decode(&replay); err != nil {
+		t.Fatal(err)
+	}
+	if replay.ReplayCount != 1 {
+		t.Fatalf("expected 1 replayed event got %+v", replay)
+	}
+	select {
+	case <-received:
+	case <-time.After(20 * time.Second):
+		t.Fatal("timed out waiting for the replayed event")
+	}
+	mu.Lock()
+	if got[2].count != 0 {
+		t.Errorf("expected the retry count of the replayed event to be reset got %d", got[2].count)
+	}
+	mu.Unlock()
+
+	// the replayed event is retried again and the view starts after the replayed message
+	select {
+	case <-received:
+	case <-time.After(20 * time.Second):
+		t.Fatal("timed out waiting for the retried event")
+	}
+	cfn()
+	if err := <-done; err != nil {
+		t.Fatalf("expected nil error got %v", err)
+	}
+	rec = httptest.NewRecorder()
+	ar.DSViewHandler(context.Background()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ds_view?topic=orders&count=10", nil))
+	view = dsViewResp{}
+	if err := json.NewDecoder(rec.Body).Decode(&view); err != nil {
+		t.Fatal(err)
+	}
+	if view.Count != 0 {
+		t.Errorf("expected the replayed event to be skipped got %+v", view)
+	}
+}
+
+func TestARetry_Validation(t *testing.T) {
+	if _, err := AutoRetry(RetryConfig{Topics: []RetryTopic{{TopicKey: "foo", Delays: []time.Duration{time.Second}}}}); err == nil {
+		t.Error("expected an error for a missing GroupID")
+	}
+	if _, err := AutoRetry(RetryConfig{GroupID: "foo", Topics: []RetryTopic{{TopicKey: "foo"}}}); err == nil {
+		t.Error("expected an error for a retry topic without delays")
+	}
+
+	mc := newMockCluster(t)
+	ar, err := AutoRetry(RetryConfig{BootstrapServers: mc.BootstrapServers(), GroupID: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if err := ar.Retry(context.Background(), &ziggurat.Event{}, "bar"); err == nil {
+		t.Error("expected an error for an unknown topic key")
+	}
+	for _, target := range []string{"/ds_view?count=1", "/ds_view?topic=foo", "/ds_view?topic=foo&count=x"} {
+		rec := httptest.NewRecorder()
+		ar.DSViewHandler(context.Background()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400 got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestRetryCountFor(t *testing.T) {
+	cases := map[string]struct {
+		metadata map[string]any
+		want     int
+	}{
+		"nil metadata": {want: 0},
+		"int":          {metadata: map[string]any{KeyRetryCount: 2}, want: 2},
+		"json number":  {metadata: map[string]any{KeyRetryCount: float64(3)}, want: 3},
+		"invalid":      {metadata: map[string]any{KeyRetryCount: "3"}, want: 0},
+	}
+	for name, c := range cases {
+		if got := RetryCountFor(&ziggurat.Event{Metadata: c.metadata}); got != c.want {
+			t.Errorf("%s: expected %d got %d", name, c.want, got)
+		}
+	}
+}
+
+func TestARetry_RedeliversFailedEvents(t *testing.T) {
+	mc := newMockCluster(t)
+	rt := RetryTopic{TopicKey: "payments", Delays: []time.Duration{50 * time.Millisecond}}
+	createTopics(t, mc.BootstrapServers(), append(rt.Topics(), rt.DeadLetterTopic())...)
+	ar, err := AutoRetry(RetryConfig{
+		BootstrapServers:    mc.BootstrapServers(),
+		GroupID:             "retry-redelivery-test",
+		Topics:              []RetryTopic{rt},
+		RedeliveryBackoffMS: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[string(event.Value)]++
+		ack, _ := ziggurat.DeferAck(ctx)
+		if string(event.Value) == "payment-1" && attempts["payment-1"] == 1 {
+			ack(errors.New("sink unavailable"))
+			return
+		}
+		ack(nil)
+		if attempts["payment-1"] > 1 && attempts["payment-3"] > 0 {
+			cfn()
+		}
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- ar.Consume(ctx, h)
+	}()
+	for _, v := range []string{"payment-1", "payment-2", "payment-3"} {
+		if err := ar.Retry(ctx, &ziggurat.Event{Key: []byte("k"), Value: []byte(v)}, "payments"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected nil error got %v", err)
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatal("timed out waiting for the failed event to be redelivered")
+	}
+
+	// the offsets after the failed event are stored once it is redelivered
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{"bootstrap.servers": mc.BootstrapServers(), "group.id": "retry-redelivery-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	topic := rt.retryTopic(0)
+	md, err := c.GetMetadata(&topic, false, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var partitions []kafka.TopicPartition
+	for _, p := range md.Topics[topic].Partitions {
+		partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: p.ID})
+	}
+	committed, err := c.Committed(partitions, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total kafka.Offset
+	for _, tp := range committed {
+		if tp.Offset > 0 {
+			total += tp.Offset
+		}
+	}
+	if total != 3 {
+		t.Errorf("expected the offsets of the 3 events to be committed got %v", committed)
+	}
+}
+
+func TestARetry_ClientConfig(t *testing.T) {
+	tokens := TokenProviderFunc(func(context.Context) (kafka.OAuthBearerToken, error) {
+		return kafka.OAuthBearerToken{TokenValue: "token", Expiration: time.Now().Add(time.Hour)}, nil
+	})
+	ar, err := AutoRetry(RetryConfig{
+		BootstrapServers: "localhost:9092",
+		GroupID:          "payments",
+		SASL:             &SASLConfig{Mechanism: SASLMechanismOAuthBearer},
+		SSL:              &SSLConfig{},
+		Overrides:        kafka.ConfigMap{"client.rack": "az1"},
+	}, WithRetryTokenProvider(tokens))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if ar.tokens == nil {
+		t.Error("expected the token provider to be set")
+	}
+	want := map[string]kafka.ConfigValue{
+		"bootstrap.servers": "localhost:9092",
+		"security.protocol": "SASL_SSL",
+		"sasl.mechanism":    "OAUTHBEARER",
+		"client.rack":       "az1",
+	}
+	configs := map[string]kafka.ConfigMap{
+		"retry consumer":       ar.consumerConfigMap("payments"),
+		"dead letter consumer": ar.dltConfigMap(),
+		"retry producer":       ar.producerConfig().toConfigMap(),
+	}
+	for name, cm := range configs {
+		for k, v := range want {
+			if cm[k] != v {
+				t.Errorf("%s: expected %s to be %v got %v", name, k, v, cm[k])
+			}
+		}
+	}
+	if cm := ar.dltConfigMap(); cm["group.id"] != "payments-dlt" || cm["enable.auto.commit"] != false {
+		t.Errorf("unexpected dead letter consumer config %v", cm)
+	}
+
+	if _, err := AutoRetry(RetryConfig{GroupID: "payments", SASL: &SASLConfig{Mechanism: "GSSAPI"}}); err == nil {
+		t.Error("expected an error for an unsupported SASL mechanism")
+	}
+	if _, err := AutoRetry(RetryConfig{GroupID: "payments", Overrides: kafka.ConfigMap{"group.id": "other"}}); err == nil {
+		t.Error("expected an error for a reserved override")
+	}
+	if _, err := AutoRetry(RetryConfig{GroupID: "payments", MaxRedeliveries: -2}); err == nil {
+		t.Error("expected an error for a negative MaxRedeliveries")
+	}
+}
@@ -0,0 +1,106 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// the SASL mechanisms supported by SASLConfig
+const (
+	SASLMechanismPlain       = "PLAIN"
+	SASLMechanismScramSHA256 = "SCRAM-SHA-256"
+	SASLMechanismScramSHA512 = "SCRAM-SHA-512"
+	// SASLMechanismOAuthBearer uses the tokens of the TokenProvider instead of the Username and the Password
+	SASLMechanismOAuthBearer = "OAUTHBEARER"
+)
+
+// SASLConfig authenticates the client using a username and a password,
+// or the tokens of a TokenProvider for OAUTHBEARER
+type SASLConfig struct {
+	// Mechanism is one of PLAIN, SCRAM-SHA-256, SCRAM-SHA-512 or OAUTHBEARER
+	Mechanism string `yaml:"mechanism"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+func (s *SASLConfig) validate() error {
+	var errs []error
+	switch s.Mechanism {
+	case SASLMechanismOAuthBearer:
+		return nil
+	case SASLMechanismPlain, SASLMechanismScramSHA256, SASLMechanismScramSHA512:
+	default:
+		errs = append(errs, fmt.Errorf("kafka: unsupported SASL mechanism %q", s.Mechanism))
+	}
+	if s.Username == "" || s.Password == "" {
+		errs = append(errs, errors.New("kafka: SASL requires a Username and a Password"))
+	}
+	return errors.Join(errs...)
+}
+
+// SSLConfig encrypts the connections to the brokers using TLS,
+// the system CA certificates are used when CALocation is empty
+type SSLConfig struct {
+	// CALocation is the path of the CA certificate file or directory used to verify the brokers
+	CALocation string `yaml:"ca_location"`
+	// CertificateLocation and KeyLocation are the paths of the client's certificate and private key
+	// used to authenticate the client with mutual TLS
+	CertificateLocation string `yaml:"certificate_location"`
+	KeyLocation         string `yaml:"key_location"`
+	KeyPassword         string `yaml:"key_password"`
+}
+
+func (s *SSLConfig) validate() error {
+	if (s.CertificateLocation == "") != (s.KeyLocation == "") {
+		return errors.New("kafka: SSL requires both a CertificateLocation and a KeyLocation for client authentication")
+	}
+	return nil
+}
+
+// validateSecurity returns the errors of both the SASL and the SSL settings
+func validateSecurity(sasl *SASLConfig, ssl *SSLConfig) error {
+	var errs []error
+	if sasl != nil {
+		errs = append(errs, sasl.validate())
+	}
+	if ssl != nil {
+		errs = append(errs, ssl.validate())
+	}
+	return errors.Join(errs...)
+}
+
+// setSecurity sets the security.protocol and the SASL and SSL properties
+func setSecurity(cm kafka.ConfigMap, sasl *SASLConfig, ssl *SSLConfig) {
+	switch {
+	case sasl != nil && ssl != nil:
+		cm["security.protocol"] = "SASL_SSL"
+	case sasl != nil:
+		cm["security.protocol"] = "SASL_PLAINTEXT"
+	case ssl != nil:
+		cm["security.protocol"] = "SSL"
+	}
+
+	if sasl != nil {
+		cm["sasl.mechanism"] = sasl.Mechanism
+		if sasl.Mechanism != SASLMechanismOAuthBearer {
+			cm["sasl.username"] = sasl.Username
+			cm["sasl.password"] = sasl.Password
+		}
+	}
+
+	if ssl != nil {
+		properties := map[string]string{
+			"ssl.ca.location":          ssl.CALocation,
+			"ssl.certificate.location": ssl.CertificateLocation,
+			"ssl.key.location":         ssl.KeyLocation,
+			"ssl.key.password":         ssl.KeyPassword,
+		}
+		for k, v := range properties {
+			if v != "" {
+				cm[k] = v
+			}
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// seekTimeoutMS bounds the broker requests made while seeking
+const seekTimeoutMS = 10000
+
+// SeekTarget is the position a partition of the group is moved to
+type SeekTarget struct {
+	Topic string
+	// Partition is kafka.PartitionAny for every partition of the topic
+	Partition int32
+	// Offset is an absolute offset, kafka.OffsetBeginning for the earliest
+	// or kafka.OffsetEnd for the latest offset of the partition
+	Offset kafka.Offset
+	// Timestamp moves the partition to the first message produced at or after it,
+	// Offset is ignored when it is set
+	Timestamp time.Time
+}
+
+func (t SeekTarget) validate() error {
+	if t.Topic == "" {
+		return errors.New("kafka: seek target requires a Topic")
+	}
+	if t.Partition < 0 && t.Partition != kafka.PartitionAny {
+		return fmt.Errorf("kafka: invalid seek partition %d", t.Partition)
+	}
+	if t.Timestamp.IsZero() && t.Offset < 0 && t.Offset != kafka.OffsetBeginning && t.Offset != kafka.OffsetEnd {
+		return fmt.Errorf("kafka: invalid seek offset %v", t.Offset)
+	}
+	return nil
+}
+
+func (t SeekTarget) topicPartition() kafka.TopicPartition {
+	topic := t.Topic
+	return kafka.TopicPartition{Topic: &topic, Partition: t.Partition, Offset: kafka.OffsetInvalid}
+}
+
+// pendingSeeks holds the seek targets which are not yet claimed by the worker the partition is assigned to
+type pendingSeeks struct {
+	mu      sync.Mutex
+	targets map[partitionKey]SeekTarget
+	// version changes whenever a target is added so that the workers only look for targets when there are new ones
+	version atomic.Uint64
+}
+
+func (ps *pendingSeeks) add(targets []SeekTarget) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.targets == nil {
+		ps.targets = map[partitionKey]SeekTarget{}
+	}
+	for _, t := range targets {
+		ps.targets[keyFor(t.topicPartition())] = t
+	}
+	ps.version.Add(1)
+}
+
+// claim removes and returns the targets of the partitions
+func (ps *pendingSeeks) claim(partitions []kafka.TopicPartition) []SeekTarget {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	var claimed []SeekTarget
+	for _, tp := range partitions {
+		k := keyFor(tp)
+		if t, ok := ps.targets[k]; ok {
+			claimed = append(claimed, t)
+			delete(ps.targets, k)
+		}
+	}
+	return claimed
+}
+
+// restore adds back the targets of revoked partitions unless a newer target was added meanwhile
+func (ps *pendingSeeks) restore(targets []SeekTarget) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for _, t := range targets {
+		k := keyFor(t.topicPartition())
+		if _, ok := ps.targets[k]; !ok {
+			ps.targets[k] = t
+		}
+	}
+	ps.version.Add(1)
+}
+
+// Seek moves the partitions of the group to the targets, the worker a partition is assigned to pauses it
+// until its in-flight events are acknowledged, then seeks and commits the new position before resuming it.
+// The targets of partitions which are not assigned to the group are applied once they are assigned to it,
+// a newer target of a partition replaces the pending one.
+//
+// Seek returns the pending targets of every partition, the partitions of a topic wide target
+// are looked up using the consumer of a worker and require the group to be consuming
+func (cg *ConsumerGroup) Seek(targets ...SeekTarget) ([]SeekTarget, error) {
+	var expanded []SeekTarget
+	for _, t := range targets {
+		if err := t.validate(); err != nil {
+			return nil, err
+		}
+		if t.Partition != kafka.PartitionAny {
+			expanded = append(expanded, t)
+			continue
+		}
+		partitions, err := cg.partitionsOf(t.Topic)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range partitions {
+			t.Partition = p
+			expanded = append(expanded, t)
+		}
+	}
+	cg.seeks.add(expanded)
+	return expanded, nil
+}
+
+func (cg *ConsumerGroup) partitionsOf(topic string) ([]int32, error) {
+	workers := cg.spawned()
+	if len(workers) == 0 {
+		return nil, errors.New("kafka: seeking every partition of a topic requires a consuming group")
+	}
+	md, err := workers[0].consumer.GetMetadata(&topic, false, seekTimeoutMS)
+	if err != nil {
+		return nil, fmt.Errorf("kafka: error fetching the partitions of %s: %w", topic, err)
+	}
+	tm, ok := md.Topics[topic]
+	if !ok || tm.Error.Code() != kafka.ErrNoError || len(tm.Partitions) == 0 {
+		return nil, fmt.Errorf("kafka: unknown topic %s", topic)
+	}
+	partitions := make([]int32, 0, len(tm.Partitions))
+	for _, p := range tm.Partitions {
+		partitions = append(partitions, p.ID)
+	}
+	return partitions, nil
+}
+
+// applySeeks claims the pending targets of the worker's partitions and applies them
+// once the in-flight events of their partitions are acknowledged
+func (w *worker) applySeeks() {
+	if w.seeks == nil {
+		return
+	}
+	if v := w.seeks.version.Load(); v != w.seekVersion || w.seeksAssigned {
+		w.seekVersion, w.seeksAssigned = v, false
+		w.claimSeeks()
+	}
+	for k, t := range w.seeking {
+		tp := t.topicPartition()
+		if w.offsets.partitionInFlight(tp) > 0 {
+			continue
+		}
+		delete(w.seeking, k)
+		w.seek(t)
+		// the seek moves the partition past a failed event which is waiting to be redelivered
+		w.redelivery.cancel(k)
+		w.resumePartition(tp)
+	}
+}
+
+func (w *worker) claimSeeks() {
+	partitions, err := w.consumer.Assignment()
+	if err != nil {
+		w.logger.Error("kafka error fetching the assignment", err, map[string]any{"Worker-ID": w.id})
+		return
+	}
+	claimed := w.seeks.claim(partitions)
+	if len(claimed) == 0 {
+		return
+	}
+	if w.seeking == nil {
+		w.seeking = map[partitionKey]SeekTarget{}
+	}
+	paused := make([]kafka.TopicPartition, 0, len(claimed))
+	for _, t := range claimed {
+		w.seeking[keyFor(t.topicPartition())] = t
+		paused = append(paused, t.topicPartition())
+	}
+	// the partitions stay paused while their in-flight events are acknowledged
+	w.logger.Error("kafka error pausing partitions", w.consumer.Pause(paused), map[string]any{"Worker-ID": w.id})
+}
+
+// seek moves the partition to the target and commits the position so that the seek is not undone
+// by the offsets stored before it, or lost when the partition is reassigned
+func (w *worker) seek(t SeekTarget) {
+	tp := t.topicPartition()
+	kvs := map[string]any{"Worker-ID": w.id, "topic": t.Topic, "partition": t.Partition}
+	offset, err := w.resolve(t)
+	if err != nil {
+		w.logger.Error("kafka error resolving seek offset", err, kvs)
+		return
+	}
+	tp.Offset = offset
+	kvs["offset"] = offset
+	if err := w.consumer.Seek(tp, 0); err != nil {
+		w.logger.Error("kafka seek error", err, kvs)
+		return
+	}
+	w.offsets.forget([]kafka.TopicPartition{tp})
+	if _, err := w.consumer.StoreOffsets([]kafka.TopicPartition{tp}); err != nil {
+		w.logger.Error("kafka error storing seek offset", err, kvs)
+		return
+	}
+	if _, err := w.consumer.Commit(); err != nil && !isNoOffset(err) {
+		w.logger.Error("kafka error committing seek offset", err, kvs)
+		return
+	}
+	w.logger.Info("kafka partition seeked", kvs)
+}
+
+// resolve returns the absolute offset of the target, absolute offsets can be committed unlike the logical ones
+func (w *worker) resolve(t SeekTarget) (kafka.Offset, error) {
+	if !t.Timestamp.IsZero() {
+		tp := t.topicPartition()
+		tp.Offset = kafka.Offset(t.Timestamp.UnixMilli())
+		offsets, err := w.consumer.OffsetsForTimes([]kafka.TopicPartition{tp}, seekTimeoutMS)
+		if err != nil {
+			return 0, err
+		}
+		if len(offsets) != 1 {
+			return 0, fmt.Errorf("kafka: no offset found for %s[%d] at %v", t.Topic, t.Partition, t.Timestamp)
+		}
+		if offsets[0].Error != nil {
+			return 0, offsets[0].Error
+		}
+		// there is no message at or after the timestamp
+		if offsets[0].Offset != kafka.OffsetEnd {
+			return offsets[0].Offset, nil
+		}
+		t.Offset = kafka.OffsetEnd
+	}
+
+	switch t.Offset {
+	case kafka.OffsetBeginning, kafka.OffsetEnd:
+		low, high, err := w.consumer.QueryWatermarkOffsets(t.Topic, t.Partition, seekTimeoutMS)
+		if err != nil {
+			return 0, err
+		}
+		if t.Offset == kafka.OffsetBeginning {
+			return kafka.Offset(low), nil
+		}
+		return kafka.Offset(high), nil
+	default:
+		return t.Offset, nil
+	}
+}
+
+// releaseSeeks returns the targets of the revoked partitions which were not applied yet
+func (w *worker) releaseSeeks(partitions []kafka.TopicPartition) {
+	var released []SeekTarget
+	for _, tp := range partitions {
+		k := keyFor(tp)
+		if t, ok := w.seeking[k]; ok {
+			released = append(released, t)
+			delete(w.seeking, k)
+		}
+	}
+	if len(released) > 0 {
+		w.seeks.restore(released)
+	}
+}
+
+type seekResp struct {
+	Topic      string  `json:"topic"`
+	Partitions []int32 `json:"partitions"`
+}
+
+// seekTargetFrom reads the target from the query params, topic and exactly one of
+// to (earliest or latest), offset or timestamp (RFC3339) are required, partition defaults to every partition
+func seekTargetFrom(req *http.Request) (SeekTarget, error) {
+	q := req.URL.Query()
+	t := SeekTarget{Topic: q.Get("topic"), Partition: kafka.PartitionAny}
+	if t.Topic == "" {
+		return t, fmt.Errorf("expected query param: topic")
+	}
+	if p := q.Get("partition"); p != "" {
+		partition, err := strconv.ParseInt(p, 10, 32)
+		if err != nil || partition < 0 {
+			return t, fmt.Errorf("expected partition to be a non negative number: %q", p)
+		}
+		t.Partition = int32(partition)
+	}
+
+	var positions int
+	for _, k := range []string{"to", "offset", "timestamp"} {
+		if q.Has(k) {
+			positions++
+		}
+	}
+	if positions != 1 {
+		return t, fmt.Errorf("expected exactly one of the query params: to, offset or timestamp")
+	}
+	switch {
+	case q.Has("to"):
+		switch to := q.Get("to"); to {
+		case "earliest":
+			t.Offset = kafka.OffsetBeginning
+		case "latest":
+			t.Offset = kafka.OffsetEnd
+		default:
+			return t, fmt.Errorf("expected to to be earliest or latest: %q", to)
+		}
+	case q.Has("offset"):
+		offset, err := strconv.ParseInt(q.Get("offset"), 10, 64)
+		if err != nil || offset < 0 {
+			return t, fmt.Errorf("expected offset to be a non negative number: %q", q.Get("offset"))
+		}
+		t.Offset = kafka.Offset(offset)
+	default:
+		ts, err := time.Parse(time.RFC3339, q.Get("timestamp"))
+		if err != nil {
+			return t, fmt.Errorf("expected timestamp to be an RFC3339 time: %v", err)
+		}
+		t.Timestamp = ts
+	}
+	return t, nil
+}
+
+// SeekHandler seeks the group using Seek
+//
+//	POST /seek?topic=orders&to=earliest
+//	POST /seek?topic=orders&partition=3&offset=42
+//	POST /seek?topic=orders&timestamp=2024-03-25T00:00:00Z
+//
+// The response lists the partitions which are seeked once their in-flight events are acknowledged
+func (cg *ConsumerGroup) SeekHandler() http.Handler {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		target, err := seekTargetFrom(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		targets, err := cg.Seek(target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp := seekResp{Topic: target.Topic, Partitions: make([]int32, 0, len(targets))}
+		for _, t := range targets {
+			resp.Partitions = append(resp.Partitions, t.Partition)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(resp)
+		cg.Logger.Error("json encode error", err)
+	}
+	return http.HandlerFunc(f)
+}
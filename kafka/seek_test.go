@@ -0,0 +1,340 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+	"github.com/google/go-cmp/cmp"
+)
+
+// seekConsumer is assigned foo/0 and foo/1 and records the calls made while seeking
+type seekConsumer struct {
+	nopConsumer
+	mu    sync.Mutex
+	calls []string
+}
+
+func (sc *seekConsumer) record(call string, tp kafka.TopicPartition) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.calls = append(sc.calls, call+" "+tp.String())
+}
+
+func (sc *seekConsumer) recorded() []string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return append([]string(nil), sc.calls...)
+}
+
+func (sc *seekConsumer) Assignment() ([]kafka.TopicPartition, error) {
+	return []kafka.TopicPartition{{Topic: makePtr("foo")}, {Topic: makePtr("foo"), Partition: 1}}, nil
+}
+
+func (sc *seekConsumer) Pause(partitions []kafka.TopicPartition) error {
+	for _, tp := range partitions {
+		sc.record("pause", tp)
+	}
+	return nil
+}
+
+func (sc *seekConsumer) Resume(partitions []kafka.TopicPartition) error {
+	for _, tp := range partitions {
+		sc.record("resume", tp)
+	}
+	return nil
+}
+
+func (sc *seekConsumer) Seek(tp kafka.TopicPartition, _ int) error {
+	sc.record("seek", tp)
+	return nil
+}
+
+func (sc *seekConsumer) StoreOffsets(tps []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	for _, tp := range tps {
+		sc.record("store", tp)
+	}
+	return tps, nil
+}
+
+func (sc *seekConsumer) QueryWatermarkOffsets(string, int32, int) (int64, int64, error) {
+	return 5, 42, nil
+}
+
+func (sc *seekConsumer) OffsetsForTimes(tps []kafka.TopicPartition, _ int) ([]kafka.TopicPartition, error) {
+	tps[0].Offset = 17
+	return tps, nil
+}
+
+func (sc *seekConsumer) GetMetadata(topic *string, _ bool, _ int) (*kafka.Metadata, error) {
+	return &kafka.Metadata{Topics: map[string]kafka.TopicMetadata{
+		"foo": {Topic: "foo", Partitions: []kafka.PartitionMetadata{{ID: 0}, {ID: 1}}},
+	}}, nil
+}
+
+func newSeekWorker(sc *seekConsumer, cg *ConsumerGroup) *worker {
+	return &worker{
+		id:       "seek-worker",
+		consumer: sc,
+		logger:   logger.NOOP,
+		offsets:  newOffsetTracker(),
+		paused:   &cg.paused,
+		seeks:    &cg.seeks,
+	}
+}
+
+func TestWorker_ApplySeeks(t *testing.T) {
+	cases := []struct {
+		name   string
+		target SeekTarget
+		offset string
+	}{
+		{name: "earliest", target: SeekTarget{Topic: "foo", Offset: kafka.OffsetBeginning}, offset: "5"},
+		{name: "latest", target: SeekTarget{Topic: "foo", Offset: kafka.OffsetEnd}, offset: "42"},
+		{name: "offset", target: SeekTarget{Topic: "foo", Offset: 7}, offset: "7"},
+		{name: "timestamp", target: SeekTarget{Topic: "foo", Timestamp: time.Now()}, offset: "17"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cg := &ConsumerGroup{}
+			sc := &seekConsumer{}
+			w := newSeekWorker(sc, cg)
+			if _, err := cg.Seek(c.target); err != nil {
+				t.Fatalf("expected no error got %v", err)
+			}
+			w.applySeeks()
+			want := []string{
+				"pause foo[0]@unset",
+				"seek foo[0]@" + c.offset,
+				"store foo[0]@" + c.offset,
+				"resume foo[0]@unset",
+			}
+			if diff := cmp.Diff(want, sc.recorded()); diff != "" {
+				t.Errorf("unexpected calls (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWorker_ApplySeeksWaitsForInFlightEvents(t *testing.T) {
+	cg := &ConsumerGroup{}
+	sc := &seekConsumer{}
+	w := newSeekWorker(sc, cg)
+	inFlight := kafka.TopicPartition{Topic: makePtr("foo"), Offset: 3}
+	w.offsets.track(inFlight)
+
+	if _, err := cg.Seek(SeekTarget{Topic: "foo", Offset: 7}); err != nil {
+		t.Fatalf("expected no error got %v", err)
+	}
+	w.applySeeks()
+	if diff := cmp.Diff([]string{"pause foo[0]@unset"}, sc.recorded()); diff != "" {
+		t.Errorf("expected the partition to only be paused (-want +got):\n%s", diff)
+	}
+
+	w.offsets.complete(inFlight)
+	w.applySeeks()
+	want := []string{"pause foo[0]@unset", "seek foo[0]@7", "store foo[0]@7", "resume foo[0]@unset"}
+	if diff := cmp.Diff(want, sc.recorded()); diff != "" {
+		t.Errorf("unexpected calls (-want +got):\n%s", diff)
+	}
+}
+
+func TestWorker_ApplySeeksKeepsPausedPartitionsPaused(t *testing.T) {
+	cg := &ConsumerGroup{}
+	sc := &seekConsumer{}
+	w := newSeekWorker(sc, cg)
+	foo0 := kafka.TopicPartition{Topic: makePtr("foo")}
+	cg.paused.partitions = map[partitionKey]kafka.TopicPartition{keyFor(foo0): foo0}
+
+	if _, err := cg.Seek(SeekTarget{Topic: "foo", Offset: 7}); err != nil {
+		t.Fatalf("expected no error got %v", err)
+	}
+	w.applySeeks()
+	want := []string{"pause foo[0]@unset", "seek foo[0]@7", "store foo[0]@7"}
+	if diff := cmp.Diff(want, sc.recorded()); diff != "" {
+		t.Errorf("expected the paused partition not to be resumed (-want +got):\n%s", diff)
+	}
+}
+
+func TestConsumerGroup_SeekRevokedPartitions(t *testing.T) {
+	cg := &ConsumerGroup{Logger: logger.NOOP}
+	sc := &seekConsumer{}
+	w := newSeekWorker(sc, cg)
+	foo0 := kafka.TopicPartition{Topic: makePtr("foo")}
+	w.offsets.track(kafka.TopicPartition{Topic: makePtr("foo"), Offset: 3})
+
+	if _, err := cg.Seek(SeekTarget{Topic: "foo", Offset: 7}); err != nil {
+		t.Fatalf("expected no error got %v", err)
+	}
+	w.applySeeks()
+	ctx, cfn := context.WithCancel(context.Background())
+	cfn()
+	cg.handleRebalance(ctx, &fakeRebalanceConsumer{}, w, kafka.RevokedPartitions{Partitions: []kafka.TopicPartition{foo0}})
+	if len(w.seeking) != 0 {
+		t.Errorf("expected the revoked partition not to be seeked by the worker got %v", w.seeking)
+	}
+
+	// the next owner of the partition applies the target once it is assigned
+	next := &seekConsumer{}
+	nw := newSeekWorker(next, cg)
+	nw.seekVersion = cg.seeks.version.Load()
+	cg.handleRebalance(context.Background(), &fakeRebalanceConsumer{}, nw, kafka.AssignedPartitions{Partitions: []kafka.TopicPartition{foo0}})
+	nw.applySeeks()
+	want := []string{"pause foo[0]@unset", "seek foo[0]@7", "store foo[0]@7", "resume foo[0]@unset"}
+	if diff := cmp.Diff(want, next.recorded()); diff != "" {
+		t.Errorf("unexpected calls (-want +got):\n%s", diff)
+	}
+}
+
+func TestConsumerGroup_Seek(t *testing.T) {
+	t.Run("rejects invalid targets", func(t *testing.T) {
+		cg := &ConsumerGroup{}
+		for _, target := range []SeekTarget{
+			{Partition: 0},
+			{Topic: "foo", Partition: -5},
+			{Topic: "foo", Offset: -7},
+		} {
+			if _, err := cg.Seek(target); err == nil {
+				t.Errorf("expected an error for %+v", target)
+			}
+		}
+	})
+
+	t.Run("expands a topic wide target to every partition", func(t *testing.T) {
+		cg := &ConsumerGroup{}
+		cg.workers = []*worker{newSeekWorker(&seekConsumer{}, cg)}
+		targets, err := cg.Seek(SeekTarget{Topic: "foo", Partition: kafka.PartitionAny, Offset: kafka.OffsetBeginning})
+		if err != nil {
+			t.Fatalf("expected no error got %v", err)
+		}
+		want := []SeekTarget{
+			{Topic: "foo", Partition: 0, Offset: kafka.OffsetBeginning},
+			{Topic: "foo", Partition: 1, Offset: kafka.OffsetBeginning},
+		}
+		if diff := cmp.Diff(want, targets); diff != "" {
+			t.Errorf("unexpected targets (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("a topic wide target requires a consuming group", func(t *testing.T) {
+		cg := &ConsumerGroup{}
+		if _, err := cg.Seek(SeekTarget{Topic: "foo", Partition: kafka.PartitionAny}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestConsumerGroup_SeekHandler(t *testing.T) {
+	cg := &ConsumerGroup{Logger: logger.NOOP}
+	cg.workers = []*worker{newSeekWorker(&seekConsumer{}, cg)}
+
+	cases := []struct {
+		query  string
+		status int
+	}{
+		{query: "topic=foo&to=earliest", status: http.StatusOK},
+		{query: "topic=foo&partition=1&offset=42", status: http.StatusOK},
+		{query: "topic=foo&timestamp=2024-03-25T00:00:00Z", status: http.StatusOK},
+		{query: "to=earliest", status: http.StatusBadRequest},
+		{query: "topic=foo", status: http.StatusBadRequest},
+		{query: "topic=foo&to=oldest", status: http.StatusBadRequest},
+		{query: "topic=foo&to=latest&offset=3", status: http.StatusBadRequest},
+		{query: "topic=foo&partition=-1&to=latest", status: http.StatusBadRequest},
+		{query: "topic=foo&timestamp=yesterday", status: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		cg.SeekHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/seek?"+c.query, nil))
+		if rec.Code != c.status {
+			t.Errorf("%s: expected status %d got %d: %s", c.query, c.status, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	cg.SeekHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/seek?topic=foo&to=latest", nil))
+	var resp seekResp
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("expected no error got %v", err)
+	}
+	if diff := cmp.Diff(seekResp{Topic: "foo", Partitions: []int32{0, 1}}, resp); diff != "" {
+		t.Errorf("unexpected response (-want +got):\n%s", diff)
+	}
+}
+
+func TestConsumerGroup_SeekToEarliest(t *testing.T) {
+	mc := newMockCluster(t)
+	createTopics(t, mc.BootstrapServers(), "seek")
+	p, err := NewProducer(ProducerConfig{BootstrapServers: mc.BootstrapServers()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []string{"a", "b", "c"}
+	for _, v := range values {
+		// the same key sends every event to the same partition
+		if _, err := p.Send(context.Background(), "seek", &ziggurat.Event{Key: []byte("k"), Value: []byte(v)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	var mu sync.Mutex
+	var handled []string
+	consumed := make(chan struct{})
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, string(event.Value))
+		switch len(handled) {
+		case len(values):
+			close(consumed)
+		case 2 * len(values):
+			cfn()
+		}
+	})
+
+	cg := ConsumerGroup{GroupConfig: ConsumerConfig{
+		BootstrapServers: mc.BootstrapServers(),
+		GroupID:          "seek-group",
+		Topics:           []string{"seek"},
+		ConsumerCount:    1,
+		AutoOffsetReset:  "earliest",
+	}}
+	done := make(chan error, 1)
+	go func() {
+		done <- cg.Consume(ctx, h)
+	}()
+	select {
+	case <-consumed:
+	case <-time.After(20 * time.Second):
+		t.Fatal("timed out waiting for the events")
+	}
+	if _, err := cg.Seek(SeekTarget{Topic: "seek", Partition: kafka.PartitionAny, Offset: kafka.OffsetBeginning}); err != nil {
+		t.Fatalf("expected no error got %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrCleanShutdown) {
+			t.Fatalf("expected a clean shutdown got %v", err)
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatal("timed out waiting for the events to be consumed again")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if diff := cmp.Diff([]string{"a", "b", "c", "a", "b", "c"}, handled); diff != "" {
+		t.Errorf("unexpected events (-want +got):\n%s", diff)
+	}
+}
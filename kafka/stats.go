@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StatsHook is invoked with the statistics of a worker's consumer every StatisticsIntervalMS,
+// it is invoked from the poll loop of the worker
+type StatsHook func(stats Stats)
+
+// Stats holds a subset of the librdkafka statistics
+// https://github.com/confluentinc/librdkafka/blob/master/STATISTICS.md
+type Stats struct {
+	// Name is the name of the client instance, it is unique per consumer
+	Name          string                 `json:"name"`
+	ClientID      string                 `json:"client_id"`
+	Brokers       map[string]BrokerStats `json:"brokers"`
+	Topics        map[string]TopicStats  `json:"topics"`
+	ConsumerGroup ConsumerGroupStats     `json:"cgrp"`
+}
+
+type BrokerStats struct {
+	Name   string `json:"name"`
+	NodeID int32  `json:"nodeid"`
+	State  string `json:"state"`
+	// RTT is the round trip time of the broker requests in microseconds
+	RTT WindowStats `json:"rtt"`
+}
+
+// WindowStats holds the rolling window statistics of a value
+type WindowStats struct {
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+	Avg int64 `json:"avg"`
+	P50 int64 `json:"p50"`
+	P95 int64 `json:"p95"`
+	P99 int64 `json:"p99"`
+}
+
+type TopicStats struct {
+	Topic string `json:"topic"`
+	// Partitions is keyed by the partition id, the internal unassigned partition is -1
+	Partitions map[string]PartitionStats `json:"partitions"`
+}
+
+type PartitionStats struct {
+	Partition int32 `json:"partition"`
+	// FetchQueueCount is the number of pre-fetched messages and FetchQueueSize their size in bytes
+	FetchQueueCount int64 `json:"fetchq_cnt"`
+	FetchQueueSize  int64 `json:"fetchq_size"`
+	// ConsumerLag is the difference between the high watermark and the committed offset, -1 when unknown
+	ConsumerLag       int64 `json:"consumer_lag"`
+	ConsumerLagStored int64 `json:"consumer_lag_stored"`
+	CommittedOffset   int64 `json:"committed_offset"`
+	HighOffset        int64 `json:"hi_offset"`
+}
+
+type ConsumerGroupStats struct {
+	State          string `json:"state"`
+	RebalanceCount int64  `json:"rebalance_cnt"`
+	AssignmentSize int    `json:"assignment_size"`
+}
+
+// ParseStats parses the JSON of a kafka.Stats event
+func ParseStats(statsJSON string) (Stats, error) {
+	var s Stats
+	if err := json.Unmarshal([]byte(statsJSON), &s); err != nil {
+		return Stats{}, fmt.Errorf("kafka: error parsing statistics: %w", err)
+	}
+	return s, nil
+}
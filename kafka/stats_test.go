@@ -0,0 +1,106 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+)
+
+const statsJSON = `{
+  "name": "rdkafka#consumer-1",
+  "client_id": "ziggurat",
+  "brokers": {
+    "localhost:9092/1": {"name": "localhost:9092/1", "nodeid": 1, "state": "UP", "rtt": {"min": 100, "max": 900, "avg": 250, "p50": 200, "p95": 800, "p99": 880}}
+  },
+  "topics": {
+    "foo": {
+      "topic": "foo",
+      "partitions": {
+        "0": {"partition": 0, "fetchq_cnt": 12, "fetchq_size": 2048, "consumer_lag": 40, "consumer_lag_stored": 38, "committed_offset": 60, "hi_offset": 100},
+        "-1": {"partition": -1, "fetchq_cnt": 0, "fetchq_size": 0, "consumer_lag": -1, "consumer_lag_stored": -1, "committed_offset": -1001, "hi_offset": -1001}
+      }
+    }
+  },
+  "cgrp": {"state": "up", "rebalance_cnt": 3, "assignment_size": 1}
+}`
+
+func TestParseStats(t *testing.T) {
+	s, err := ParseStats(statsJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "rdkafka#consumer-1" || s.ClientID != "ziggurat" {
+		t.Errorf("unexpected client %q %q", s.Name, s.ClientID)
+	}
+	if rtt := s.Brokers["localhost:9092/1"].RTT; rtt.Avg != 250 || rtt.P99 != 880 {
+		t.Errorf("unexpected broker rtt %+v", rtt)
+	}
+	p := s.Topics["foo"].Partitions["0"]
+	if p.ConsumerLag != 40 || p.FetchQueueCount != 12 || p.FetchQueueSize != 2048 || p.HighOffset != 100 {
+		t.Errorf("unexpected partition stats %+v", p)
+	}
+	if s.ConsumerGroup.RebalanceCount != 3 || s.ConsumerGroup.AssignmentSize != 1 {
+		t.Errorf("unexpected consumer group stats %+v", s.ConsumerGroup)
+	}
+
+	if _, err := ParseStats("{"); err == nil {
+		t.Error("expected an error for invalid statistics")
+	}
+}
+
+func TestWorker_PublishStats(t *testing.T) {
+	var got []Stats
+	w := &worker{logger: logger.NOOP, onStats: func(s Stats) {
+		got = append(got, s)
+	}}
+	w.publishStats("{")
+	w.publishStats(statsJSON)
+	if len(got) != 1 || got[0].Name != "rdkafka#consumer-1" {
+		t.Errorf("expected only the valid statistics to be published got %+v", got)
+	}
+}
+
+func TestConsumerGroup_OnStats(t *testing.T) {
+	mc := newMockCluster(t)
+	createTopics(t, mc.BootstrapServers(), "stats")
+
+	stats := make(chan Stats, 1)
+	cg := ConsumerGroup{
+		GroupConfig: ConsumerConfig{
+			BootstrapServers:     mc.BootstrapServers(),
+			GroupID:              "stats-group",
+			Topics:               []string{"stats"},
+			ConsumerCount:        1,
+			StatisticsIntervalMS: 100,
+		},
+		OnStats: func(s Stats) {
+			select {
+			case stats <- s:
+			default:
+			}
+		},
+	}
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	done := make(chan error, 1)
+	go func() {
+		done <- cg.Consume(ctx, ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {}))
+	}()
+
+	select {
+	case s := <-stats:
+		if s.Name == "" {
+			t.Error("expected the statistics to name the client")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected statistics within 5 seconds")
+	}
+	cfn()
+	if err := <-done; !errors.Is(err, ErrCleanShutdown) {
+		t.Errorf("expected a clean shutdown got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+)
+
+// txTimeout bounds the transactional operations of a worker, they are not cancelled on shutdown
+// so that the transaction of the last message is either committed or aborted.
+// It also bounds the wait for the acknowledgement of the event so that the transaction is not timed out by the broker
+const txTimeout = 30 * time.Second
+
+// ErrAckTimeout aborts the transaction of an event which was not acknowledged in time,
+// the transactional mode requires the handler to acknowledge the event before it returns
+var ErrAckTimeout = errors.New("kafka: event was not acknowledged in time")
+
+type transactionCtxKey struct{}
+
+// TransactionalProducer sends events in transactions, the events sent in a transaction
+// and the consumer offsets sent with SendOffsets are committed or aborted atomically
+//
+// Send and SendAsync must be called between Begin and Commit or Abort
+type TransactionalProducer struct {
+	*Producer
+}
+
+// NewTransactionalProducer creates a producer with the TransactionalID of the config and initializes its transactions,
+// an earlier producer with the same TransactionalID is fenced and its open transaction is aborted
+func NewTransactionalProducer(ctx context.Context, config ProducerConfig, opts ...ProducerOpts) (*TransactionalProducer, error) {
+	if config.TransactionalID == "" {
+		return nil, errors.New("kafka: TransactionalID is required for a transactional producer")
+	}
+	p, err := NewProducer(config, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.p.InitTransactions(ctx); err != nil {
+		_ = p.Close()
+		return nil, fmt.Errorf("kafka: error initializing transactions: %w", err)
+	}
+	return &TransactionalProducer{Producer: p}, nil
+}
+
+func (tp *TransactionalProducer) Begin() error {
+	return tp.p.BeginTransaction()
+}
+
+// SendOffsets adds the consumer offsets to the transaction, the offsets are those of the next messages to consume
+// the consumer group metadata fences the transaction once the partitions are assigned to another consumer
+func (tp *TransactionalProducer) SendOffsets(ctx context.Context, offsets []kafka.TopicPartition, cgm *kafka.ConsumerGroupMetadata) error {
+	return tp.p.SendOffsetsToTransaction(ctx, offsets, cgm)
+}
+
+// Commit flushes the events of the transaction and commits it, retriable errors are retried until ctx is done
+func (tp *TransactionalProducer) Commit(ctx context.Context) error {
+	for {
+		err := tp.p.CommitTransaction(ctx)
+		var kerr kafka.Error
+		if err == nil || !errors.As(err, &kerr) || !kerr.IsRetriable() || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// Abort purges the events of the transaction which were not delivered and aborts it
+func (tp *TransactionalProducer) Abort(ctx context.Context) error {
+	return tp.p.AbortTransaction(ctx)
+}
+
+// TransactionFrom returns the producer of the transaction in which the event is processed
+// the ConsumerGroup sets it when the GroupConfig has a TransactionalID
+func TransactionFrom(ctx context.Context) (*TransactionalProducer, bool) {
+	tp, ok := ctx.Value(transactionCtxKey{}).(*TransactionalProducer)
+	return tp, ok
+}
+
+// requiresAbort reports whether the transaction can be aborted and retried after the error,
+// other errors like a fenced producer are fatal
+func requiresAbort(err error) bool {
+	var kerr kafka.Error
+	if errors.As(err, &kerr) {
+		return !kerr.IsFatal()
+	}
+	return true
+}
+
+// processTransaction processes the message in a transaction which commits the events sent by the handler
+// along with the offset of the message, the worker waits for the event to be acknowledged before polling again.
+// An event which is not acknowledged within the ack timeout or before shutdown aborts the transaction
+func (w *worker) processTransaction(ctx context.Context, msg *kafka.Message) error {
+	if err := w.tx.Begin(); err != nil {
+		return fmt.Errorf("kafka: error beginning transaction: %w", err)
+	}
+
+	m := &message{}
+	m.reset()
+	w.fillEvent(m, msg)
+	e := &m.event
+	done := make(chan error, 1)
+	actx, ack := ziggurat.WithAck(context.WithValue(ctx, transactionCtxKey{}, w.tx), func(err error) {
+		done <- err
+	})
+	w.handler.Handle(actx, e)
+	ack.Release()
+
+	err := w.awaitAck(ctx, done)
+
+	tctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), txTimeout)
+	defer cancel()
+	if err == nil {
+		err = w.commitTransaction(tctx, msg.TopicPartition)
+	}
+	if err == nil {
+		return nil
+	}
+	return w.abortTransaction(tctx, msg.TopicPartition, err)
+}
+
+// awaitAck waits for the acknowledgement of the transaction's event, it returns ErrAckTimeout
+// once the ack timeout is reached and the error of ctx once the worker is shut down
+func (w *worker) awaitAck(ctx context.Context, done chan error) error {
+	// the event is usually acknowledged before the handler returns
+	select {
+	case err := <-done:
+		return err
+	default:
+	}
+	timeout := txTimeout
+	if w.ackTimeout > 0 {
+		timeout = min(timeout, w.ackTimeout)
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrAckTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *worker) commitTransaction(ctx context.Context, tp kafka.TopicPartition) error {
+	cgm, err := w.consumer.GetConsumerGroupMetadata()
+	if err != nil {
+		return err
+	}
+	tp.Offset++
+	if err := w.tx.SendOffsets(ctx, []kafka.TopicPartition{tp}, cgm); err != nil {
+		return err
+	}
+	return w.tx.Commit(ctx)
+}
+
+// abortTransaction aborts the transaction and schedules the redelivery of the message after a backoff,
+// the returned error stops the worker, as does a message which failed more than MaxRedeliveries times
+func (w *worker) abortTransaction(ctx context.Context, tp kafka.TopicPartition, cause error) error {
+	w.logger.Error("aborting kafka transaction", cause, map[string]any{
+		"Worker-ID": w.id,
+		"topic":     topicName(tp),
+		"partition": tp.Partition,
+		"offset":    tp.Offset,
+	})
+	if !requiresAbort(cause) {
+		return fmt.Errorf("kafka: transaction failed: %w", cause)
+	}
+	if err := w.tx.Abort(ctx); err != nil {
+		return fmt.Errorf("kafka: error aborting transaction: %w", err)
+	}
+	return w.redelivery.schedule(tp)
+}
@@ -0,0 +1,271 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConsumerGroup_Transactional(t *testing.T) {
+	mc := newMockCluster(t)
+	createTopics(t, mc.BootstrapServers(), "ledger-in", "ledger-out")
+	values := []string{"a", "b", "c", "d", "e"}
+
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	var mu sync.Mutex
+	handled := map[string]int{}
+	var total int
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		tx, ok := TransactionFrom(ctx)
+		if !ok {
+			t.Error("expected a transactional producer in the context")
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		handled[string(event.Value)]++
+		total++
+		// the output carries the attempt so that the events of aborted transactions can be told apart
+		out := &ziggurat.Event{Key: event.Key, Value: []byte(fmt.Sprintf("out-%s-%d", event.Value, handled[string(event.Value)]))}
+		if _, err := tx.Send(ctx, "ledger-out", out); err != nil {
+			t.Errorf("send error: %v", err)
+		}
+		// the first attempt of c fails after sending, the sent event is aborted
+		if string(event.Value) == "c" && handled["c"] == 1 {
+			ack, _ := ziggurat.DeferAck(ctx)
+			ack(errors.New("ledger unavailable"))
+		}
+		if total == len(values)+1 {
+			cfn()
+		}
+	})
+
+	cg := ConsumerGroup{GroupConfig: ConsumerConfig{
+		BootstrapServers: mc.BootstrapServers(),
+		GroupID:          "ledger",
+		Topics:           []string{"ledger-in"},
+		ConsumerCount:    2,
+		AutoOffsetReset:  "earliest",
+		TransactionalID:  "ledger-tx",
+		// c is redelivered after the backoff
+		RedeliveryBackoffMS: 10,
+	}}
+	// the mock cluster rejects the SyncGroup of a follower which arrives before the assignment of the leader,
+	// the follower rejoins and the mock holds the join until the rebalance timeout of the members,
+	// which is max.poll.interval.ms. A short timeout lets the group recover within the test
+	var consumers []*kafka.Consumer
+	cg.consumerMakeFunc = func(cm *kafka.ConfigMap, topics []string, cb kafka.RebalanceCb) confluentConsumer {
+		_ = cm.SetKey("session.timeout.ms", 10000)
+		_ = cm.SetKey("max.poll.interval.ms", 10000)
+		c := createConsumer(cm, topics, cb)
+		mu.Lock()
+		defer mu.Unlock()
+		consumers = append(consumers, c.(*kafka.Consumer))
+		return c
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cg.Consume(ctx, h)
+	}()
+
+	// the mock cluster does not commit the offsets sent in transactions, the events are produced
+	// once every worker has its partitions so that a rebalance does not process them again
+	waitFor(t, 30*time.Second, "expected the partitions to be assigned to both workers", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(consumers) != 2 {
+			return false
+		}
+		for _, c := range consumers {
+			if partitions, err := c.Assignment(); err != nil || len(partitions) != 2 {
+				return false
+			}
+		}
+		return true
+	})
+	p, err := NewProducer(ProducerConfig{BootstrapServers: mc.BootstrapServers()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range values {
+		if _, err := p.Send(context.Background(), "ledger-in", &ziggurat.Event{Key: []byte(v), Value: []byte(v)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrCleanShutdown) {
+			t.Fatalf("expected a clean shutdown got %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("timed out waiting for the consumer group")
+	}
+	if diff := cmp.Diff(map[string]int{"a": 1, "b": 1, "c": 2, "d": 1, "e": 1}, handled); diff != "" {
+		t.Errorf("expected every event to be processed once and c twice (-want +got):\n%s", diff)
+	}
+
+	// the mock cluster neither filters the events of aborted transactions nor writes transaction markers,
+	// every event of the topic is read and the aborted out-c-1 is dropped if it was delivered before the abort
+	var got []string
+	for _, m := range readMessages(t, mc.BootstrapServers(), "ledger-out", topicSize(t, mc.BootstrapServers(), "ledger-out")) {
+		if v := string(m.Value); v != "out-c-1" {
+			got = append(got, v)
+		}
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{"out-a-1", "out-b-1", "out-c-2", "out-d-1", "out-e-1"}, got); diff != "" {
+		t.Errorf("expected every event to be committed once (-want +got):\n%s", diff)
+	}
+}
+
+func TestConsumerGroup_TransactionalPoisonMessage(t *testing.T) {
+	mc := newMockCluster(t)
+	createTopics(t, mc.BootstrapServers(), "poison-in")
+	p, err := NewProducer(ProducerConfig{BootstrapServers: mc.BootstrapServers()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Send(context.Background(), "poison-in", &ziggurat.Event{Value: []byte("poison")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cfn := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cfn()
+	var attempts []time.Time
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		attempts = append(attempts, time.Now())
+		ack, _ := ziggurat.DeferAck(ctx)
+		ack(errors.New("invalid ledger entry"))
+	})
+	cg := ConsumerGroup{GroupConfig: ConsumerConfig{
+		BootstrapServers:    mc.BootstrapServers(),
+		GroupID:             "poison",
+		Topics:              []string{"poison-in"},
+		ConsumerCount:       1,
+		AutoOffsetReset:     "earliest",
+		TransactionalID:     "poison-tx",
+		MaxRedeliveries:     2,
+		RedeliveryBackoffMS: 50,
+	}}
+	err = cg.Consume(ctx, h)
+	if err == nil || !strings.Contains(err.Error(), ErrRedeliveriesExhausted.Error()) {
+		t.Fatalf("expected %v got %v", ErrRedeliveriesExhausted, err)
+	}
+	// the message is processed once and redelivered twice after a backoff which doubles
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 attempts got %d", len(attempts))
+	}
+	for i, backoff := range []time.Duration{50 * time.Millisecond, 100 * time.Millisecond} {
+		if d := attempts[i+1].Sub(attempts[i]); d < backoff {
+			t.Errorf("expected redelivery %d after at least %v got %v", i+1, backoff, d)
+		}
+	}
+}
+
+func waitFor(t *testing.T, timeout time.Duration, msg string, f func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// topicSize returns the number of events of the topic
+func topicSize(t *testing.T, bootstrap, topic string) int {
+	t.Helper()
+	p, err := kafka.NewProducer(&kafka.ConfigMap{"bootstrap.servers": bootstrap})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	md, err := p.GetMetadata(&topic, false, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for _, pm := range md.Topics[topic].Partitions {
+		low, high, err := p.QueryWatermarkOffsets(topic, pm.ID, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n += int(high - low)
+	}
+	return n
+}
+
+func TestWorker_TransactionAckTimeout(t *testing.T) {
+	mc := newMockCluster(t)
+	tx, err := NewTransactionalProducer(context.Background(), ProducerConfig{BootstrapServers: mc.BootstrapServers(), TransactionalID: "ack-timeout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Close()
+
+	// the handler defers the ack of the event and never acknowledges it, like a batch which waits for more events
+	w := newRedeliveryWorker(&rewindConsumer{}, func(ctx context.Context, event *ziggurat.Event) {
+		_, _ = ziggurat.DeferAck(ctx)
+	})
+	w.tx, w.ackTimeout = tx, 50*time.Millisecond
+	w.init()
+	tp := kafka.TopicPartition{Topic: makePtr("foo"), Offset: 3}
+	start := time.Now()
+	if err := w.processTransaction(context.Background(), &kafka.Message{TopicPartition: tp}); err != nil {
+		t.Fatalf("expected the transaction to be aborted got %v", err)
+	}
+	if d := time.Since(start); d < w.ackTimeout {
+		t.Errorf("expected the worker to wait for the ack timeout got %v", d)
+	}
+	if !w.redelivery.redelivering(tp) {
+		t.Error("expected the event to be redelivered")
+	}
+}
+
+func TestConsumerGroup_TransactionalProducerConfig(t *testing.T) {
+	cg := ConsumerGroup{GroupConfig: ConsumerConfig{
+		BootstrapServers: "localhost:9092",
+		TransactionalID:  "ledger-tx",
+		SASL:             &SASLConfig{Mechanism: SASLMechanismOAuthBearer},
+		Overrides: kafka.ConfigMap{
+			"sasl.oauthbearer.method":    "oidc",
+			"sasl.oauthbearer.client.id": "ledger",
+		},
+	}}
+	cm := cg.transactionalProducerConfig(1).toConfigMap()
+	for k, want := range map[string]kafka.ConfigValue{
+		"bootstrap.servers":          "localhost:9092",
+		"transactional.id":           "ledger-tx-1",
+		"sasl.mechanism":             "OAUTHBEARER",
+		"sasl.oauthbearer.method":    "oidc",
+		"sasl.oauthbearer.client.id": "ledger",
+	} {
+		if got := cm[k]; got != want {
+			t.Errorf("expected %s to be %v got %v", k, want, got)
+		}
+	}
+}
+
+func TestNewTransactionalProducer_RequiresID(t *testing.T) {
+	if _, err := NewTransactionalProducer(context.Background(), ProducerConfig{BootstrapServers: "localhost:9092"}); err == nil {
+		t.Error("expected an error without a TransactionalID")
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"github.com/gojekfarm/ziggurat/v2"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 )
@@ -25,16 +28,86 @@ type worker struct {
 	killSig     chan struct{}
 	id          string
 	err         error
+	inflight    sync.WaitGroup
+	offsets     *offsetTracker
+	messages    sync.Pool
+	routes      map[partitionKey]*route
+	// tx is set in the transactional mode, ackTimeout bounds the wait for the acknowledgement of a transaction's event
+	tx         *TransactionalProducer
+	ackTimeout time.Duration
+	// paused is shared by the workers of a group, nil disables pausing
+	paused        *pausedPartitions
+	highWaterMark int
+	lowWaterMark  int
+	// throttled is set while the partitions are paused by backpressure
+	throttled atomic.Bool
+	// assigned is set by the rebalance callback so that the pauses are applied to the new assignment
+	assigned bool
+	// resumes is the last ConsumerGroup.Resume applied by the worker
+	resumes uint64
+	onStats StatsHook
+	tokens  TokenProvider
+	// seeks is shared by the workers of a group, seeking holds the claimed targets
+	// which are applied once the in-flight events of their partitions are acknowledged
+	seeks       *pendingSeeks
+	seekVersion uint64
+	// seeksAssigned is set by the rebalance callback so that the pending targets of the new assignment are claimed
+	seeksAssigned bool
+	seeking       map[partitionKey]SeekTarget
+	// redelivery pauses the partitions of failed events until they are redelivered,
+	// zero maxRedeliveries and redeliveryBackoff use the defaults
+	redelivery        redeliverer
+	maxRedeliveries   int
+	redeliveryBackoff time.Duration
+}
+
+func (w *worker) init() {
+	if w.offsets == nil {
+		w.offsets = newOffsetTracker()
+	}
+	w.redelivery = redeliverer{
+		consumer:        w.consumer,
+		offsets:         w.offsets,
+		logger:          w.logger,
+		workerID:        w.id,
+		maxRedeliveries: w.maxRedeliveries,
+		backoff:         w.redeliveryBackoff,
+		seeking: func(k partitionKey) bool {
+			_, ok := w.seeking[k]
+			return ok
+		},
+		resume: w.resumePartition,
+	}
+	w.redelivery.init()
+	w.routes = map[partitionKey]*route{}
+	w.messages.New = func() any {
+		m := &message{}
+		m.reset()
+		m.done = func(err error) {
+			w.acknowledge(m, err)
+			m.reset()
+			w.messages.Put(m)
+		}
+		return m
+	}
 }
 
 func (w *worker) run(ctx context.Context) {
+	w.init()
 
 	defer func() {
-		_, err := w.consumer.Commit()
-		if err != nil {
-			w.logger.Error("pre-close commit error", err, map[string]interface{}{"Worker-ID": w.id})
+		if w.tx != nil {
+			// the offsets are committed by the transactions
+			w.logger.Error("error closing transactional producer", w.tx.Close(), map[string]any{"Worker-ID": w.id})
+		} else {
+			// wait for the deferred acknowledgements to store their offsets
+			w.inflight.Wait()
+			_, err := w.consumer.Commit()
+			if err != nil {
+				w.logger.Error("pre-close commit error", err, map[string]interface{}{"Worker-ID": w.id})
+			}
 		}
-		err = w.consumer.Close()
+		err := w.consumer.Close()
 		w.logger.Error("error closing kafka consumer", err, map[string]interface{}{"Worker-ID": w.id})
 	}()
 
@@ -60,12 +133,25 @@ func (w *worker) run(ctx context.Context) {
 			w.err = ErrorWorkerKilled{workerID: w.id}
 			run = false
 		default:
+			if err := w.redelivery.apply(); err != nil {
+				w.err = err
+				run = false
+				break
+			}
+			w.applyPauses()
+			w.applySeeks()
 			ev := w.consumer.Poll(w.pollTimeout)
 			switch e := ev.(type) {
 			case *kafka.Message:
-				processMessage(ctx, e, w.handler, w.routeGroup)
-				if err := storeOffsets(w.consumer, e.TopicPartition); err != nil {
-					w.logger.Error("error storing offsets locally", err)
+				if w.redelivery.redelivering(e.TopicPartition) {
+					// the message was fetched before the partition was paused, it is consumed again once the partition is rewound
+					break
+				}
+				if w.tx == nil {
+					w.processMessage(ctx, e)
+				} else if err := w.processTransaction(ctx, e); err != nil {
+					w.err = err
+					run = false
 				}
 			case kafka.Error:
 				if e.IsFatal() {
@@ -73,7 +159,10 @@ func (w *worker) run(ctx context.Context) {
 					run = false
 				}
 				w.logger.Error("kafka poll error", e)
-				// handle case `kafka.Stats`
+			case *kafka.Stats:
+				w.publishStats(e.String())
+			case kafka.OAuthBearerTokenRefresh:
+				w.logger.Error("kafka oauthbearer token refresh error", refreshToken(ctx, w.consumer, w.tokens), map[string]any{"Worker-ID": w.id})
 			default:
 				// do nothing
 			}
@@ -81,6 +170,39 @@ func (w *worker) run(ctx context.Context) {
 	}
 }
 
+// acknowledge stores the offset of the message once it is acknowledged
+// events can be acknowledged out of order, only the offsets below which
+// every event has been acknowledged are stored. An event which fails to be processed
+// is never marked as complete, this stops the offsets of its partition from moving forward
+// until the worker rewinds the partition to redeliver the event
+func (w *worker) acknowledge(m *message, err error) {
+	defer w.inflight.Done()
+	if err != nil {
+		w.logger.Error("event processing failed, not storing offsets", err, map[string]any{"Worker-ID": w.id})
+		w.offsets.fail(m.tp)
+		return
+	}
+	storeTP, ok := w.offsets.complete(m.tp)
+	if !ok {
+		return
+	}
+	if err := storeOffsetsIn(w.consumer, m.stored[:], storeTP); err != nil {
+		w.logger.Error("error storing offsets locally", err)
+	}
+}
+
+func (w *worker) publishStats(statsJSON string) {
+	if w.onStats == nil {
+		return
+	}
+	stats, err := ParseStats(statsJSON)
+	if err != nil {
+		w.logger.Error("kafka statistics error", err, map[string]any{"Worker-ID": w.id})
+		return
+	}
+	w.onStats(stats)
+}
+
 func (w *worker) kill() {
 	w.killSig <- struct{}{}
 }
@@ -140,4 +140,51 @@ func TestWorker(t *testing.T) {
 
 	})
 
+	t.Run("offsets are stored only for contiguously acknowledged events", func(t *testing.T) {
+		mc := MockConsumer{}
+		acks := make(chan ziggurat.AckFunc, 3)
+		w := worker{
+			handler: ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+				ack, ok := ziggurat.DeferAck(ctx)
+				if !ok {
+					t.Error("expected the worker to support deferred acks")
+				}
+				acks <- ack
+			}),
+			logger:      logger.NOOP,
+			consumer:    &mc,
+			routeGroup:  "foo",
+			pollTimeout: 100,
+			killSig:     make(chan struct{}),
+			id:          "foo-worker",
+		}
+
+		topic := "foo"
+		mc.On("Logs").Return(make(chan kafka.LogEvent))
+		mc.On("Commit").Return([]kafka.TopicPartition{}, nil)
+		for _, o := range []kafka.Offset{5, 6, 7} {
+			mc.On("Poll", 100).Return(&kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: o},
+			}).Once()
+		}
+		mc.On("Poll", 100).Return(kafka.PartitionEOF{})
+		mc.On("StoreOffsets", []kafka.TopicPartition{{Topic: &topic, Partition: 1, Offset: 7}}).Return([]kafka.TopicPartition{}, nil)
+		mc.On("Close").Return(nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		go func() {
+			<-ctx.Done()
+			a5, a6, a7 := <-acks, <-acks, <-acks
+			a6(nil)
+			a5(nil)
+			// offset 7 fails, its offset must never be stored
+			a7(errors.New("flush failed"))
+		}()
+		w.run(ctx)
+
+		mc.AssertNumberOfCalls(t, "StoreOffsets", 1)
+		mc.AssertCalled(t, "StoreOffsets", []kafka.TopicPartition{{Topic: &topic, Partition: 1, Offset: 7}})
+	})
+
 }
@@ -0,0 +1,79 @@
+package mqtt
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+type ConsumerConfig struct {
+	// Brokers is a list of broker URLs, for example tcp://localhost:1883
+	Brokers  []string
+	Username string
+	Password string
+	// ClientID is the prefix of the client IDs, every client is named <ClientID>_<n>
+	// stable client IDs are required for the broker to persist the sessions
+	ClientID string
+	// Topics is a list of topic filters which may contain the + and # wildcards
+	Topics []string
+	// SharedGroup subscribes to $share/<SharedGroup>/<topic> so that the messages are
+	// load balanced across the clients of the group, every client receives every message when empty
+	SharedGroup string
+	// ConsumerCount is the number of clients, it should be 1 unless SharedGroup is set
+	ConsumerCount int
+	// QoS is the subscription QoS, it defaults to 1
+	QoS byte
+	// CleanSession discards the session on disconnect, messages published while a client
+	// is disconnected are only delivered when it is false
+	CleanSession bool
+	// KeepAlive defaults to 30s
+	KeepAlive time.Duration
+	// MaxReconnectInterval is the upper bound of the reconnect backoff, it defaults to 1m
+	MaxReconnectInterval time.Duration
+}
+
+func (c ConsumerConfig) withDefaults() ConsumerConfig {
+	if c.QoS == 0 {
+		c.QoS = 1
+	}
+	if c.KeepAlive <= 0 {
+		c.KeepAlive = 30 * time.Second
+	}
+	if c.MaxReconnectInterval <= 0 {
+		c.MaxReconnectInterval = time.Minute
+	}
+	return c
+}
+
+// subscription returns the topic filter sent to the broker
+func (c ConsumerConfig) subscription(topic string) string {
+	if c.SharedGroup == "" {
+		return topic
+	}
+	return "$share/" + c.SharedGroup + "/" + topic
+}
+
+// TopicPattern translates an MQTT topic filter into a pattern for the ziggurat.Router
+// the + wildcard matches a single level and the # wildcard matches any number of levels
+//
+//	router.HandlerFunc(mqtt.TopicPattern("devices/+/telemetry"), handler)
+func TopicPattern(filter string) string {
+	levels := strings.Split(filter, "/")
+	for i, l := range levels {
+		switch {
+		case l == "+":
+			levels[i] = "[^/]+"
+		case l == "#" && i == len(levels)-1:
+			// devices/# also matches devices
+			if i > 0 {
+				levels[i-1] += "(/.*)?"
+				levels = levels[:i]
+			} else {
+				levels[i] = ".*"
+			}
+		default:
+			levels[i] = regexp.QuoteMeta(l)
+		}
+	}
+	return "^" + strings.Join(levels, "/") + "$"
+}
@@ -0,0 +1,179 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+)
+
+const (
+	EventType = "mqtt"
+	// KeyTopic holds the topic the message was published on
+	KeyTopic = "mqtt-topic"
+	// KeySubscription holds the topic filter which matched the message
+	KeySubscription = "mqtt-subscription"
+	// KeyMessageID holds the packet identifier of the message as an uint16
+	KeyMessageID = "mqtt-message-id"
+	// KeyQoS holds the QoS of the message as a byte
+	KeyQoS = "mqtt-qos"
+	// KeyRetained is true for retained messages
+	KeyRetained = "mqtt-retained"
+	// KeyDuplicate is true for messages which were redelivered by the broker
+	KeyDuplicate = "mqtt-duplicate"
+)
+
+// ConsumerGroup subscribes to MQTT topics using one or more clients
+// QoS 1 messages are acknowledged once they are processed, a message which fails processing is not acknowledged
+// and is redelivered by the broker when the session is resumed
+// the RoutingPath of an event is the topic of the message, use TopicPattern to route topic filters
+type ConsumerGroup struct {
+	GroupConfig ConsumerConfig
+	Logger      ziggurat.StructuredLogger
+}
+
+// Consume connects the clients and blocks until ctx is done
+// it waits for the pending acknowledgements before disconnecting
+func (cg *ConsumerGroup) Consume(ctx context.Context, h ziggurat.Handler) error {
+	if cg.Logger == nil {
+		cg.Logger = logger.NOOP
+	}
+	cfg := cg.GroupConfig.withDefaults()
+	if len(cfg.Brokers) == 0 || len(cfg.Topics) == 0 || cfg.ClientID == "" {
+		return errors.New("mqtt: Brokers, Topics and ClientID are required")
+	}
+	if cfg.ConsumerCount < 1 {
+		cg.Logger.Warn("ConsumerConfig.ConsumerCount < 1, no consumers will be started")
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, cfg.ConsumerCount)
+	for i := 0; i < cfg.ConsumerCount; i++ {
+		w := &worker{
+			config:  cfg,
+			id:      fmt.Sprintf("%s_%d", cfg.ClientID, i),
+			handler: h,
+			logger:  cg.Logger,
+		}
+		cg.Logger.Info("spawning mqtt client", map[string]any{"client-id": w.id})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = w.run(ctx)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+type worker struct {
+	config   ConsumerConfig
+	id       string
+	handler  ziggurat.Handler
+	logger   ziggurat.StructuredLogger
+	inflight sync.WaitGroup
+	mu       sync.RWMutex
+	stopped  bool
+}
+
+func (w *worker) options(ctx context.Context) *paho.ClientOptions {
+	opts := paho.NewClientOptions().
+		SetClientID(w.id).
+		SetUsername(w.config.Username).
+		SetPassword(w.config.Password).
+		SetCleanSession(w.config.CleanSession).
+		SetKeepAlive(w.config.KeepAlive).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(w.config.MaxReconnectInterval).
+		SetResumeSubs(true).
+		SetAutoAckDisabled(true).
+		SetOrderMatters(true)
+	for _, b := range w.config.Brokers {
+		opts.AddBroker(b)
+	}
+	opts.SetOnConnectHandler(func(c paho.Client) {
+		w.logger.Info("mqtt client connected", map[string]any{"client-id": w.id})
+		// the broker keeps the subscriptions of a persistent session,
+		// subscribing again covers sessions which expired while the client was disconnected
+		for _, t := range w.config.Topics {
+			token := c.Subscribe(w.config.subscription(t), w.config.QoS, w.callback(ctx, t))
+			go func(filter string) {
+				token.Wait()
+				w.logger.Error("mqtt subscribe error", token.Error(), map[string]any{"client-id": w.id, "topic": filter})
+			}(t)
+		}
+	})
+	opts.SetConnectionLostHandler(func(c paho.Client, err error) {
+		w.logger.Error("mqtt connection lost, reconnecting", err, map[string]any{"client-id": w.id})
+	})
+	return opts
+}
+
+func (w *worker) run(ctx context.Context) error {
+	client := paho.NewClient(w.options(ctx))
+	token := client.Connect()
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("mqtt: %s failed to connect: %w", w.id, err)
+		}
+	case <-ctx.Done():
+	}
+	<-ctx.Done()
+
+	// messages arriving from now on are not acknowledged and are redelivered when the session resumes
+	w.mu.Lock()
+	w.stopped = true
+	w.mu.Unlock()
+	w.inflight.Wait()
+	client.Disconnect(uint((250 * time.Millisecond).Milliseconds()))
+	return nil
+}
+
+func (w *worker) callback(ctx context.Context, filter string) paho.MessageHandler {
+	return func(_ paho.Client, msg paho.Message) {
+		w.mu.RLock()
+		if w.stopped {
+			w.mu.RUnlock()
+			return
+		}
+		w.inflight.Add(1)
+		w.mu.RUnlock()
+
+		now := time.Now()
+		event := &ziggurat.Event{
+			Metadata: map[string]any{
+				KeyTopic:        msg.Topic(),
+				KeySubscription: filter,
+				KeyMessageID:    msg.MessageID(),
+				KeyQoS:          msg.Qos(),
+				KeyRetained:     msg.Retained(),
+				KeyDuplicate:    msg.Duplicate(),
+			},
+			Value:             msg.Payload(),
+			Key:               []byte{},
+			RoutingPath:       msg.Topic(),
+			ProducerTimestamp: now,
+			ReceivedTimestamp: now,
+			EventType:         EventType,
+		}
+
+		actx, ack := ziggurat.WithAck(ctx, func(err error) {
+			defer w.inflight.Done()
+			if err != nil {
+				w.logger.Error("mqtt message processing failed, leaving it unacknowledged", err,
+					map[string]any{"client-id": w.id, "topic": msg.Topic(), "message-id": msg.MessageID()})
+				return
+			}
+			msg.Ack()
+		})
+		w.handler.Handle(actx, event)
+		ack.Release()
+	}
+}
@@ -0,0 +1,227 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/ziggurattest"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+func runBroker(t *testing.T) (*mochi.Server, string) {
+	t.Helper()
+	s := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := s.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	if err := s.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = s.Serve()
+	}()
+	t.Cleanup(func() { s.Close() })
+	return s, "tcp://" + tcp.Address()
+}
+
+func publish(t *testing.T, s *mochi.Server, topic, value string) {
+	t.Helper()
+	if err := s.Publish(topic, []byte(value), false, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func consume(ctx context.Context, cg *ConsumerGroup, h ziggurat.Handler) chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- cg.Consume(ctx, h)
+	}()
+	return done
+}
+
+// waitForSubscribers waits until the broker has n subscribers for the filter
+func waitForSubscribers(t *testing.T, s *mochi.Server, filter string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		subs := s.Topics.Subscribers(filter)
+		count := len(subs.Subscriptions)
+		for _, group := range subs.Shared {
+			count += len(group)
+		}
+		if count >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d subscribers on %s", n, filter)
+}
+
+func TestConsumerGroup_Wildcards(t *testing.T) {
+	s, addr := runBroker(t)
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+
+	r := ziggurattest.NewRecorder()
+	cg := &ConsumerGroup{GroupConfig: ConsumerConfig{
+		Brokers:       []string{addr},
+		ClientID:      "telemetry",
+		Topics:        []string{"devices/+/telemetry"},
+		ConsumerCount: 1,
+	}}
+	done := consume(ctx, cg, r)
+	waitForSubscribers(t, s, "devices/d1/telemetry", 1)
+
+	publish(t, s, "devices/d1/telemetry", "foo")
+	publish(t, s, "devices/d2/status", "ignored")
+	publish(t, s, "devices/d2/telemetry", "bar")
+	if !r.WaitFor(t, 2, 5*time.Second) {
+		t.FailNow()
+	}
+	cfn()
+	if err := <-done; err != nil {
+		t.Fatalf("expected nil error got %v", err)
+	}
+
+	r.AssertValues(t, "foo", "bar")
+	r.AssertRoutingPaths(t, "devices/d1/telemetry", "devices/d2/telemetry")
+	e := r.Events()[0]
+	if e.Metadata[KeySubscription] != "devices/+/telemetry" || e.Metadata[KeyQoS] != byte(1) {
+		t.Errorf("unexpected metadata %v", e.Metadata)
+	}
+}
+
+func TestConsumerGroup_SharedSubscription(t *testing.T) {
+	s, addr := runBroker(t)
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+
+	r := ziggurattest.NewRecorder()
+	cg := &ConsumerGroup{GroupConfig: ConsumerConfig{
+		Brokers:       []string{addr},
+		ClientID:      "telemetry",
+		Topics:        []string{"devices/#"},
+		SharedGroup:   "ingest",
+		ConsumerCount: 3,
+	}}
+	done := consume(ctx, cg, r)
+	waitForSubscribers(t, s, "devices/d1", 3)
+
+	for i := 0; i < 30; i++ {
+		publish(t, s, "devices/d1", fmt.Sprint(i))
+	}
+	r.WaitFor(t, 30, 5*time.Second)
+	// wait for duplicates, if any
+	time.Sleep(100 * time.Millisecond)
+	cfn()
+	<-done
+	r.AssertCount(t, 30)
+}
+
+func TestConsumerGroup_RedeliversOnSessionResume(t *testing.T) {
+	s, addr := runBroker(t)
+	config := ConsumerConfig{
+		Brokers:       []string{addr},
+		ClientID:      "telemetry",
+		Topics:        []string{"devices/+/telemetry"},
+		ConsumerCount: 1,
+	}
+
+	var mu sync.Mutex
+	var values []string
+	failing := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		mu.Lock()
+		values = append(values, string(event.Value))
+		mu.Unlock()
+		ack, _ := ziggurat.DeferAck(ctx)
+		ack(errors.New("processing failed"))
+	})
+	ctx, cfn := context.WithCancel(context.Background())
+	done := consume(ctx, &ConsumerGroup{GroupConfig: config}, failing)
+	waitForSubscribers(t, s, "devices/d1/telemetry", 1)
+	publish(t, s, "devices/d1/telemetry", "foo")
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(values)
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cfn()
+	<-done
+
+	// published while the client is disconnected, delivered once the session resumes
+	publish(t, s, "devices/d2/telemetry", "bar")
+
+	r := ziggurattest.NewRecorder()
+	ctx, cfn = context.WithCancel(context.Background())
+	defer cfn()
+	done = consume(ctx, &ConsumerGroup{GroupConfig: config}, r)
+	if !r.WaitFor(t, 2, 5*time.Second) {
+		t.FailNow()
+	}
+	cfn()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(values) != 1 || values[0] != "foo" {
+		t.Errorf("expected the failing handler to receive foo got %v", values)
+	}
+	// the broker does not guarantee the order of the redelivered and the queued messages
+	received := map[string]*ziggurat.Event{}
+	for _, e := range r.Events() {
+		received[string(e.Value)] = e
+	}
+	if _, ok := received["bar"]; !ok {
+		t.Errorf("expected bar to be delivered once the session resumed")
+	}
+	foo, ok := received["foo"]
+	if !ok {
+		t.Fatalf("expected foo to be redelivered once the session resumed")
+	}
+	if dup, _ := foo.Metadata[KeyDuplicate].(bool); !dup {
+		t.Errorf("expected the redelivered message to be marked as duplicate")
+	}
+}
+
+func TestTopicPattern(t *testing.T) {
+	cases := []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{"devices/+/telemetry", "devices/d1/telemetry", true},
+		{"devices/+/telemetry", "devices/d1/status", false},
+		{"devices/+/telemetry", "devices/d1/x/telemetry", false},
+		{"devices/#", "devices", true},
+		{"devices/#", "devices/d1/telemetry", true},
+		{"devices/#", "devicesx", false},
+		{"#", "anything/at/all", true},
+		{"a.b/+", "a.b/c", true},
+		{"a.b/+", "axb/c", false},
+	}
+	for _, c := range cases {
+		got := regexp.MustCompile(TopicPattern(c.filter)).MatchString(c.topic)
+		if got != c.match {
+			t.Errorf("expected %s to match %s: %v got %v", c.filter, c.topic, c.match, got)
+		}
+	}
+}
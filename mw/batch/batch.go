@@ -0,0 +1,192 @@
+package batch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+)
+
+const (
+	defaultSize     = 100
+	defaultInterval = 1 * time.Second
+)
+
+type Opts func(b *Batcher)
+
+// WithSize sets the max number of events in a batch
+func WithSize(n int) Opts {
+	return func(b *Batcher) {
+		b.size = n
+	}
+}
+
+// WithInterval sets the max time an event waits in a batch before it is flushed
+func WithInterval(d time.Duration) Opts {
+	return func(b *Batcher) {
+		b.interval = d
+	}
+}
+
+func WithLogger(l ziggurat.StructuredLogger) Opts {
+	return func(b *Batcher) {
+		b.logger = l
+	}
+}
+
+type batch struct {
+	mu     sync.Mutex
+	events []*ziggurat.Event
+	acks   []ziggurat.AckFunc
+	timer  *time.Timer
+}
+
+// Batcher buffers events per route and hands them over to a ziggurat.BatchHandler
+// a batch is flushed when it reaches the configured size, when the oldest
+// event in it is older than the configured interval or when the context passed to New is done.
+// Batcher defers the acknowledgement of every event until the batch
+// containing it is flushed, the events are acknowledged only if the flush succeeds.
+type Batcher struct {
+	ctx      context.Context
+	h        ziggurat.BatchHandler
+	size     int
+	interval time.Duration
+	logger   ziggurat.StructuredLogger
+	mu       sync.Mutex
+	batches  map[string]*batch
+	closed   bool
+	// unbatched logs the first event which is handled without batching
+	unbatched sync.Once
+}
+
+// New creates a Batcher, all pending batches are flushed once ctx is done
+// the ctx should be the same one that is passed to ziggurat.Run
+func New(ctx context.Context, h ziggurat.BatchHandler, opts ...Opts) *Batcher {
+	b := &Batcher{
+		ctx:      ctx,
+		h:        h,
+		size:     defaultSize,
+		interval: defaultInterval,
+		logger:   logger.NOOP,
+		batches:  map[string]*batch{},
+	}
+	for _, o := range opts {
+		o(b)
+	}
+	if b.size < 1 {
+		b.size = 1
+	}
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		b.closed = true
+		b.mu.Unlock()
+		b.Flush(context.WithoutCancel(ctx))
+	}()
+	return b
+}
+
+func (b *Batcher) batchFor(route string) (*batch, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bt, ok := b.batches[route]
+	if !ok {
+		bt = &batch{events: make([]*ziggurat.Event, 0, b.size), acks: make([]ziggurat.AckFunc, 0, b.size)}
+		b.batches[route] = bt
+	}
+	return bt, b.closed
+}
+
+// Handle adds the event to the batch for its routing path
+// Handle blocks while the batch is being flushed, an event whose acknowledgement
+// cannot be deferred by the message consumer is handled in a batch of its own before Handle returns
+func (b *Batcher) Handle(ctx context.Context, event *ziggurat.Event) {
+	ack, ok := ziggurat.DeferAck(ctx)
+	if !ok {
+		b.unbatched.Do(func() {
+			b.logger.Warn("batch: the message consumer does not support deferred acknowledgements, handling its events without batching", map[string]any{"path": event.RoutingPath})
+		})
+		if err := b.h.HandleBatch(ctx, []*ziggurat.Event{event}); err != nil {
+			b.logger.Error("batch handler error", err, map[string]any{"path": event.RoutingPath, "count": 1})
+		}
+		return
+	}
+	bt, closed := b.batchFor(event.RoutingPath)
+
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	b.add(bt, event, ack, closed)
+}
+
+// add must be called with bt.mu held
+func (b *Batcher) add(bt *batch, event *ziggurat.Event, ack ziggurat.AckFunc, closed bool) {
+	bt.events = append(bt.events, event)
+	bt.acks = append(bt.acks, ack)
+
+	if closed {
+		b.flush(context.WithoutCancel(b.ctx), bt)
+		return
+	}
+
+	if len(bt.events) >= b.size {
+		b.flush(b.ctx, bt)
+		return
+	}
+
+	if bt.timer == nil && b.interval > 0 {
+		var t *time.Timer
+		t = time.AfterFunc(b.interval, func() {
+			bt.mu.Lock()
+			defer bt.mu.Unlock()
+			// the batch the timer was started for may have been flushed while the timer fired,
+			// the events added since then have a timer of their own
+			if bt.timer != t {
+				return
+			}
+			b.flush(b.ctx, bt)
+		})
+		bt.timer = t
+	}
+}
+
+// Flush flushes the pending batches of all the routes
+func (b *Batcher) Flush(ctx context.Context) {
+	b.mu.Lock()
+	bts := make([]*batch, 0, len(b.batches))
+	for _, bt := range b.batches {
+		bts = append(bts, bt)
+	}
+	b.mu.Unlock()
+
+	for _, bt := range bts {
+		bt.mu.Lock()
+		b.flush(ctx, bt)
+		bt.mu.Unlock()
+	}
+}
+
+// flush must be called with bt.mu held
+func (b *Batcher) flush(ctx context.Context, bt *batch) {
+	if bt.timer != nil {
+		bt.timer.Stop()
+		bt.timer = nil
+	}
+	if len(bt.events) == 0 {
+		return
+	}
+
+	events, acks := bt.events, bt.acks
+	bt.events = make([]*ziggurat.Event, 0, b.size)
+	bt.acks = make([]ziggurat.AckFunc, 0, b.size)
+
+	err := b.h.HandleBatch(ctx, events)
+	if err != nil {
+		b.logger.Error("batch handler error", err, map[string]any{"path": events[0].RoutingPath, "count": len(events)})
+	}
+	for _, ack := range acks {
+		ack(err)
+	}
+}
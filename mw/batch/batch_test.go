@@ -0,0 +1,178 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+)
+
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]*ziggurat.Event
+	err     error
+}
+
+func (r *recorder) HandleBatch(ctx context.Context, events []*ziggurat.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, events)
+	return r.err
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.batches)
+}
+
+type ackRecorder struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (a *ackRecorder) handle(b *Batcher, e *ziggurat.Event) {
+	ctx, ack := ziggurat.WithAck(context.Background(), func(err error) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.errs = append(a.errs, err)
+	})
+	b.Handle(ctx, e)
+	ack.Release()
+}
+
+func (a *ackRecorder) acked() []error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]error{}, a.errs...)
+}
+
+func TestBatcher(t *testing.T) {
+	t.Run("flushes when the batch is full", func(t *testing.T) {
+		ctx, cfn := context.WithCancel(context.Background())
+		defer cfn()
+		var r recorder
+		var ar ackRecorder
+		b := New(ctx, &r, WithSize(3), WithInterval(time.Hour))
+		for i := 0; i < 7; i++ {
+			ar.handle(b, &ziggurat.Event{RoutingPath: "foo"})
+		}
+		if r.count() != 2 {
+			t.Errorf("expected 2 batches got %d", r.count())
+		}
+		if len(ar.acked()) != 6 {
+			t.Errorf("expected 6 acks got %d", len(ar.acked()))
+		}
+	})
+
+	t.Run("batches per route", func(t *testing.T) {
+		ctx, cfn := context.WithCancel(context.Background())
+		defer cfn()
+		var r recorder
+		var ar ackRecorder
+		b := New(ctx, &r, WithSize(2), WithInterval(time.Hour))
+		ar.handle(b, &ziggurat.Event{RoutingPath: "foo"})
+		ar.handle(b, &ziggurat.Event{RoutingPath: "bar"})
+		if r.count() != 0 {
+			t.Errorf("expected no batches got %d", r.count())
+		}
+		ar.handle(b, &ziggurat.Event{RoutingPath: "bar"})
+		if r.count() != 1 || r.batches[0][0].RoutingPath != "bar" {
+			t.Errorf("expected a single batch for bar got %v", r.batches)
+		}
+	})
+
+	t.Run("flushes after the interval", func(t *testing.T) {
+		ctx, cfn := context.WithCancel(context.Background())
+		defer cfn()
+		var r recorder
+		var ar ackRecorder
+		b := New(ctx, &r, WithSize(100), WithInterval(50*time.Millisecond))
+		ar.handle(b, &ziggurat.Event{RoutingPath: "foo"})
+		ar.handle(b, &ziggurat.Event{RoutingPath: "foo"})
+		time.Sleep(200 * time.Millisecond)
+		if r.count() != 1 || len(r.batches[0]) != 2 {
+			t.Errorf("expected one batch of 2 events got %v", r.batches)
+		}
+		if len(ar.acked()) != 2 {
+			t.Errorf("expected 2 acks got %d", len(ar.acked()))
+		}
+	})
+
+	t.Run("flushes on shutdown", func(t *testing.T) {
+		ctx, cfn := context.WithCancel(context.Background())
+		var r recorder
+		var ar ackRecorder
+		b := New(ctx, &r, WithSize(100), WithInterval(time.Hour))
+		ar.handle(b, &ziggurat.Event{RoutingPath: "foo"})
+		ar.handle(b, &ziggurat.Event{RoutingPath: "bar"})
+		cfn()
+		time.Sleep(100 * time.Millisecond)
+		if r.count() != 2 {
+			t.Errorf("expected 2 batches got %d", r.count())
+		}
+		if len(ar.acked()) != 2 {
+			t.Errorf("expected 2 acks got %d", len(ar.acked()))
+		}
+	})
+
+	t.Run("failed flushes are negatively acknowledged", func(t *testing.T) {
+		ctx, cfn := context.WithCancel(context.Background())
+		defer cfn()
+		wantErr := errors.New("bulk insert failed")
+		r := recorder{err: wantErr}
+		var ar ackRecorder
+		b := New(ctx, &r, WithSize(2))
+		ar.handle(b, &ziggurat.Event{RoutingPath: "foo"})
+		ar.handle(b, &ziggurat.Event{RoutingPath: "foo"})
+		for _, err := range ar.acked() {
+			if !errors.Is(err, wantErr) {
+				t.Errorf("expected %v got %v", wantErr, err)
+			}
+		}
+		if len(ar.acked()) != 2 {
+			t.Errorf("expected 2 acks got %d", len(ar.acked()))
+		}
+	})
+}
+
+func TestBatcher_StaleTimer(t *testing.T) {
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	var r recorder
+	var ar ackRecorder
+	b := New(ctx, &r, WithSize(100), WithInterval(20*time.Millisecond))
+	ar.handle(b, &ziggurat.Event{RoutingPath: "foo"})
+	bt, _ := b.batchFor("foo")
+
+	// the timer fires while the batch is flushed, the callback waits for the lock
+	bt.mu.Lock()
+	time.Sleep(50 * time.Millisecond)
+	b.flush(ctx, bt)
+	b.add(bt, &ziggurat.Event{RoutingPath: "foo"}, func(error) {}, false)
+	bt.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+	if r.count() != 1 {
+		t.Errorf("expected the stale timer not to flush the new batch got %d batches", r.count())
+	}
+	time.Sleep(50 * time.Millisecond)
+	if r.count() != 2 {
+		t.Errorf("expected the new batch to be flushed by its own timer got %d batches", r.count())
+	}
+}
+
+func TestBatcher_WithoutDeferredAcks(t *testing.T) {
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	var r recorder
+	b := New(ctx, &r, WithSize(100), WithInterval(time.Hour))
+	// the context of the event does not carry an ack, the event is acknowledged once Handle returns
+	b.Handle(context.Background(), &ziggurat.Event{RoutingPath: "foo"})
+	if r.count() != 1 || len(r.batches[0]) != 1 {
+		t.Errorf("expected the event to be handled before Handle returns got %v", r.batches)
+	}
+}
@@ -0,0 +1,285 @@
+package capture
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+)
+
+const defaultMaxSize = 100 * 1024 * 1024
+
+type Opts func(c *Capture)
+
+// WithRoutes captures only the events whose routing path matches one of the regex patterns
+func WithRoutes(patterns ...string) Opts {
+	return func(c *Capture) {
+		for _, p := range patterns {
+			c.routes = append(c.routes, regexp.MustCompile(p))
+		}
+	}
+}
+
+// WithSampleRate captures a fraction of the events, rate is between 0 and 1
+func WithSampleRate(rate float64) Opts {
+	return func(c *Capture) {
+		c.sampleRate = rate
+	}
+}
+
+// WithMaxSize rotates the file once the uncompressed size of the events written to it exceeds n bytes
+func WithMaxSize(n int64) Opts {
+	return func(c *Capture) {
+		c.maxSize = n
+	}
+}
+
+// WithMaxAge rotates the file once it is older than d
+func WithMaxAge(d time.Duration) Opts {
+	return func(c *Capture) {
+		c.maxAge = d
+	}
+}
+
+// WithGzip compresses the captured files
+func WithGzip() Opts {
+	return func(c *Capture) {
+		c.gzip = true
+	}
+}
+
+// WithRedactor adds a hook which can modify the event before it is written
+// the hook receives a clone of the event, the event handed to the handlers is never modified
+func WithRedactor(f func(e *ziggurat.Event)) Opts {
+	return func(c *Capture) {
+		c.redactors = append(c.redactors, f)
+	}
+}
+
+// WithPrefix sets the prefix of the file names, it defaults to "events"
+func WithPrefix(prefix string) Opts {
+	return func(c *Capture) {
+		c.prefix = prefix
+	}
+}
+
+func WithLogger(l ziggurat.StructuredLogger) Opts {
+	return func(c *Capture) {
+		c.logger = l
+	}
+}
+
+// Capture writes processed events to rotating JSONL files in the ziggurat.Event JSON shape
+// the files can be replayed using the jsonl.Consumer
+type Capture struct {
+	dir        string
+	prefix     string
+	routes     []*regexp.Regexp
+	sampleRate float64
+	maxSize    int64
+	maxAge     time.Duration
+	gzip       bool
+	redactors  []func(e *ziggurat.Event)
+	logger     ziggurat.StructuredLogger
+
+	mu       sync.Mutex
+	file     *os.File
+	gz       *gzip.Writer
+	w        io.Writer
+	size     int64
+	openedAt time.Time
+	seq      int
+	closed   bool
+}
+
+// New creates the capture directory if it does not exist
+// Close must be called once ziggurat.Run returns to flush the current file
+func New(dir string, opts ...Opts) (*Capture, error) {
+	c := &Capture{
+		dir:        dir,
+		prefix:     "events",
+		sampleRate: 1,
+		maxSize:    defaultMaxSize,
+		logger:     logger.NOOP,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("capture: %w", err)
+	}
+	return c, nil
+}
+
+// Middleware writes a clone of the event once the event is acknowledged, the events whose acknowledgement
+// failed are not written. The event is cloned before it is handed to the next handler as the message consumer
+// can reuse it once it is acknowledged, the captured event is the event as it was received
+func (c *Capture) Middleware(next ziggurat.Handler) ziggurat.Handler {
+	f := func(ctx context.Context, event *ziggurat.Event) {
+		if !c.shouldCapture(event) {
+			next.Handle(ctx, event)
+			return
+		}
+		captured := event.Clone()
+		ack, ok := ziggurat.DeferAck(ctx)
+		if !ok {
+			next.Handle(ctx, event)
+			c.write(captured)
+			return
+		}
+		actx, a := ziggurat.WithAck(ctx, func(err error) {
+			if err == nil {
+				c.write(captured)
+			}
+			ack(err)
+		})
+		next.Handle(actx, event)
+		a.Release()
+	}
+	return ziggurat.HandlerFunc(f)
+}
+
+// write redacts and writes an event owned by the capture
+func (c *Capture) write(event *ziggurat.Event) {
+	for _, r := range c.redactors {
+		r(event)
+	}
+	if err := c.writeEvent(event); err != nil {
+		c.logger.Error("capture write error", err, map[string]any{"path": event.RoutingPath})
+	}
+}
+
+func (c *Capture) shouldCapture(event *ziggurat.Event) bool {
+	if len(c.routes) > 0 {
+		var matched bool
+		for _, r := range c.routes {
+			if r.MatchString(event.RoutingPath) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return c.sampleRate >= 1 || rand.Float64() < c.sampleRate
+}
+
+// Write redacts and writes a single event
+func (c *Capture) Write(event *ziggurat.Event) error {
+	if len(c.redactors) > 0 {
+		event = event.Clone()
+		for _, r := range c.redactors {
+			r(event)
+		}
+	}
+	return c.writeEvent(event)
+}
+
+func (c *Capture) writeEvent(event *ziggurat.Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return fmt.Errorf("capture: write after close")
+	}
+	if c.shouldRotate() {
+		if err := c.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := c.w.Write(b)
+	c.size += int64(n)
+	return err
+}
+
+func (c *Capture) shouldRotate() bool {
+	switch {
+	case c.w == nil:
+		return true
+	case c.maxSize > 0 && c.size >= c.maxSize:
+		return true
+	case c.maxAge > 0 && time.Since(c.openedAt) >= c.maxAge:
+		return true
+	default:
+		return false
+	}
+}
+
+// rotate must be called with c.mu held
+func (c *Capture) rotate() error {
+	if err := c.closeFile(); err != nil {
+		c.logger.Error("capture error closing file", err)
+	}
+
+	now := time.Now()
+	var name string
+	var f *os.File
+	for {
+		c.seq++
+		name = fmt.Sprintf("%s-%s-%04d.jsonl", c.prefix, now.Format("20060102T150405"), c.seq)
+		if c.gzip {
+			name += ".gz"
+		}
+		// another Capture or process writing to the directory may own the name, its file is never overwritten
+		var err error
+		f, err = os.OpenFile(filepath.Join(c.dir, name), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("capture: %w", err)
+		}
+		break
+	}
+	c.file, c.w = f, f
+	if c.gzip {
+		c.gz = gzip.NewWriter(f)
+		c.w = c.gz
+	}
+	c.size = 0
+	c.openedAt = now
+	c.logger.Info("capture rotated file", map[string]any{"file": name})
+	return nil
+}
+
+// closeFile must be called with c.mu held
+func (c *Capture) closeFile() error {
+	if c.file == nil {
+		return nil
+	}
+	var err error
+	if c.gz != nil {
+		err = c.gz.Close()
+	}
+	if closeErr := c.file.Close(); err == nil {
+		err = closeErr
+	}
+	c.file, c.gz, c.w = nil, nil, nil
+	return err
+}
+
+// Close flushes and closes the current file, events written after Close are dropped
+func (c *Capture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return c.closeFile()
+}
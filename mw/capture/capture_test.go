@@ -0,0 +1,179 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/jsonl"
+	"github.com/gojekfarm/ziggurat/v2/ziggurattest"
+)
+
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+	m, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCapture_Replay(t *testing.T) {
+	for name, opts := range map[string][]Opts{
+		"plain": nil,
+		"gzip":  {WithGzip()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			c, err := New(dir, append(opts, WithRoutes("orders"))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			handled := ziggurattest.NewRecorder()
+			_, _ = ziggurattest.Run(context.Background(), ziggurat.Use(handled, c.Middleware),
+				ziggurattest.NewEvent().Value("o1").Kafka("foo.id", "orders", 0, 1).Build(),
+				ziggurattest.NewEvent().Value("p1").Kafka("foo.id", "payments", 0, 1).Build(),
+				ziggurattest.NewEvent().Value("o2").Kafka("foo.id", "orders", 1, 5).Build(),
+			)
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+			handled.AssertCount(t, 3)
+
+			replayed := ziggurattest.NewRecorder()
+			consumer := jsonl.Consumer{Paths: []string{filepath.Join(dir, "*")}}
+			if err := consumer.Consume(context.Background(), replayed); err != nil {
+				t.Fatal(err)
+			}
+			replayed.AssertValues(t, "o1", "o2")
+			replayed.AssertRoutingPaths(t, "foo.id/orders/0", "foo.id/orders/1")
+		})
+	}
+}
+
+func TestCapture_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, WithMaxSize(1), WithPrefix("orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := c.Write(ziggurattest.NewEvent().Value("foo").Build()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = c.Close()
+	got := files(t, dir)
+	if len(got) != 3 {
+		t.Errorf("expected 3 files got %v", got)
+	}
+	for _, f := range got {
+		if matched, _ := filepath.Match("orders-*.jsonl", filepath.Base(f)); !matched {
+			t.Errorf("unexpected file name %s", f)
+		}
+	}
+	if err := c.Write(ziggurattest.NewEvent().Build()); err == nil {
+		t.Error("expected an error when writing after close")
+	}
+}
+
+func TestCapture_RotationKeepsExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	// both captures start from the same sequence number, the second one skips the names the first one owns
+	for _, value := range []string{"first", "second"} {
+		c, err := New(dir, WithPrefix("orders"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Write(ziggurattest.NewEvent().Value(value).Build()); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := files(t, dir); len(got) != 2 {
+		t.Errorf("expected 2 files got %v", got)
+	}
+	replayed := ziggurattest.NewRecorder()
+	consumer := jsonl.Consumer{Paths: []string{filepath.Join(dir, "*")}}
+	if err := consumer.Consume(context.Background(), replayed); err != nil {
+		t.Fatal(err)
+	}
+	replayed.AssertCount(t, 2)
+}
+
+func TestCapture_Redaction(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, WithRedactor(func(e *ziggurat.Event) {
+		e.Value = []byte("REDACTED")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handled := ziggurattest.NewRecorder()
+	_, _ = ziggurattest.Run(context.Background(), ziggurat.Use(handled, c.Middleware),
+		ziggurattest.NewEvent().Value("card-number").Build())
+	_ = c.Close()
+
+	handled.AssertValues(t, "card-number")
+	replayed := ziggurattest.NewRecorder()
+	consumer := jsonl.Consumer{Paths: files(t, dir)}
+	if err := consumer.Consume(context.Background(), replayed); err != nil {
+		t.Fatal(err)
+	}
+	replayed.AssertValues(t, "REDACTED")
+}
+
+func TestCapture_Sampling(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, WithSampleRate(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := c.Middleware(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {}))
+	for i := 0; i < 10; i++ {
+		h.Handle(context.Background(), ziggurattest.NewEvent().Build())
+	}
+	_ = c.Close()
+	if got := files(t, dir); len(got) != 0 {
+		t.Errorf("expected no files got %v", got)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("expected the directory to exist: %v", err)
+	}
+}
+
+func TestCapture_DeferredAck(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acks := map[string]ziggurat.AckFunc{}
+	h := c.Middleware(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		acks[string(event.Value)], _ = ziggurat.DeferAck(ctx)
+	}))
+	for _, v := range []string{"o1", "o2"} {
+		event := ziggurattest.NewEvent().Value(v).Build()
+		ctx, ack := ziggurat.WithAck(context.Background(), func(error) {})
+		h.Handle(ctx, event)
+		ack.Release()
+		// the message consumer reuses the event before it is acknowledged
+		event.Value = []byte("reused")
+	}
+	acks["o1"](nil)
+	acks["o2"](errors.New("flush failed"))
+	_ = c.Close()
+
+	replayed := ziggurattest.NewRecorder()
+	consumer := jsonl.Consumer{Paths: files(t, dir)}
+	if err := consumer.Consume(context.Background(), replayed); err != nil {
+		t.Fatal(err)
+	}
+	// the event whose acknowledgement failed is not captured
+	replayed.AssertValues(t, "o1")
+}
@@ -0,0 +1,120 @@
+package ordered
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gojekfarm/ziggurat/v2"
+)
+
+const (
+	defaultConcurrency = 10
+	defaultMaxInFlight = 100
+)
+
+type Opts func(e *Executor)
+
+// WithConcurrency sets the max number of keys processed concurrently
+func WithConcurrency(n int) Opts {
+	return func(e *Executor) {
+		e.concurrency = n
+	}
+}
+
+// WithMaxInFlight sets the max number of events which are queued or being processed,
+// Handle blocks once the limit is reached
+func WithMaxInFlight(n int) Opts {
+	return func(e *Executor) {
+		e.maxInFlight = n
+	}
+}
+
+type task struct {
+	ctx   context.Context
+	event *ziggurat.Event
+	ack   ziggurat.AckFunc
+}
+
+// Executor processes events with different keys concurrently
+// events with the same key are handed to the next handler one after the other
+// in the order in which they were received. Events without a key share the same empty key.
+//
+// Handle returns as soon as the event is queued and the acknowledgement of the event
+// is deferred until the next handler is done with it, message consumers which
+// support deferred acknowledgements (like kafka.ConsumerGroup) keep their at least once guarantees.
+type Executor struct {
+	next        ziggurat.Handler
+	concurrency int
+	maxInFlight int
+	mu          sync.Mutex
+	queues      map[string][]task
+	inFlight    chan struct{}
+	running     chan struct{}
+}
+
+func New(next ziggurat.Handler, opts ...Opts) *Executor {
+	e := &Executor{
+		next:        next,
+		concurrency: defaultConcurrency,
+		maxInFlight: defaultMaxInFlight,
+		queues:      map[string][]task{},
+	}
+	for _, o := range opts {
+		o(e)
+	}
+	if e.concurrency < 1 {
+		e.concurrency = 1
+	}
+	if e.maxInFlight < 1 {
+		e.maxInFlight = 1
+	}
+	e.inFlight = make(chan struct{}, e.maxInFlight)
+	e.running = make(chan struct{}, e.concurrency)
+	return e
+}
+
+// Handle queues the event behind the in-flight events with the same key
+func (e *Executor) Handle(ctx context.Context, event *ziggurat.Event) {
+	ack, _ := ziggurat.DeferAck(ctx)
+	e.inFlight <- struct{}{}
+
+	key := string(event.Key)
+	e.mu.Lock()
+	q, ok := e.queues[key]
+	e.queues[key] = append(q, task{ctx: ctx, event: event, ack: ack})
+	e.mu.Unlock()
+
+	// a key present in the map already has a goroutine draining its queue
+	if !ok {
+		go e.drain(key)
+	}
+}
+
+func (e *Executor) drain(key string) {
+	e.running <- struct{}{}
+	defer func() { <-e.running }()
+
+	for {
+		e.mu.Lock()
+		q := e.queues[key]
+		if len(q) == 0 {
+			delete(e.queues, key)
+			e.mu.Unlock()
+			return
+		}
+		t := q[0]
+		e.queues[key] = q[1:]
+		e.mu.Unlock()
+
+		// the next handler can defer the acknowledgement further
+		actx, a := ziggurat.WithAck(t.ctx, t.ack)
+		e.next.Handle(actx, t.event)
+		a.Release()
+		<-e.inFlight
+	}
+}
+
+// InFlight returns the number of events which are queued or being processed
+func (e *Executor) InFlight() int {
+	return len(e.inFlight)
+}
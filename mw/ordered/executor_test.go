@@ -0,0 +1,124 @@
+package ordered
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+)
+
+func TestExecutor(t *testing.T) {
+	t.Run("events with the same key are processed in order", func(t *testing.T) {
+		var mu sync.Mutex
+		got := map[string][]string{}
+		var wg sync.WaitGroup
+		e := New(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			got[string(event.Key)] = append(got[string(event.Key)], string(event.Value))
+			mu.Unlock()
+		}), WithConcurrency(4), WithMaxInFlight(8))
+
+		keys := []string{"a", "b", "c"}
+		for i := 0; i < 30; i++ {
+			wg.Add(1)
+			ctx, ack := ziggurat.WithAck(context.Background(), func(err error) { wg.Done() })
+			e.Handle(ctx, &ziggurat.Event{Key: []byte(keys[i%3]), Value: []byte(fmt.Sprintf("%02d", i))})
+			ack.Release()
+		}
+		wg.Wait()
+
+		for _, k := range keys {
+			vals := got[k]
+			if len(vals) != 10 {
+				t.Errorf("expected 10 events for key %s got %d", k, len(vals))
+			}
+			for i := 1; i < len(vals); i++ {
+				if vals[i-1] > vals[i] {
+					t.Errorf("events for key %s out of order: %v", k, vals)
+					break
+				}
+			}
+		}
+	})
+
+	t.Run("different keys are processed concurrently", func(t *testing.T) {
+		var running, maxRunning int32
+		var wg sync.WaitGroup
+		e := New(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}), WithConcurrency(3), WithMaxInFlight(10))
+
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			ctx, ack := ziggurat.WithAck(context.Background(), func(err error) { wg.Done() })
+			e.Handle(ctx, &ziggurat.Event{Key: []byte(fmt.Sprintf("key-%d", i))})
+			ack.Release()
+		}
+		wg.Wait()
+		if m := atomic.LoadInt32(&maxRunning); m != 3 {
+			t.Errorf("expected 3 keys to be processed concurrently got %d", m)
+		}
+	})
+
+	t.Run("handle blocks when max in-flight is reached", func(t *testing.T) {
+		release := make(chan struct{})
+		e := New(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+			<-release
+		}), WithMaxInFlight(2))
+
+		e.Handle(context.Background(), &ziggurat.Event{Key: []byte("a")})
+		e.Handle(context.Background(), &ziggurat.Event{Key: []byte("b")})
+		if e.InFlight() != 2 {
+			t.Errorf("expected 2 in-flight events got %d", e.InFlight())
+		}
+
+		queued := make(chan struct{})
+		go func() {
+			e.Handle(context.Background(), &ziggurat.Event{Key: []byte("c")})
+			close(queued)
+		}()
+		select {
+		case <-queued:
+			t.Error("expected Handle to block")
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(release)
+		<-queued
+	})
+
+	t.Run("acknowledgements can be deferred by the next handler", func(t *testing.T) {
+		deferred := make(chan ziggurat.AckFunc, 1)
+		acked := make(chan error, 1)
+		e := New(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+			ack, _ := ziggurat.DeferAck(ctx)
+			deferred <- ack
+		}))
+		ctx, ack := ziggurat.WithAck(context.Background(), func(err error) { acked <- err })
+		e.Handle(ctx, &ziggurat.Event{})
+		ack.Release()
+
+		f := <-deferred
+		select {
+		case <-acked:
+			t.Error("expected the ack to wait for the next handler")
+		case <-time.After(20 * time.Millisecond):
+		}
+		f(nil)
+		if err := <-acked; err != nil {
+			t.Errorf("expected nil error got %v", err)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+// Package kafkastats publishes the kafka.Stats of a kafka.ConsumerGroup as Prometheus metrics,
+// it is kept apart from the prometheus middleware so that the middleware does not depend on librdkafka
+package kafkastats
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2/kafka"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace      = "ziggurat_go"
+	kafkaSubsystem = "kafka"
+)
+
+var partitionLabels = []string{"client", "topic", "partition"}
+
+// ConsumerLag - Prometheus gauge for the consumer lag of every assigned partition
+var ConsumerLag = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: kafkaSubsystem,
+		Name:      "consumer_lag",
+		Help:      "difference between the high watermark and the committed offset, partitioned by client, topic and partition",
+	},
+	partitionLabels,
+)
+
+// FetchQueueMessages - Prometheus gauge for the pre-fetched messages of every partition
+var FetchQueueMessages = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: kafkaSubsystem,
+		Name:      "fetch_queue_messages",
+		Help:      "pre-fetched messages in the fetch queue, partitioned by client, topic and partition",
+	},
+	partitionLabels,
+)
+
+// FetchQueueBytes - Prometheus gauge for the size of the pre-fetched messages of every partition
+var FetchQueueBytes = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: kafkaSubsystem,
+		Name:      "fetch_queue_bytes",
+		Help:      "size of the pre-fetched messages in the fetch queue, partitioned by client, topic and partition",
+	},
+	partitionLabels,
+)
+
+// BrokerRTT - Prometheus gauge for the round trip time of the broker requests
+var BrokerRTT = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: kafkaSubsystem,
+		Name:      "broker_rtt_seconds",
+		Help:      "round trip time of the broker requests, partitioned by client, broker and quantile",
+	},
+	[]string{"client", "broker", "quantile"},
+)
+
+// RebalancesCounter - Prometheus counter for the rebalances of every consumer
+var RebalancesCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: kafkaSubsystem,
+		Name:      "rebalances_total",
+		Help:      "consumer group rebalances, partitioned by client",
+	},
+	[]string{"client"},
+)
+
+// Register - Registers the Prometheus metrics published by Publish
+func Register() {
+	prometheus.MustRegister(
+		ConsumerLag,
+		FetchQueueMessages,
+		FetchQueueBytes,
+		BrokerRTT,
+		RebalancesCounter,
+	)
+}
+
+// rebalances holds the last rebalance count of every client,
+// librdkafka reports the total number of rebalances
+var rebalances = struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}{counts: map[string]int64{}}
+
+// Publish - updates the registered kafka metrics, it can be used as kafka.ConsumerGroup.OnStats
+func Publish(s kafka.Stats) {
+	for _, ts := range s.Topics {
+		for id, ps := range ts.Partitions {
+			// the internal partition holds the messages of unassigned partitions
+			if id == "-1" {
+				continue
+			}
+			FetchQueueMessages.WithLabelValues(s.Name, ts.Topic, id).Set(float64(ps.FetchQueueCount))
+			FetchQueueBytes.WithLabelValues(s.Name, ts.Topic, id).Set(float64(ps.FetchQueueSize))
+			// the lag is -1 until the committed offset and the high watermark are known
+			if ps.ConsumerLag >= 0 {
+				ConsumerLag.WithLabelValues(s.Name, ts.Topic, id).Set(float64(ps.ConsumerLag))
+			}
+		}
+	}
+
+	for _, bs := range s.Brokers {
+		// the bootstrap brokers and the internal broker do not have a node id
+		if bs.NodeID < 0 {
+			continue
+		}
+		broker := strconv.Itoa(int(bs.NodeID))
+		BrokerRTT.WithLabelValues(s.Name, broker, "avg").Set(microseconds(bs.RTT.Avg))
+		BrokerRTT.WithLabelValues(s.Name, broker, "0.99").Set(microseconds(bs.RTT.P99))
+	}
+
+	rebalances.mu.Lock()
+	defer rebalances.mu.Unlock()
+	if delta := s.ConsumerGroup.RebalanceCount - rebalances.counts[s.Name]; delta > 0 {
+		RebalancesCounter.WithLabelValues(s.Name).Add(float64(delta))
+	}
+	rebalances.counts[s.Name] = s.ConsumerGroup.RebalanceCount
+}
+
+func microseconds(us int64) float64 {
+	return (time.Duration(us) * time.Microsecond).Seconds()
+}
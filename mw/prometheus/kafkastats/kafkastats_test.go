@@ -0,0 +1,59 @@
+package kafkastats
+
+import (
+	"testing"
+
+	"github.com/gojekfarm/ziggurat/v2/kafka"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPublish(t *testing.T) {
+	// the collectors and the rebalance counts are global, they are reset so that the test can run again
+	for _, v := range []interface{ Reset() }{ConsumerLag, FetchQueueMessages, FetchQueueBytes, BrokerRTT, RebalancesCounter} {
+		v.Reset()
+	}
+	rebalances.mu.Lock()
+	delete(rebalances.counts, "consumer-1")
+	rebalances.mu.Unlock()
+
+	s := kafka.Stats{
+		Name: "consumer-1",
+		Brokers: map[string]kafka.BrokerStats{
+			"localhost:9092/1": {NodeID: 1, RTT: kafka.WindowStats{Avg: 2500, P99: 10000}},
+			"GroupCoordinator": {NodeID: -1},
+		},
+		Topics: map[string]kafka.TopicStats{
+			"foo": {Topic: "foo", Partitions: map[string]kafka.PartitionStats{
+				"0":  {Partition: 0, ConsumerLag: 42, FetchQueueCount: 3, FetchQueueSize: 300},
+				"1":  {Partition: 1, ConsumerLag: -1},
+				"-1": {Partition: -1, ConsumerLag: -1},
+			}},
+		},
+		ConsumerGroup: kafka.ConsumerGroupStats{RebalanceCount: 2},
+	}
+	Publish(s)
+	s.ConsumerGroup.RebalanceCount = 3
+	Publish(s)
+
+	if got := testutil.ToFloat64(ConsumerLag.WithLabelValues("consumer-1", "foo", "0")); got != 42 {
+		t.Errorf("expected a lag of 42 got %v", got)
+	}
+	if got := testutil.CollectAndCount(ConsumerLag); got != 1 {
+		t.Errorf("expected the unknown lags to be skipped got %d series", got)
+	}
+	if got := testutil.CollectAndCount(FetchQueueMessages); got != 2 {
+		t.Errorf("expected the internal partition to be skipped got %d series", got)
+	}
+	if got := testutil.ToFloat64(FetchQueueBytes.WithLabelValues("consumer-1", "foo", "0")); got != 300 {
+		t.Errorf("expected 300 bytes got %v", got)
+	}
+	if got := testutil.ToFloat64(BrokerRTT.WithLabelValues("consumer-1", "1", "avg")); got != 0.0025 {
+		t.Errorf("expected an average rtt of 2.5ms got %v", got)
+	}
+	if got := testutil.CollectAndCount(BrokerRTT); got != 2 {
+		t.Errorf("expected the brokers without a node id to be skipped got %d series", got)
+	}
+	if got := testutil.ToFloat64(RebalancesCounter.WithLabelValues("consumer-1")); got != 3 {
+		t.Errorf("expected 3 rebalances got %v", got)
+	}
+}
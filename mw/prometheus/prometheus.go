@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/gojekfarm/ziggurat/v2"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -64,18 +65,45 @@ func Register() {
 	)
 }
 
+type routeMetrics struct {
+	duration prometheus.Observer
+	events   prometheus.Counter
+}
+
+// handlerMetrics caches the metrics of every route,
+// looking them up by label values allocates for every event
+var handlerMetrics = struct {
+	mu     sync.RWMutex
+	routes map[string]routeMetrics
+}{routes: map[string]routeMetrics{}}
+
+func metricsFor(route string) routeMetrics {
+	handlerMetrics.mu.RLock()
+	rm, ok := handlerMetrics.routes[route]
+	handlerMetrics.mu.RUnlock()
+	if ok {
+		return rm
+	}
+
+	handlerMetrics.mu.Lock()
+	defer handlerMetrics.mu.Unlock()
+	rm = routeMetrics{
+		duration: HandlerDurationHistogram.WithLabelValues(route),
+		events:   HandlerEventsCounter.WithLabelValues(route),
+	}
+	handlerMetrics.routes[route] = rm
+	return rm
+}
+
 // PublishHandlerMetrics - middleware to update registered handler metrics
 func PublishHandlerMetrics(next ziggurat.Handler) ziggurat.Handler {
 	f := func(ctx context.Context, event *ziggurat.Event) {
 		t1 := time.Now()
 		next.Handle(ctx, event)
 
-		labels := prometheus.Labels{
-			RouteLabel: event.RoutingPath,
-		}
-
-		HandlerDurationHistogram.With(labels).Observe(time.Since(t1).Seconds())
-		HandlerEventsCounter.With(labels).Inc()
+		rm := metricsFor(event.RoutingPath)
+		rm.duration.Observe(time.Since(t1).Seconds())
+		rm.events.Inc()
 
 	}
 	return ziggurat.HandlerFunc(f)
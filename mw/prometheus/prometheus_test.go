@@ -0,0 +1,37 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPublishHandlerMetrics(t *testing.T) {
+	h := PublishHandlerMetrics(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {}))
+	e := &ziggurat.Event{RoutingPath: "foo.id/bar/1"}
+	ctx := context.Background()
+	// the counter is global, the test can run more than once
+	before := testutil.ToFloat64(HandlerEventsCounter.WithLabelValues("foo.id/bar/1"))
+	allocs := testing.AllocsPerRun(100, func() {
+		h.Handle(ctx, e)
+	})
+	if allocs != 0 {
+		t.Errorf("expected 0 allocations per event got %v", allocs)
+	}
+	if got := testutil.ToFloat64(HandlerEventsCounter.WithLabelValues("foo.id/bar/1")) - before; got != 101 {
+		t.Errorf("expected 101 events got %v", got)
+	}
+}
+
+func BenchmarkPublishHandlerMetrics(b *testing.B) {
+	h := PublishHandlerMetrics(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {}))
+	e := &ziggurat.Event{RoutingPath: "foo.id/foo/1"}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Handle(ctx, e)
+	}
+}
@@ -1,11 +1,69 @@
 package rabbitmq
 
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gojekfarm/ziggurat/v2/internal/configfile"
+)
+
 type QueueConfig struct {
-	QueueKey              string
-	DelayExpirationInMS   string
-	RetryCount            int
-	ConsumerPrefetchCount int
-	ConsumerCount         int
+	QueueKey              string `yaml:"queue_key"`
+	DelayExpirationInMS   string `yaml:"delay_expiration_in_ms"`
+	RetryCount            int    `yaml:"retry_count"`
+	ConsumerPrefetchCount int    `yaml:"consumer_prefetch_count"`
+	ConsumerCount         int    `yaml:"consumer_count"`
 }
 
 type Queues []QueueConfig
+
+// Validate returns an error listing every invalid field of every queue
+func (qs Queues) Validate() error {
+	var errs []error
+	keys := map[string]bool{}
+	for i, qc := range qs {
+		name := fmt.Sprintf("queue %d", i)
+		if qc.QueueKey == "" {
+			errs = append(errs, fmt.Errorf("rabbitmq: %s: QueueKey is required", name))
+		} else {
+			name = fmt.Sprintf("queue %q", qc.QueueKey)
+			if keys[qc.QueueKey] {
+				errs = append(errs, fmt.Errorf("rabbitmq: %s: QueueKey is duplicated", name))
+			}
+			keys[qc.QueueKey] = true
+		}
+		// the delay is used as the expiration of the messages in the delay queue
+		if d, err := strconv.Atoi(qc.DelayExpirationInMS); err != nil || d < 1 {
+			errs = append(errs, fmt.Errorf("rabbitmq: %s: DelayExpirationInMS must be a positive integer, got %q", name, qc.DelayExpirationInMS))
+		}
+		if qc.RetryCount < 0 {
+			errs = append(errs, fmt.Errorf("rabbitmq: %s: RetryCount must not be negative, got %d", name, qc.RetryCount))
+		}
+		if qc.ConsumerPrefetchCount < 0 {
+			errs = append(errs, fmt.Errorf("rabbitmq: %s: ConsumerPrefetchCount must not be negative, got %d", name, qc.ConsumerPrefetchCount))
+		}
+		if qc.ConsumerCount < 0 {
+			errs = append(errs, fmt.Errorf("rabbitmq: %s: ConsumerCount must not be negative, got %d", name, qc.ConsumerCount))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// QueuesFromFile reads the queues from a YAML or JSON file holding a list of queues,
+// the fields use their YAML names
+//
+//	# queues.yaml
+//	- queue_key: orders
+//	  delay_expiration_in_ms: "2000"
+//	  retry_count: 3
+//	  consumer_count: 2
+//
+// Unknown fields are errors, the returned error lists every invalid field
+func QueuesFromFile(path string) (Queues, error) {
+	var qs Queues
+	if err := configfile.Decode(path, &qs); err != nil {
+		return nil, fmt.Errorf("rabbitmq: %w", err)
+	}
+	return qs, qs.Validate()
+}
@@ -0,0 +1,75 @@
+package rabbitmq
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestQueues_Validate(t *testing.T) {
+	valid := Queues{{QueueKey: "orders", DelayExpirationInMS: "500", RetryCount: 3}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected no error got %v", err)
+	}
+
+	invalid := Queues{
+		{DelayExpirationInMS: "500"},
+		{QueueKey: "orders", DelayExpirationInMS: "1s", RetryCount: -1},
+		{QueueKey: "orders", DelayExpirationInMS: "500", ConsumerCount: -2},
+	}
+	err := invalid.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		"queue 0: QueueKey is required",
+		`queue "orders": DelayExpirationInMS must be a positive integer, got "1s"`,
+		`queue "orders": RetryCount must not be negative, got -1`,
+		`queue "orders": QueueKey is duplicated`,
+		`queue "orders": ConsumerCount must not be negative, got -2`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to contain %q got:\n%v", want, err)
+		}
+	}
+}
+
+func TestQueuesFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "queues.yaml")
+	content := `
+- queue_key: orders
+  delay_expiration_in_ms: "2000"
+  retry_count: 3
+  consumer_count: 2
+- queue_key: refunds
+  delay_expiration_in_ms: "500"
+  retry_count: 1
+  consumer_prefetch_count: 10
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := QueuesFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Queues{
+		{QueueKey: "orders", DelayExpirationInMS: "2000", RetryCount: 3, ConsumerCount: 2},
+		{QueueKey: "refunds", DelayExpirationInMS: "500", RetryCount: 1, ConsumerPrefetchCount: 10},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected queues (-want +got):\n%s", diff)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`[{"queue_key": "orders", "retries": 3}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := QueuesFromFile(invalid); err == nil || !strings.Contains(err.Error(), "field retries not found") {
+		t.Errorf("expected an unknown field error got %v", err)
+	}
+}
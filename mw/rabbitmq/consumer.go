@@ -35,9 +35,23 @@ func startConsumer(ctx context.Context, d *amqpextra.Dialer, c QueueConfig, h zi
 				ogl.Error("amqp unmarshal error", err)
 				return msg.Reject(true)
 			}
+			if event.Metadata == nil {
+				event.Metadata = map[string]any{}
+			}
+			event.Metadata[KeyQueue] = c.QueueKey
+			event.Metadata[KeyHeaders] = stringHeaders(msg.Headers)
 			ogl.Info("amqp processing message", map[string]interface{}{"consumer": consumerName})
-			h.Handle(ctx, &event)
-			return msg.Ack(false)
+			actx, ack := ziggurat.WithAck(ctx, func(err error) {
+				if err != nil {
+					ogl.Error("amqp event processing failed, requeueing", err, map[string]interface{}{"consumer": consumerName})
+					ogl.Error("amqp reject error", msg.Reject(true))
+					return
+				}
+				ogl.Error("amqp ack error", msg.Ack(false))
+			})
+			h.Handle(actx, &event)
+			ack.Release()
+			return nil
 		})),
 	)
 
@@ -46,3 +60,16 @@ func startConsumer(ctx context.Context, d *amqpextra.Dialer, c QueueConfig, h zi
 	}
 	return cons, nil
 }
+
+func stringHeaders(t amqp.Table) map[string]string {
+	headers := make(map[string]string, len(t))
+	for k, v := range t {
+		switch val := v.(type) {
+		case string:
+			headers[k] = val
+		case []byte:
+			headers[k] = string(val)
+		}
+	}
+	return headers
+}
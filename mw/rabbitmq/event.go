@@ -4,6 +4,12 @@ import "github.com/gojekfarm/ziggurat/v2"
 
 const KeyRetryCount = "rabbitmqAutoRetryCount"
 
+// KeyQueue holds the queue key from which the event was consumed
+const KeyQueue = "rabbitmqQueue"
+
+// KeyHeaders holds the string headers of the AMQP delivery as a map[string]string
+const KeyHeaders = "rabbitmqHeaders"
+
 func RetryCountFor(e *ziggurat.Event) int {
 	if e.Metadata == nil {
 		return 0
@@ -1,6 +1,7 @@
 package rabbitmq
 
 import (
+	"context"
 	"github.com/gojekfarm/ziggurat/v2"
 	"time"
 )
@@ -39,3 +40,13 @@ func WithConnectionTimeout(t time.Duration) Opts {
 		r.connTimeout = t
 	}
 }
+
+// HeaderInjector adds headers to the messages published by ARetry
+// it can be used to propagate the trace context of the event being retried
+type HeaderInjector func(ctx context.Context, headers map[string]any)
+
+func WithHeaderInjector(f HeaderInjector) Opts {
+	return func(r *ARetry) {
+		r.injectHeaders = f
+	}
+}
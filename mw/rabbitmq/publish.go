@@ -9,7 +9,9 @@ import (
 	"github.com/streadway/amqp"
 )
 
-func publishInternal(p amqpPublisher, queue string, retryCount int, delayExpiration string, event *ziggurat.Event) error {
+// publishInternal publishes the event to the delay queue or to the dlq once the retries are exhausted
+// headers are added to the published message along with the retry-origin header
+func publishInternal(p amqpPublisher, queue string, retryCount int, delayExpiration string, event *ziggurat.Event, headers map[string]any) error {
 
 	expiration := delayExpiration
 
@@ -34,13 +36,18 @@ func publishInternal(p amqpPublisher, queue string, retryCount int, delayExpirat
 		return err
 	}
 
+	h := map[string]interface{}{"retry-origin": "ziggurat-go"}
+	for k, v := range headers {
+		h[k] = v
+	}
+
 	msg := publisher.Message{
 		Exchange: exchange,
 		Key:      routingKey,
 		Publishing: amqp.Publishing{
 			Expiration: expiration,
 			Body:       eb,
-			Headers:    map[string]interface{}{"retry-origin": "ziggurat-go"},
+			Headers:    h,
 		},
 	}
 
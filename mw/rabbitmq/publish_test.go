@@ -54,7 +54,7 @@ func Test_publish(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			p := mockAMQPPublisher{}
 			p.On("Publish", c.WantMsg).Return(nil)
-			_ = publishInternal(&p, "foo", c.retryCount, "100", c.input)
+			_ = publishInternal(&p, "foo", c.retryCount, "100", c.input, nil)
 		})
 	}
 }
@@ -117,7 +117,7 @@ func Test_publish_retries(t *testing.T) {
 				}
 				want.Publishing.Body = toJSON(ziggurat.Event{Metadata: map[string]any{KeyRetryCount: retryCount}})
 				p.On("Publish", want).Return(nil).Once()
-				_ = publishInternal(&p, "foo", c.retryCount, "100", &e)
+				_ = publishInternal(&p, "foo", c.retryCount, "100", &e, nil)
 			}
 		})
 	}
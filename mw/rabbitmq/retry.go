@@ -50,6 +50,7 @@ type ARetry struct {
 	ogLogger      ziggurat.StructuredLogger
 	queueConfig   map[string]QueueConfig
 	publisherPool *publisherPool
+	injectHeaders HeaderInjector
 }
 
 func constructAMQPURL(host, username, password string) string {
@@ -98,7 +99,7 @@ func (r *ARetry) publish(c context.Context, event *ziggurat.Event, queue string)
 		return err
 	}
 	defer r.publisherPool.put(pub)
-	err = publishInternal(pub, queue, r.queueConfig[queue].RetryCount, r.queueConfig[queue].DelayExpirationInMS, event)
+	err = publishInternal(pub, queue, r.queueConfig[queue].RetryCount, r.queueConfig[queue].DelayExpirationInMS, event, r.headers(c))
 	return err
 }
 
@@ -125,18 +126,29 @@ func (r *ARetry) Publish(ctx context.Context, event *ziggurat.Event, queueKey st
 	if err != nil {
 		return err
 	}
+	headers := r.headers(ctx)
+	headers["retry-origin"] = "ziggurat-go"
 	msg := publisher.Message{
 		Exchange: exchange,
 		Key:      queueType,
 		Publishing: amqp.Publishing{
 			Expiration: expirationMS,
 			Body:       eb,
-			Headers:    map[string]interface{}{"retry-origin": "ziggurat-go"},
+			Headers:    headers,
 		},
 	}
 	return p.Publish(msg)
 }
 
+// headers returns the headers added by the HeaderInjector
+func (r *ARetry) headers(ctx context.Context) map[string]any {
+	headers := map[string]any{}
+	if r.injectHeaders != nil {
+		r.injectHeaders(ctx, headers)
+	}
+	return headers
+}
+
 func (r *ARetry) Retry(ctx context.Context, event *ziggurat.Event, queueKey string) error {
 	r.once.Do(func() {
 		r.ogLogger.Info("[amqp] running init function from retry")
@@ -0,0 +1,203 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/mw/rabbitmq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/gojekfarm/ziggurat/v2/mw/tracing"
+
+// RetryCountKey is the span attribute which holds the rabbitmq retry count of the event
+const RetryCountKey = attribute.Key("ziggurat.retry_count")
+
+// RoutingPathKey is the span attribute which holds the routing path of the event
+const RoutingPathKey = attribute.Key("ziggurat.routing_path")
+
+type config struct {
+	tp         trace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+type Opts func(c *config)
+
+// WithTracerProvider sets the tracer provider, the global tracer provider is used by default
+func WithTracerProvider(tp trace.TracerProvider) Opts {
+	return func(c *config) {
+		c.tp = tp
+	}
+}
+
+// WithPropagator sets the propagator, the W3C trace context propagator is used by default
+func WithPropagator(p propagation.TextMapPropagator) Opts {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+func newConfig(opts []Opts) *config {
+	c := &config{
+		tp:         otel.GetTracerProvider(),
+		propagator: propagation.TraceContext{},
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// headerCarrier adapts the headers stored in the event metadata to a propagation.TextMapCarrier
+type headerCarrier map[string]string
+
+func (h headerCarrier) Get(key string) string {
+	return h[key]
+}
+
+func (h headerCarrier) Set(key string, value string) {
+	h[key] = value
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// toCarrier handles the headers set by the message consumers and the ones
+// which went through a JSON round trip while being retried
+func toCarrier(v any) (headerCarrier, bool) {
+	switch headers := v.(type) {
+	case map[string]string:
+		return headers, true
+	case map[string]any:
+		hc := make(headerCarrier, len(headers))
+		for k, val := range headers {
+			if s, ok := val.(string); ok {
+				hc[k] = s
+			}
+		}
+		return hc, true
+	default:
+		return nil, false
+	}
+}
+
+// carrierFor prefers the AMQP delivery headers over the kafka message headers,
+// a retried event carries both and the AMQP headers point to the span which retried it
+func carrierFor(event *ziggurat.Event) headerCarrier {
+	for _, k := range []string{rabbitmq.KeyHeaders, "kafka-headers"} {
+		if hc, ok := toCarrier(event.Metadata[k]); ok {
+			return hc
+		}
+	}
+	return headerCarrier{}
+}
+
+func attributesFor(event *ziggurat.Event) (string, []attribute.KeyValue) {
+	attrs := []attribute.KeyValue{
+		semconv.MessagingOperationTypeDeliver,
+		RoutingPathKey.String(event.RoutingPath),
+		RetryCountKey.Int(rabbitmq.RetryCountFor(event)),
+	}
+
+	if queue, ok := event.Metadata[rabbitmq.KeyQueue].(string); ok {
+		attrs = append(attrs, semconv.MessagingSystemRabbitmq, semconv.MessagingDestinationName(queue))
+		return queue, attrs
+	}
+
+	destination := event.RoutingPath
+	if event.EventType == "kafka" {
+		attrs = append(attrs, semconv.MessagingSystemKafka)
+		if topic, ok := event.Metadata["kafka-topic"].(string); ok {
+			destination = topic
+			attrs = append(attrs, semconv.MessagingDestinationName(topic))
+		}
+		if p, ok := asInt(event.Metadata["kafka-partition"]); ok {
+			attrs = append(attrs, semconv.MessagingDestinationPartitionID(strconv.Itoa(p)))
+		}
+		if o, ok := asInt(event.Metadata["kafka-offset"]); ok {
+			attrs = append(attrs, semconv.MessagingKafkaMessageOffset(o))
+		}
+		if len(event.Key) > 0 {
+			attrs = append(attrs, semconv.MessagingKafkaMessageKey(string(event.Key)))
+		}
+	}
+	return destination, attrs
+}
+
+// asInt handles numbers which went through a JSON round trip
+func asInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
+
+// Trace returns a middleware which starts a consumer span for every event
+// the trace context is extracted from the AMQP delivery headers or the kafka message headers
+// the span is available to the next handler through the context
+//
+// The span ends once the event is acknowledged so that it covers the processing of handlers
+// which defer the acknowledgement, a failed acknowledgement sets the error status of the span
+func Trace(opts ...Opts) ziggurat.Middleware {
+	c := newConfig(opts)
+	tracer := c.tp.Tracer(instrumentationName)
+	return func(next ziggurat.Handler) ziggurat.Handler {
+		f := func(ctx context.Context, event *ziggurat.Event) {
+			ctx = c.propagator.Extract(ctx, carrierFor(event))
+			destination, attrs := attributesFor(event)
+			ctx, span := tracer.Start(ctx, fmt.Sprintf("%s process", destination),
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(attrs...))
+			ack, ok := ziggurat.DeferAck(ctx)
+			if !ok {
+				defer span.End()
+				next.Handle(ctx, event)
+				return
+			}
+			actx, a := ziggurat.WithAck(ctx, func(err error) {
+				if err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+				}
+				span.End()
+				ack(err)
+			})
+			next.Handle(actx, event)
+			a.Release()
+		}
+		return ziggurat.HandlerFunc(f)
+	}
+}
+
+// HeaderInjector returns a rabbitmq.HeaderInjector which injects the trace context
+// of the span in the context, a retried event stays in the trace of the original event
+//
+//	ar := rabbitmq.AutoRetry(queues, rabbitmq.WithHeaderInjector(tracing.HeaderInjector()))
+func HeaderInjector(opts ...Opts) rabbitmq.HeaderInjector {
+	c := newConfig(opts)
+	return func(ctx context.Context, headers map[string]any) {
+		hc := headerCarrier{}
+		c.propagator.Inject(ctx, hc)
+		for k, v := range hc {
+			headers[k] = v
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/mw/rabbitmq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	traceID    = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentSpan = "00f067aa0ba902b7"
+)
+
+func newProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exp := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)), exp
+}
+
+func attrMap(kvs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestTrace_Kafka(t *testing.T) {
+	tp, exp := newProvider()
+	var handlerSpan trace.SpanContext
+	h := ziggurat.Use(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		handlerSpan = trace.SpanContextFromContext(ctx)
+	}), Trace(WithTracerProvider(tp)))
+
+	h.Handle(context.Background(), &ziggurat.Event{
+		Key:         []byte("order-1"),
+		RoutingPath: "foo.id/orders/3",
+		EventType:   "kafka",
+		Metadata: map[string]any{
+			"kafka-topic":     "orders",
+			"kafka-partition": 3,
+			"kafka-offset":    int64(42),
+			"kafka-headers":   map[string]string{"traceparent": "00-" + traceID + "-" + parentSpan + "-01"},
+		},
+	})
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span got %d", len(spans))
+	}
+	s := spans[0]
+	if s.Name != "orders process" || s.SpanKind != trace.SpanKindConsumer {
+		t.Errorf("unexpected span name %q kind %v", s.Name, s.SpanKind)
+	}
+	if s.SpanContext.TraceID().String() != traceID || s.Parent.SpanID().String() != parentSpan {
+		t.Errorf("expected span to continue trace %s got %s parent %s", traceID, s.SpanContext.TraceID(), s.Parent.SpanID())
+	}
+	if handlerSpan.SpanID() != s.SpanContext.SpanID() {
+		t.Error("expected the span to be available to the handler")
+	}
+
+	attrs := attrMap(s.Attributes)
+	want := map[attribute.Key]attribute.Value{
+		semconv.MessagingSystemKey:                 attribute.StringValue("kafka"),
+		semconv.MessagingDestinationNameKey:        attribute.StringValue("orders"),
+		semconv.MessagingDestinationPartitionIDKey: attribute.StringValue("3"),
+		semconv.MessagingKafkaMessageOffsetKey:     attribute.IntValue(42),
+		semconv.MessagingKafkaMessageKeyKey:        attribute.StringValue("order-1"),
+		RetryCountKey:                              attribute.IntValue(0),
+		RoutingPathKey:                             attribute.StringValue("foo.id/orders/3"),
+	}
+	for k, v := range want {
+		if attrs[k] != v {
+			t.Errorf("expected attribute %s=%v got %v", k, v.Emit(), attrs[k].Emit())
+		}
+	}
+}
+
+func TestTrace_RetryStaysInTrace(t *testing.T) {
+	tp, exp := newProvider()
+	injector := HeaderInjector()
+	var published map[string]any
+
+	// the first handler retries the event, like ARetry.Retry would
+	retrying := ziggurat.Use(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		published = map[string]any{"retry-origin": "ziggurat-go"}
+		injector(ctx, published)
+	}), Trace(WithTracerProvider(tp)))
+
+	retrying.Handle(context.Background(), &ziggurat.Event{
+		EventType: "kafka",
+		Metadata: map[string]any{
+			"kafka-topic":   "orders",
+			"kafka-headers": map[string]string{"traceparent": "00-" + traceID + "-" + parentSpan + "-01"},
+		},
+	})
+
+	if _, ok := published["traceparent"]; !ok {
+		t.Fatalf("expected the trace context to be injected got %v", published)
+	}
+
+	// the retried event as it is consumed from rabbitmq after a JSON round trip
+	headers := map[string]any{}
+	for k, v := range published {
+		headers[k] = v
+	}
+	retried := ziggurat.Use(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {}), Trace(WithTracerProvider(tp)))
+	retried.Handle(context.Background(), &ziggurat.Event{
+		EventType: "kafka",
+		Metadata: map[string]any{
+			"kafka-topic":          "orders",
+			"kafka-headers":        map[string]any{"traceparent": "00-" + traceID + "-" + parentSpan + "-01"},
+			rabbitmq.KeyHeaders:    headers,
+			rabbitmq.KeyQueue:      "orders_retry",
+			rabbitmq.KeyRetryCount: float64(1),
+		},
+	})
+
+	spans := exp.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans got %d", len(spans))
+	}
+	first, second := spans[0], spans[1]
+	if second.SpanContext.TraceID() != first.SpanContext.TraceID() {
+		t.Errorf("expected the retried event to stay in trace %s got %s", first.SpanContext.TraceID(), second.SpanContext.TraceID())
+	}
+	if second.Parent.SpanID() != first.SpanContext.SpanID() {
+		t.Errorf("expected the retried span to be a child of the retrying span")
+	}
+	attrs := attrMap(second.Attributes)
+	if attrs[semconv.MessagingSystemKey].AsString() != "rabbitmq" || attrs[semconv.MessagingDestinationNameKey].AsString() != "orders_retry" {
+		t.Errorf("unexpected rabbitmq attributes %v", second.Attributes)
+	}
+	if attrs[RetryCountKey].AsInt64() != 1 {
+		t.Errorf("expected retry count 1 got %v", attrs[RetryCountKey].AsInt64())
+	}
+}
+
+func TestTrace_NoParent(t *testing.T) {
+	tp, exp := newProvider()
+	h := ziggurat.Use(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {}), Trace(WithTracerProvider(tp)))
+	h.Handle(context.Background(), &ziggurat.Event{RoutingPath: "numpath", EventType: "numbergen"})
+	spans := exp.GetSpans()
+	if len(spans) != 1 || spans[0].Parent.IsValid() {
+		t.Fatalf("expected a single root span got %v", spans)
+	}
+	if spans[0].Name != "numpath process" {
+		t.Errorf("expected span name %q got %q", "numpath process", spans[0].Name)
+	}
+}
+
+func TestTrace_EndsOnDeferredAck(t *testing.T) {
+	tp, exp := newProvider()
+	var deferred ziggurat.AckFunc
+	h := ziggurat.Use(ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		deferred, _ = ziggurat.DeferAck(ctx)
+	}), Trace(WithTracerProvider(tp)))
+
+	var acked []error
+	ctx, ack := ziggurat.WithAck(context.Background(), func(err error) {
+		acked = append(acked, err)
+	})
+	h.Handle(ctx, &ziggurat.Event{RoutingPath: "foo.id/orders/3", EventType: "kafka"})
+	ack.Release()
+	if n := len(exp.GetSpans()); n != 0 {
+		t.Fatalf("expected the span to end once the event is acknowledged got %d spans", n)
+	}
+
+	wantErr := errors.New("flush failed")
+	deferred(wantErr)
+	spans := exp.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span got %d", len(spans))
+	}
+	if spans[0].Status.Code != codes.Error || spans[0].Status.Description != wantErr.Error() {
+		t.Errorf("expected the error status got %v", spans[0].Status)
+	}
+	if len(acked) != 1 || !errors.Is(acked[0], wantErr) {
+		t.Errorf("expected the event to be acknowledged with %v got %v", wantErr, acked)
+	}
+}
@@ -0,0 +1,57 @@
+package nats
+
+import "time"
+
+const (
+	defaultBatchSize = 10
+	defaultMaxWait   = time.Second
+)
+
+type ConsumerConfig struct {
+	// URL is used to connect when ConsumerGroup.Conn is nil, it defaults to nats.DefaultURL
+	URL string
+	// Stream is the JetStream stream to consume from, it must exist
+	Stream string
+	// Durable is the name of the durable pull consumer, it is created or updated with the config below
+	Durable string
+	// Subjects filters the subjects of the stream delivered to the consumer, all subjects are delivered when empty
+	Subjects []string
+	// ConsumerCount is the number of goroutines fetching from the durable consumer
+	ConsumerCount int
+	// BatchSize is the maximum number of messages returned by a single fetch, it defaults to 10
+	BatchSize int
+	// MaxWait is the time a fetch waits for messages, it defaults to 1s
+	// it also bounds the time taken to shut down
+	MaxWait time.Duration
+	// AckWait is the time the server waits for an acknowledgement before redelivering a message
+	AckWait time.Duration
+	// MaxDeliver is the number of times a message is delivered before it is terminated, 0 means unlimited
+	MaxDeliver int
+	// BackOff is the delay before a failed message is redelivered, the n-th failure uses BackOff[n-1]
+	// and the last delay is used once the list is exhausted, a failed message is redelivered immediately when empty
+	BackOff []time.Duration
+	// DeliverPolicy is used when the durable consumer is created, it defaults to jetstream.DeliverAllPolicy
+	DeliverPolicy string
+}
+
+func (c ConsumerConfig) withDefaults() ConsumerConfig {
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.MaxWait <= 0 {
+		c.MaxWait = defaultMaxWait
+	}
+	return c
+}
+
+// backoff returns the delay for the given delivery attempt
+func (c ConsumerConfig) backoff(numDelivered uint64) time.Duration {
+	if len(c.BackOff) == 0 || numDelivered == 0 {
+		return 0
+	}
+	i := int(numDelivered) - 1
+	if i >= len(c.BackOff) {
+		i = len(c.BackOff) - 1
+	}
+	return c.BackOff[i]
+}
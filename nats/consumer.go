@@ -0,0 +1,128 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	EventType = "nats"
+	// KeySubject holds the subject the message was published on
+	KeySubject = "nats-subject"
+	// KeyStream holds the name of the stream
+	KeyStream = "nats-stream"
+	// KeyConsumer holds the name of the durable consumer
+	KeyConsumer = "nats-consumer"
+	// KeySequence holds the stream sequence of the message as an uint64
+	KeySequence = "nats-sequence"
+	// KeyRetryCount holds the number of times the message was delivered before, as an int
+	KeyRetryCount = "nats-retry-count"
+	// KeyHeaders holds the message headers as a map[string]string
+	KeyHeaders = "nats-headers"
+)
+
+var deliverPolicies = map[string]jetstream.DeliverPolicy{
+	"":     jetstream.DeliverAllPolicy,
+	"all":  jetstream.DeliverAllPolicy,
+	"last": jetstream.DeliverLastPolicy,
+	"new":  jetstream.DeliverNewPolicy,
+}
+
+// ConsumerGroup consumes a JetStream stream using a durable pull consumer with explicit acknowledgements
+// a message is acknowledged once it is processed, a failed message is redelivered after the configured
+// BackOff until it has been delivered MaxDeliver times after which it is terminated
+// the RoutingPath of an event is the subject of the message
+type ConsumerGroup struct {
+	// Conn is used to talk to the server, a connection is created from the GroupConfig when it is nil
+	Conn        *nats.Conn
+	GroupConfig ConsumerConfig
+	Logger      ziggurat.StructuredLogger
+}
+
+// Consume blocks until ctx is done or a consumer fails
+// it waits for the pending acknowledgements before returning
+func (cg *ConsumerGroup) Consume(ctx context.Context, h ziggurat.Handler) error {
+	if cg.Logger == nil {
+		cg.Logger = logger.NOOP
+	}
+	cfg := cg.GroupConfig.withDefaults()
+	if cfg.Stream == "" || cfg.Durable == "" {
+		return errors.New("nats: Stream and Durable are required")
+	}
+	policy, ok := deliverPolicies[cfg.DeliverPolicy]
+	if !ok {
+		return fmt.Errorf("nats: unknown deliver policy %q", cfg.DeliverPolicy)
+	}
+	if cfg.ConsumerCount < 1 {
+		cg.Logger.Warn("ConsumerConfig.ConsumerCount < 1, no consumers will be started")
+	}
+
+	nc := cg.Conn
+	if nc == nil {
+		url := cfg.URL
+		if url == "" {
+			url = nats.DefaultURL
+		}
+		var err error
+		nc, err = nats.Connect(url, nats.MaxReconnects(-1))
+		if err != nil {
+			return fmt.Errorf("nats: %w", err)
+		}
+		defer nc.Close()
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+	maxDeliver := cfg.MaxDeliver
+	if maxDeliver <= 0 {
+		maxDeliver = -1
+	}
+	cons, err := js.CreateOrUpdateConsumer(ctx, cfg.Stream, jetstream.ConsumerConfig{
+		Durable:        cfg.Durable,
+		FilterSubjects: cfg.Subjects,
+		AckPolicy:      jetstream.AckExplicitPolicy,
+		AckWait:        cfg.AckWait,
+		MaxDeliver:     maxDeliver,
+		DeliverPolicy:  policy,
+	})
+	if err != nil {
+		return fmt.Errorf("nats: error creating consumer %s on stream %s: %w", cfg.Durable, cfg.Stream, err)
+	}
+
+	var wg sync.WaitGroup
+	workers := make([]*worker, cfg.ConsumerCount)
+	for i := range workers {
+		w := &worker{
+			consumer: cons,
+			config:   cfg,
+			id:       fmt.Sprintf("%s_%d", cfg.Durable, i),
+			handler:  h,
+			logger:   cg.Logger,
+		}
+		workers[i] = w
+		cg.Logger.Info("spawning nats worker", map[string]any{"id": w.id, "stream": cfg.Stream})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, w := range workers {
+		if w.err != nil {
+			errs = append(errs, fmt.Errorf("%s worker failed with error: %w", w.id, w.err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,191 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/ziggurattest"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func runServer(t *testing.T) *nats.Conn {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	t.Cleanup(s.Shutdown)
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	return nc
+}
+
+func createStream(t *testing.T, nc *nats.Conn, name string, subjects ...string) jetstream.JetStream {
+	t.Helper()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.CreateStream(context.Background(), jetstream.StreamConfig{Name: name, Subjects: subjects})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return js
+}
+
+func publish(t *testing.T, js jetstream.JetStream, subject, value string) {
+	t.Helper()
+	if _, err := js.Publish(context.Background(), subject, []byte(value)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func consume(ctx context.Context, cg *ConsumerGroup, h ziggurat.Handler) chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- cg.Consume(ctx, h)
+	}()
+	return done
+}
+
+func pendingAcks(t *testing.T, js jetstream.JetStream, stream, durable string) int {
+	t.Helper()
+	c, err := js.Consumer(context.Background(), stream, durable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := c.Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.NumAckPending + int(info.NumPending)
+}
+
+func TestConsumerGroup_Consume(t *testing.T) {
+	nc := runServer(t)
+	js := createStream(t, nc, "ORDERS", "orders.>")
+	publish(t, js, "orders.created", "foo")
+	publish(t, js, "orders.paid", "bar")
+	publish(t, js, "orders.created", "baz")
+
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	r := ziggurattest.NewRecorder()
+	cg := &ConsumerGroup{
+		Conn: nc,
+		GroupConfig: ConsumerConfig{
+			Stream:        "ORDERS",
+			Durable:       "billing",
+			Subjects:      []string{"orders.created"},
+			ConsumerCount: 1,
+			MaxWait:       100 * time.Millisecond,
+		},
+	}
+	done := consume(ctx, cg, r)
+	if !r.WaitFor(t, 2, 5*time.Second) {
+		t.FailNow()
+	}
+	cfn()
+	if err := <-done; err != nil {
+		t.Fatalf("expected nil error got %v", err)
+	}
+
+	r.AssertValues(t, "foo", "baz")
+	r.AssertRoutingPaths(t, "orders.created", "orders.created")
+	e := r.Events()[0]
+	if e.Metadata[KeyStream] != "ORDERS" || e.Metadata[KeyConsumer] != "billing" {
+		t.Errorf("unexpected metadata %v", e.Metadata)
+	}
+	if e.Metadata[KeySequence] != uint64(1) || e.Metadata[KeyRetryCount] != 0 {
+		t.Errorf("unexpected metadata %v", e.Metadata)
+	}
+	if n := pendingAcks(t, js, "ORDERS", "billing"); n != 0 {
+		t.Errorf("expected no pending messages got %d", n)
+	}
+}
+
+func TestConsumerGroup_RetriesWithBackOff(t *testing.T) {
+	nc := runServer(t)
+	js := createStream(t, nc, "ORDERS", "orders.>")
+	publish(t, js, "orders.created", "foo")
+
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+
+	var mu sync.Mutex
+	var deliveries []time.Time
+	var retryCounts []int
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		mu.Lock()
+		deliveries = append(deliveries, time.Now())
+		retryCounts = append(retryCounts, event.Metadata[KeyRetryCount].(int))
+		mu.Unlock()
+		ack, _ := ziggurat.DeferAck(ctx)
+		ack(errors.New("processing failed"))
+	})
+	cg := &ConsumerGroup{
+		Conn: nc,
+		GroupConfig: ConsumerConfig{
+			Stream:        "ORDERS",
+			Durable:       "billing",
+			ConsumerCount: 1,
+			MaxWait:       50 * time.Millisecond,
+			MaxDeliver:    3,
+			BackOff:       []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+	}
+	done := consume(ctx, cg, h)
+	time.Sleep(time.Second)
+	cfn()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(deliveries) != 3 {
+		t.Fatalf("expected 3 deliveries got %d", len(deliveries))
+	}
+	for i, c := range retryCounts {
+		if c != i {
+			t.Errorf("expected retry count %d got %d", i, c)
+		}
+	}
+	if d := deliveries[1].Sub(deliveries[0]); d < 100*time.Millisecond {
+		t.Errorf("expected the first redelivery after 100ms got %v", d)
+	}
+	if d := deliveries[2].Sub(deliveries[1]); d < 200*time.Millisecond {
+		t.Errorf("expected the second redelivery after 200ms got %v", d)
+	}
+	if n := pendingAcks(t, js, "ORDERS", "billing"); n != 0 {
+		t.Errorf("expected the message to be terminated got %d pending", n)
+	}
+}
+
+func TestConsumerConfig_Backoff(t *testing.T) {
+	c := ConsumerConfig{BackOff: []time.Duration{time.Second, 2 * time.Second}}
+	cases := map[uint64]time.Duration{0: 0, 1: time.Second, 2: 2 * time.Second, 5: 2 * time.Second}
+	for n, want := range cases {
+		if got := c.backoff(n); got != want {
+			t.Errorf("expected backoff %v for delivery %d got %v", want, n, got)
+		}
+	}
+}
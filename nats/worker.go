@@ -0,0 +1,132 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type worker struct {
+	consumer jetstream.Consumer
+	config   ConsumerConfig
+	id       string
+	handler  ziggurat.Handler
+	logger   ziggurat.StructuredLogger
+	inflight sync.WaitGroup
+	err      error
+}
+
+func (w *worker) run(ctx context.Context) {
+	// wait for the deferred acknowledgements to be sent
+	defer w.inflight.Wait()
+
+	for ctx.Err() == nil {
+		batch, err := w.consumer.Fetch(w.config.BatchSize, jetstream.FetchMaxWait(w.config.MaxWait))
+		switch {
+		case errors.Is(err, jetstream.ErrConsumerDeleted), errors.Is(err, jetstream.ErrConsumerNotFound),
+			errors.Is(err, nats.ErrConnectionClosed):
+			w.err = err
+			return
+		case err != nil:
+			w.logger.Error("nats fetch error", err, map[string]any{"worker-id": w.id})
+			w.backoff(ctx)
+			continue
+		}
+
+		for msg := range batch.Messages() {
+			// messages fetched after shutdown began are redelivered right away
+			if ctx.Err() != nil {
+				w.nak(msg, 0)
+				continue
+			}
+			w.process(ctx, msg)
+		}
+		if err := batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) && ctx.Err() == nil {
+			w.logger.Error("nats fetch error", err, map[string]any{"worker-id": w.id})
+		}
+	}
+}
+
+func (w *worker) backoff(ctx context.Context) {
+	t := time.NewTimer(w.config.MaxWait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+func (w *worker) process(ctx context.Context, msg jetstream.Msg) {
+	md, err := msg.Metadata()
+	if err != nil {
+		w.logger.Error("nats invalid message metadata", err, map[string]any{"worker-id": w.id, "subject": msg.Subject()})
+		w.nak(msg, 0)
+		return
+	}
+
+	headers := make(map[string]string, len(msg.Headers()))
+	for k := range msg.Headers() {
+		headers[k] = msg.Headers().Get(k)
+	}
+	event := &ziggurat.Event{
+		Metadata: map[string]any{
+			KeySubject:    msg.Subject(),
+			KeyStream:     md.Stream,
+			KeyConsumer:   md.Consumer,
+			KeySequence:   md.Sequence.Stream,
+			KeyRetryCount: int(md.NumDelivered) - 1,
+			KeyHeaders:    headers,
+		},
+		Value:             msg.Data(),
+		Key:               []byte{},
+		RoutingPath:       msg.Subject(),
+		ProducerTimestamp: md.Timestamp,
+		ReceivedTimestamp: time.Now(),
+		EventType:         EventType,
+	}
+
+	w.inflight.Add(1)
+	actx, ack := ziggurat.WithAck(ctx, func(err error) {
+		defer w.inflight.Done()
+		w.acknowledge(msg, md, err)
+	})
+	w.handler.Handle(actx, event)
+	ack.Release()
+}
+
+// acknowledge maps the outcome of the handler to the JetStream acknowledgements,
+// a failed message is redelivered after its backoff unless it reached MaxDeliver
+func (w *worker) acknowledge(msg jetstream.Msg, md *jetstream.MsgMetadata, err error) {
+	kvs := map[string]any{"worker-id": w.id, "subject": msg.Subject(), "sequence": md.Sequence.Stream}
+	if err == nil {
+		if ackErr := msg.Ack(); ackErr != nil {
+			w.logger.Error("nats ack error", ackErr, kvs)
+		}
+		return
+	}
+
+	if w.config.MaxDeliver > 0 && md.NumDelivered >= uint64(w.config.MaxDeliver) {
+		w.logger.Error("nats message processing failed, max deliveries reached", err, kvs)
+		if termErr := msg.TermWithReason(err.Error()); termErr != nil {
+			w.logger.Error("nats term error", termErr, kvs)
+		}
+		return
+	}
+	w.logger.Error("nats message processing failed, redelivering", err, kvs)
+	w.nak(msg, w.config.backoff(md.NumDelivered))
+}
+
+func (w *worker) nak(msg jetstream.Msg, delay time.Duration) {
+	var err error
+	if delay > 0 {
+		err = msg.NakWithDelay(delay)
+	} else {
+		err = msg.Nak()
+	}
+	w.logger.Error("nats nak error", err, map[string]any{"worker-id": w.id, "subject": msg.Subject()})
+}
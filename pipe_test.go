@@ -43,3 +43,41 @@ func TestPipeHandlers(t *testing.T) {
 	finalHandler := pipe(actualHandler, mw1, mw2)
 	finalHandler.Handle(context.Background(), &Event{})
 }
+
+func TestPipeComposesOnce(t *testing.T) {
+	var built int
+	mw := func(next Handler) Handler {
+		built++
+		return HandlerFunc(func(ctx context.Context, event *Event) {
+			next.Handle(ctx, event)
+		})
+	}
+	h := Use(HandlerFunc(func(ctx context.Context, event *Event) {}), mw, mw, mw)
+	e := &Event{}
+	ctx := context.Background()
+	allocs := testing.AllocsPerRun(100, func() {
+		h.Handle(ctx, e)
+	})
+	if built != 3 {
+		t.Errorf("expected the middlewares to be built once got %d", built)
+	}
+	if allocs != 0 {
+		t.Errorf("expected 0 allocations per event got %v", allocs)
+	}
+}
+
+func BenchmarkPipe(b *testing.B) {
+	mw := func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, event *Event) {
+			next.Handle(ctx, event)
+		})
+	}
+	h := Use(HandlerFunc(func(ctx context.Context, event *Event) {}), mw, mw, mw, mw, mw)
+	e := &Event{}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Handle(ctx, e)
+	}
+}
@@ -0,0 +1,66 @@
+package redis
+
+import "time"
+
+const (
+	defaultBlock        = time.Second
+	defaultCount        = 10
+	defaultClaimMinIdle = time.Minute
+	defaultValueField   = "value"
+	defaultKeyField     = "key"
+)
+
+type StreamConfig struct {
+	// Addr is used to create a client when ConsumerGroup.Client is nil
+	Addr     string
+	Password string
+	DB       int
+	// Streams are read using XREADGROUP, the group is created on every stream if it does not exist
+	Streams []string
+	Group   string
+	// ConsumerName is the prefix of the consumer names within the group, it defaults to the host name
+	// every consumer is named <ConsumerName>_<n>
+	ConsumerName  string
+	ConsumerCount int
+	// Count is the maximum number of entries read by a single XREADGROUP call, it defaults to 10
+	Count int64
+	// Block is the time XREADGROUP waits for new entries, it defaults to 1s
+	// it also bounds the time taken to shut down
+	Block time.Duration
+	// StartID is the ID the group starts reading from when it is created, it defaults to "$"
+	StartID string
+	// ClaimMinIdle is the time an entry has to be pending before it is reclaimed using XAUTOCLAIM, it defaults to 1m
+	// entries which failed processing are not acknowledged and are retried once they are reclaimed
+	ClaimMinIdle time.Duration
+	// ClaimInterval is the time between XAUTOCLAIM calls of a consumer, it defaults to ClaimMinIdle
+	// a negative value disables reclaiming
+	ClaimInterval time.Duration
+	// ValueField and KeyField are the entry fields used as the event value and key, they default to "value" and "key"
+	ValueField string
+	KeyField   string
+}
+
+func (c StreamConfig) withDefaults() StreamConfig {
+	if c.Count <= 0 {
+		c.Count = defaultCount
+	}
+	if c.Block <= 0 {
+		c.Block = defaultBlock
+	}
+	if c.StartID == "" {
+		c.StartID = "$"
+	}
+	if c.ClaimMinIdle <= 0 {
+		c.ClaimMinIdle = defaultClaimMinIdle
+	}
+	if c.ClaimInterval == 0 {
+		c.ClaimInterval = c.ClaimMinIdle
+	}
+	if c.ValueField == "" {
+		c.ValueField = defaultValueField
+	}
+	if c.KeyField == "" {
+		c.KeyField = defaultKeyField
+	}
+	return c
+}
@@ -0,0 +1,102 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	EventType = "redis"
+	// KeyStream holds the name of the stream the entry was read from
+	KeyStream = "redis-stream"
+	// KeyGroup holds the name of the consumer group
+	KeyGroup = "redis-group"
+	// KeyID holds the ID of the stream entry
+	KeyID = "redis-id"
+	// KeyFields holds all the fields of the stream entry as a map[string]string
+	KeyFields = "redis-fields"
+	// KeyReclaimed is set to true for entries reclaimed from another consumer using XAUTOCLAIM
+	KeyReclaimed = "redis-reclaimed"
+)
+
+// ConsumerGroup reads entries from Redis Streams using XREADGROUP
+// every entry is acknowledged with XACK once it is processed,
+// entries which fail processing stay pending and are reclaimed using XAUTOCLAIM
+// the RoutingPath of an event is <group>/<stream>
+type ConsumerGroup struct {
+	// Client is used to talk to redis, a client is created from the GroupConfig when it is nil
+	Client      goredis.UniversalClient
+	GroupConfig StreamConfig
+	Logger      ziggurat.StructuredLogger
+}
+
+// Consume blocks until ctx is done or a consumer fails
+// it waits for the pending acknowledgements before returning
+func (cg *ConsumerGroup) Consume(ctx context.Context, h ziggurat.Handler) error {
+	if cg.Logger == nil {
+		cg.Logger = logger.NOOP
+	}
+	cfg := cg.GroupConfig.withDefaults()
+	if len(cfg.Streams) == 0 || cfg.Group == "" {
+		return errors.New("redis: Streams and Group are required")
+	}
+	if cfg.ConsumerCount < 1 {
+		cg.Logger.Warn("StreamConfig.ConsumerCount < 1, no consumers will be started")
+	}
+	if cfg.ConsumerName == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("redis: %w", err)
+		}
+		cfg.ConsumerName = host
+	}
+
+	client := cg.Client
+	if client == nil {
+		client = goredis.NewClient(&goredis.Options{Addr: cfg.Addr, Password: cfg.Password, DB: cfg.DB})
+		defer client.Close()
+	}
+
+	for _, s := range cfg.Streams {
+		err := client.XGroupCreateMkStream(ctx, s, cfg.Group, cfg.StartID).Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("redis: error creating group %s on stream %s: %w", cfg.Group, s, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	workers := make([]*worker, cfg.ConsumerCount)
+	for i := range workers {
+		w := &worker{
+			client:  client,
+			config:  cfg,
+			name:    fmt.Sprintf("%s_%d", cfg.ConsumerName, i),
+			handler: h,
+			logger:  cg.Logger,
+		}
+		workers[i] = w
+		cg.Logger.Info("spawning redis consumer", map[string]any{"name": w.name, "group": cfg.Group})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, w := range workers {
+		if w.err != nil {
+			errs = append(errs, fmt.Errorf("%s consumer failed with error: %w", w.name, w.err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,205 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/ziggurattest"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func newClient(t *testing.T) goredis.UniversalClient {
+	t.Helper()
+	s := miniredis.RunT(t)
+	c := goredis.NewClient(&goredis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func xadd(t *testing.T, c goredis.UniversalClient, stream string, values ...string) {
+	t.Helper()
+	err := c.XAdd(context.Background(), &goredis.XAddArgs{Stream: stream, Values: values}).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func pending(t *testing.T, c goredis.UniversalClient, stream, group string) int64 {
+	t.Helper()
+	p, err := c.XPending(context.Background(), stream, group).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Count
+}
+
+func consume(ctx context.Context, cg *ConsumerGroup, h ziggurat.Handler) chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- cg.Consume(ctx, h)
+	}()
+	return done
+}
+
+func TestConsumerGroup_Consume(t *testing.T) {
+	client := newClient(t)
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+
+	r := ziggurattest.NewRecorder()
+	cg := &ConsumerGroup{
+		Client: client,
+		GroupConfig: StreamConfig{
+			Streams:       []string{"orders", "payments"},
+			Group:         "billing",
+			ConsumerName:  "test",
+			ConsumerCount: 2,
+			StartID:       "0",
+			Block:         50 * time.Millisecond,
+		},
+	}
+	xadd(t, client, "orders", "key", "k1", "value", "foo", "source", "web")
+	xadd(t, client, "payments", "value", "bar")
+	done := consume(ctx, cg, r)
+
+	if !r.WaitFor(t, 2, 2*time.Second) {
+		t.FailNow()
+	}
+	cfn()
+	if err := <-done; err != nil {
+		t.Fatalf("expected nil error got %v", err)
+	}
+
+	paths := map[string]*ziggurat.Event{}
+	for _, e := range r.Events() {
+		paths[e.RoutingPath] = e
+	}
+	e, ok := paths["billing/orders"]
+	if !ok {
+		t.Fatalf("expected an event routed to billing/orders got %v", paths)
+	}
+	if string(e.Value) != "foo" || string(e.Key) != "k1" {
+		t.Errorf("expected key k1 and value foo got %q %q", e.Key, e.Value)
+	}
+	if e.Metadata[KeyFields].(map[string]string)["source"] != "web" {
+		t.Errorf("expected the source field in the metadata got %v", e.Metadata[KeyFields])
+	}
+	if e.EventType != EventType {
+		t.Errorf("expected event type %q got %q", EventType, e.EventType)
+	}
+	if _, ok := paths["billing/payments"]; !ok {
+		t.Errorf("expected an event routed to billing/payments got %v", paths)
+	}
+
+	for _, s := range []string{"orders", "payments"} {
+		if n := pending(t, client, s, "billing"); n != 0 {
+			t.Errorf("expected no pending entries on %s got %d", s, n)
+		}
+	}
+}
+
+func TestConsumerGroup_ReclaimsFailedEntries(t *testing.T) {
+	client := newClient(t)
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+
+	var attempts atomic.Int32
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		ack, _ := ziggurat.DeferAck(ctx)
+		if attempts.Add(1) == 1 {
+			ack(errors.New("processing failed"))
+			return
+		}
+		ack(nil)
+	})
+	cg := &ConsumerGroup{
+		Client: client,
+		GroupConfig: StreamConfig{
+			Streams:       []string{"orders"},
+			Group:         "billing",
+			ConsumerCount: 1,
+			StartID:       "0",
+			Block:         20 * time.Millisecond,
+			ClaimMinIdle:  50 * time.Millisecond,
+			ClaimInterval: 20 * time.Millisecond,
+		},
+	}
+	xadd(t, client, "orders", "value", "foo")
+	done := consume(ctx, cg, h)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for attempts.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cfn()
+	<-done
+
+	if n := attempts.Load(); n < 2 {
+		t.Fatalf("expected the failed entry to be retried, got %d attempts", n)
+	}
+	if n := pending(t, client, "orders", "billing"); n != 0 {
+		t.Errorf("expected no pending entries got %d", n)
+	}
+}
+
+func TestConsumerGroup_ReclaimsFromCrashedConsumer(t *testing.T) {
+	client := newClient(t)
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+
+	if err := client.XGroupCreateMkStream(ctx, "orders", "billing", "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	xadd(t, client, "orders", "value", "foo")
+	// a consumer which reads the entry and crashes before acknowledging it
+	_, err := client.XReadGroup(ctx, &goredis.XReadGroupArgs{
+		Group:    "billing",
+		Consumer: "crashed",
+		Streams:  []string{"orders", ">"},
+	}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	r := ziggurattest.NewRecorder()
+	cg := &ConsumerGroup{
+		Client: client,
+		GroupConfig: StreamConfig{
+			Streams:       []string{"orders"},
+			Group:         "billing",
+			ConsumerCount: 1,
+			Block:         20 * time.Millisecond,
+			ClaimMinIdle:  10 * time.Millisecond,
+		},
+	}
+	done := consume(ctx, cg, r)
+	if !r.WaitFor(t, 1, 2*time.Second) {
+		t.FailNow()
+	}
+	cfn()
+	<-done
+
+	r.AssertValues(t, "foo")
+	if reclaimed, _ := r.Events()[0].Metadata[KeyReclaimed].(bool); !reclaimed {
+		t.Errorf("expected the event to be marked as reclaimed")
+	}
+	if n := pending(t, client, "orders", "billing"); n != 0 {
+		t.Errorf("expected no pending entries got %d", n)
+	}
+}
+
+func TestIdTime(t *testing.T) {
+	fallback := time.Now()
+	if got := idTime("1700000000000-0", fallback); !got.Equal(time.UnixMilli(1700000000000)) {
+		t.Errorf("expected the time from the ID got %v", got)
+	}
+	if got := idTime("invalid", fallback); !got.Equal(fallback) {
+		t.Errorf("expected the fallback time got %v", got)
+	}
+}
@@ -0,0 +1,174 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+type worker struct {
+	client   goredis.UniversalClient
+	config   StreamConfig
+	name     string
+	handler  ziggurat.Handler
+	logger   ziggurat.StructuredLogger
+	inflight sync.WaitGroup
+	err      error
+}
+
+func (w *worker) run(ctx context.Context) {
+	// wait for the deferred acknowledgements to be sent
+	defer w.inflight.Wait()
+
+	streams := make([]string, 0, len(w.config.Streams)*2)
+	streams = append(streams, w.config.Streams...)
+	for range w.config.Streams {
+		streams = append(streams, ">")
+	}
+
+	var lastClaim time.Time
+	for ctx.Err() == nil {
+		if w.config.ClaimInterval > 0 && time.Since(lastClaim) >= w.config.ClaimInterval {
+			w.reclaim(ctx)
+			lastClaim = time.Now()
+		}
+
+		res, err := w.client.XReadGroup(ctx, &goredis.XReadGroupArgs{
+			Group:    w.config.Group,
+			Consumer: w.name,
+			Streams:  streams,
+			Count:    w.config.Count,
+			Block:    w.config.Block,
+		}).Result()
+		switch {
+		case errors.Is(err, goredis.Nil):
+			continue
+		case err != nil && ctx.Err() != nil:
+			return
+		case err != nil && strings.HasPrefix(err.Error(), "NOGROUP"):
+			w.err = err
+			return
+		case err != nil:
+			w.logger.Error("redis read error", err, map[string]any{"consumer": w.name})
+			w.backoff(ctx)
+			continue
+		}
+
+		for _, s := range res {
+			for _, m := range s.Messages {
+				w.process(ctx, s.Stream, m, false)
+			}
+		}
+	}
+}
+
+func (w *worker) backoff(ctx context.Context) {
+	t := time.NewTimer(w.config.Block)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// reclaim takes over the entries which have been pending for longer than ClaimMinIdle,
+// these belong to consumers which crashed or failed to process them
+func (w *worker) reclaim(ctx context.Context) {
+	for _, s := range w.config.Streams {
+		start := "0-0"
+		for ctx.Err() == nil {
+			msgs, next, err := w.client.XAutoClaim(ctx, &goredis.XAutoClaimArgs{
+				Stream:   s,
+				Group:    w.config.Group,
+				Consumer: w.name,
+				MinIdle:  w.config.ClaimMinIdle,
+				Start:    start,
+				Count:    w.config.Count,
+			}).Result()
+			if err != nil {
+				if ctx.Err() == nil {
+					w.logger.Error("redis reclaim error", err, map[string]any{"consumer": w.name, "stream": s})
+				}
+				break
+			}
+			for _, m := range msgs {
+				w.logger.Info("redis reclaimed entry", map[string]any{"consumer": w.name, "stream": s, "id": m.ID})
+				w.process(ctx, s, m, true)
+			}
+			if next == "0-0" || next == "" {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+func (w *worker) process(ctx context.Context, stream string, m goredis.XMessage, reclaimed bool) {
+	fields := make(map[string]string, len(m.Values))
+	for k, v := range m.Values {
+		switch val := v.(type) {
+		case string:
+			fields[k] = val
+		default:
+			fields[k] = fmt.Sprint(val)
+		}
+	}
+
+	now := time.Now()
+	event := &ziggurat.Event{
+		Metadata: map[string]any{
+			KeyStream:    stream,
+			KeyGroup:     w.config.Group,
+			KeyID:        m.ID,
+			KeyFields:    fields,
+			KeyReclaimed: reclaimed,
+		},
+		Value:             []byte(fields[w.config.ValueField]),
+		Key:               []byte(fields[w.config.KeyField]),
+		RoutingPath:       w.config.Group + "/" + stream,
+		ProducerTimestamp: idTime(m.ID, now),
+		ReceivedTimestamp: now,
+		EventType:         EventType,
+	}
+
+	w.inflight.Add(1)
+	actx, ack := ziggurat.WithAck(ctx, func(err error) {
+		defer w.inflight.Done()
+		w.acknowledge(ctx, stream, m.ID, err)
+	})
+	w.handler.Handle(actx, event)
+	ack.Release()
+}
+
+// acknowledge leaves failed entries pending so that they are retried once reclaimed
+func (w *worker) acknowledge(ctx context.Context, stream, id string, err error) {
+	kvs := map[string]any{"consumer": w.name, "stream": stream, "id": id}
+	if err != nil {
+		w.logger.Error("redis entry processing failed, leaving it pending", err, kvs)
+		return
+	}
+	// the entry is acknowledged even when the consumer is shutting down
+	if ackErr := w.client.XAck(context.WithoutCancel(ctx), stream, w.config.Group, id).Err(); ackErr != nil {
+		w.logger.Error("redis ack error", ackErr, kvs)
+	}
+}
+
+// idTime returns the time encoded in the milliseconds part of an auto generated entry ID
+func idTime(id string, fallback time.Time) time.Time {
+	ms, _, ok := strings.Cut(id, "-")
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return time.UnixMilli(n)
+}
@@ -104,22 +104,17 @@ func NewRouter() *Router {
 
 type Middleware func(handler Handler) Handler
 
+// pipe composes the middleware chain once, the first middleware is the outermost
 var pipe = func(h Handler, fs ...Middleware) Handler {
-	if len(fs) < 1 {
-		return h
+	next := h
+	for i := len(fs) - 1; i >= 0; i-- {
+		next = fs[i](next)
 	}
-	last := len(fs) - 1
-	f := func(ctx context.Context, event *Event) {
-		next := h
-		for i := last; i >= 0; i-- {
-			next = fs[i](next)
-		}
-		next.Handle(ctx, event)
-	}
-	return HandlerFunc(f)
+	return next
 }
 
 // Use takes a ziggurat.Handler and wraps it with Middleware
+// the middlewares are invoked once when Use is called and not for every event
 func Use(h Handler, fs ...Middleware) Handler {
 	return pipe(h, fs...)
 }
@@ -0,0 +1,162 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/logger"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	EventType = "schedule"
+	// KeyName holds the name of the schedule
+	KeyName = "schedule-name"
+	// KeyScheduledAt holds the time the tick was scheduled for
+	KeyScheduledAt = "schedule-scheduled-at"
+	// KeyTick holds the number of ticks emitted for the schedule so far, starting at 1
+	KeyTick = "schedule-tick"
+)
+
+// Schedule emits an event either on a cron expression or at a fixed interval
+type Schedule struct {
+	// Name is used as the RoutingPath of the events
+	Name string
+	// Cron is a standard five field cron expression or a descriptor like @hourly or @every 10m
+	Cron string
+	// Every is a fixed interval, it is used when Cron is empty
+	Every time.Duration
+	// Value is set as the value of every event
+	Value []byte
+}
+
+// Consumer emits synthetic events on schedules so that periodic jobs go through the same
+// router, middlewares and shutdown handling as the events of the other consumers
+//
+// A tick is skipped when the event of the previous tick of the same schedule is still being processed,
+// including deferred acknowledgements, unless AllowOverlap is set.
+type Consumer struct {
+	Schedules []Schedule
+	// Location is used to evaluate the cron expressions, it defaults to time.Local
+	Location     *time.Location
+	AllowOverlap bool
+	Logger       ziggurat.StructuredLogger
+	pending      sync.WaitGroup
+}
+
+// every is a cron.Schedule with a fixed interval, cron.Every rounds the interval to seconds
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+func (s Schedule) parse() (cron.Schedule, error) {
+	switch {
+	case s.Name == "":
+		return nil, errors.New("schedule: Name is required")
+	case s.Cron != "":
+		sched, err := parser.Parse(s.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("schedule: invalid cron expression for %s: %w", s.Name, err)
+		}
+		return sched, nil
+	case s.Every > 0:
+		return every(s.Every), nil
+	default:
+		return nil, fmt.Errorf("schedule: %s requires either Cron or Every", s.Name)
+	}
+}
+
+// Consume emits events until ctx is done
+// it waits for the pending acknowledgements before returning
+func (c *Consumer) Consume(ctx context.Context, h ziggurat.Handler) error {
+	if c.Logger == nil {
+		c.Logger = logger.NOOP
+	}
+	if c.Location == nil {
+		c.Location = time.Local
+	}
+	scheds := make([]cron.Schedule, len(c.Schedules))
+	names := map[string]bool{}
+	for i, s := range c.Schedules {
+		sched, err := s.parse()
+		if err != nil {
+			return err
+		}
+		if names[s.Name] {
+			return fmt.Errorf("schedule: duplicate schedule %s", s.Name)
+		}
+		names[s.Name] = true
+		scheds[i] = sched
+	}
+
+	var wg sync.WaitGroup
+	for i, s := range c.Schedules {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.run(ctx, h, s, scheds[i])
+		}()
+	}
+	wg.Wait()
+	c.pending.Wait()
+	return nil
+}
+
+func (c *Consumer) run(ctx context.Context, h ziggurat.Handler, s Schedule, sched cron.Schedule) {
+	var running atomic.Bool
+	var tick int
+	at := sched.Next(time.Now().In(c.Location))
+	for {
+		t := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		kvs := map[string]any{"schedule": s.Name, "scheduled-at": at}
+		if !c.AllowOverlap && !running.CompareAndSwap(false, true) {
+			c.Logger.Warn("schedule skipping tick, the previous tick is still running", kvs)
+		} else {
+			tick++
+			c.emit(ctx, h, s, at, tick, &running)
+		}
+		// ticks missed while the handler was running are skipped
+		at = sched.Next(time.Now().In(c.Location))
+	}
+}
+
+func (c *Consumer) emit(ctx context.Context, h ziggurat.Handler, s Schedule, at time.Time, tick int, running *atomic.Bool) {
+	event := &ziggurat.Event{
+		Metadata: map[string]any{
+			KeyName:        s.Name,
+			KeyScheduledAt: at,
+			KeyTick:        tick,
+		},
+		Value:             append([]byte{}, s.Value...),
+		Key:               []byte(s.Name),
+		RoutingPath:       s.Name,
+		ProducerTimestamp: at,
+		ReceivedTimestamp: time.Now(),
+		EventType:         EventType,
+	}
+
+	c.pending.Add(1)
+	actx, ack := ziggurat.WithAck(ctx, func(err error) {
+		defer c.pending.Done()
+		running.Store(false)
+		c.Logger.Error("schedule tick failed", err, map[string]any{"schedule": s.Name, "tick": tick})
+	})
+	h.Handle(actx, event)
+	ack.Release()
+}
@@ -0,0 +1,117 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/ziggurattest"
+)
+
+func TestConsumer_Every(t *testing.T) {
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	r := ziggurattest.NewRecorder()
+	c := &Consumer{Schedules: []Schedule{
+		{Name: "cleanup", Every: 20 * time.Millisecond, Value: []byte("foo")},
+		{Name: "report", Every: 30 * time.Millisecond},
+	}}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Consume(ctx, r)
+	}()
+	r.WaitFor(t, 6, 2*time.Second)
+	cfn()
+	if err := <-done; err != nil {
+		t.Fatalf("expected nil error got %v", err)
+	}
+
+	ticks := map[string]int{}
+	for _, e := range r.Events() {
+		ticks[e.RoutingPath]++
+		if e.EventType != EventType || e.Metadata[KeyName] != e.RoutingPath {
+			t.Errorf("unexpected event %+v", e)
+		}
+		if e.RoutingPath == "cleanup" && string(e.Value) != "foo" {
+			t.Errorf("expected value foo got %q", e.Value)
+		}
+		if e.Metadata[KeyTick] != ticks[e.RoutingPath] {
+			t.Errorf("expected tick %d got %v", ticks[e.RoutingPath], e.Metadata[KeyTick])
+		}
+	}
+	if ticks["cleanup"] < 2 || ticks["report"] < 2 {
+		t.Errorf("expected ticks for both schedules got %v", ticks)
+	}
+}
+
+func TestConsumer_SkipsOverlappingTicks(t *testing.T) {
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+	r := ziggurattest.NewRecorder()
+	acks := make(chan ziggurat.AckFunc, 10)
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		r.Handle(ctx, event)
+		ack, _ := ziggurat.DeferAck(ctx)
+		acks <- ack
+	})
+	c := &Consumer{Schedules: []Schedule{{Name: "job", Every: 10 * time.Millisecond}}}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Consume(ctx, h)
+	}()
+
+	r.WaitFor(t, 1, 2*time.Second)
+	time.Sleep(50 * time.Millisecond)
+	r.AssertCount(t, 1)
+
+	(<-acks)(nil)
+	r.WaitFor(t, 2, 2*time.Second)
+	(<-acks)(nil)
+	cfn()
+	// a tick may have been emitted after the second ack, Consume waits for it
+	for {
+		select {
+		case ack := <-acks:
+			ack(nil)
+		case <-done:
+			return
+		}
+	}
+}
+
+func TestConsumer_InvalidSchedules(t *testing.T) {
+	cases := map[string][]Schedule{
+		"missing name":    {{Every: time.Second}},
+		"invalid cron":    {{Name: "job", Cron: "not a cron"}},
+		"missing trigger": {{Name: "job"}},
+		"duplicate name":  {{Name: "job", Every: time.Second}, {Name: "job", Every: time.Minute}},
+	}
+	for name, schedules := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := &Consumer{Schedules: schedules}
+			if err := c.Consume(context.Background(), ziggurattest.NewRecorder()); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestSchedule_Cron(t *testing.T) {
+	from := time.Date(2024, 3, 25, 10, 17, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"*/15 * * * *": time.Date(2024, 3, 25, 10, 30, 0, 0, time.UTC),
+		"@hourly":      time.Date(2024, 3, 25, 11, 0, 0, 0, time.UTC),
+		"0 9 * * MON":  time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC),
+		"@every 90s":   from.Add(90 * time.Second),
+	}
+	for expr, want := range cases {
+		sched, err := Schedule{Name: "job", Cron: expr}.parse()
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if got := sched.Next(from); !got.Equal(want) {
+			t.Errorf("%s: expected %v got %v", expr, want, got)
+		}
+	}
+}
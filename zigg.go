@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"github.com/gojekfarm/ziggurat/v2/logger"
+	"io"
 	"sync"
 	"time"
 )
@@ -19,15 +20,21 @@ type Ziggurat struct {
 	Logger          StructuredLogger
 	ShutdownTimeout time.Duration
 	ErrorHandler    func(err error)
+	// Closers are closed in order once the consumers return or the ShutdownTimeout expires
+	// producers used by the handlers can be closed here so that they are flushed after the last event is handled
+	Closers []io.Closer
 }
 
+// Run starts the consumers and blocks until all of them return
+// once ctx is done the consumers are given ShutdownTimeout to return
 func (z *Ziggurat) Run(ctx context.Context, handler Handler, consumers ...MessageConsumer) error {
 
 	z.mustInit(consumers, handler)
 
 	var wg sync.WaitGroup
 	wg.Add(len(consumers))
-	errChan := make(chan error)
+	// buffered so that consumers which return after a shutdown timeout do not block
+	errChan := make(chan error, len(consumers))
 	for i := range consumers {
 		go func(i int) {
 			err := consumers[i].Consume(ctx, handler)
@@ -38,39 +45,56 @@ func (z *Ziggurat) Run(ctx context.Context, handler Handler, consumers ...Messag
 		}(i)
 	}
 
-	timeout := make(chan bool, 1)
-	go func() {
-		<-ctx.Done()
-		<-time.After(z.ShutdownTimeout)
-		z.Logger.Info("ziggurat consumer orchestration wait timeout")
-		timeout <- true
-		close(errChan)
-	}()
-
+	done := make(chan struct{})
 	go func() {
 		wg.Wait()
-		close(errChan)
-		close(timeout)
+		close(done)
 	}()
 
 	var allErrs []error
-	for consErr := range errChan {
+	handleErr := func(consErr error) {
 		if z.ErrorHandler != nil {
 			z.ErrorHandler(consErr)
 		}
 		allErrs = append(allErrs, consErr)
 	}
 
-	if <-timeout {
-		return errors.New("shutdown timeout")
+	ctxDone := ctx.Done()
+	var timeout <-chan time.Time
+	for {
+		select {
+		case consErr := <-errChan:
+			handleErr(consErr)
+		case <-ctxDone:
+			ctxDone = nil
+			timeout = time.After(z.ShutdownTimeout)
+		case <-timeout:
+			z.Logger.Info("ziggurat consumer orchestration wait timeout")
+			return errors.Join(append([]error{errors.New("shutdown timeout")}, z.close()...)...)
+		case <-done:
+			for len(errChan) > 0 {
+				handleErr(<-errChan)
+			}
+			for _, err := range z.close() {
+				handleErr(err)
+			}
+			if len(allErrs) > 0 {
+				return errors.Join(allErrs...)
+			}
+			return ErrCleanShutdown
+		}
 	}
+}
 
-	if len(allErrs) > 0 {
-		return errors.Join(allErrs...)
+func (z *Ziggurat) close() []error {
+	var errs []error
+	for _, c := range z.Closers {
+		if err := c.Close(); err != nil {
+			z.Logger.Error("ziggurat error closing", err)
+			errs = append(errs, err)
+		}
 	}
-
-	return ErrCleanShutdown
-
+	return errs
 }
 
 func (z *Ziggurat) mustInit(consumers []MessageConsumer, handler Handler) {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/gojekfarm/ziggurat/v2/logger"
 	"github.com/stretchr/testify/mock"
+	"io"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -139,4 +140,36 @@ func TestZiggurat_Run(t *testing.T) {
 
 	})
 
+	t.Run("closers are closed after the consumers return", func(t *testing.T) {
+		var consumed atomic.Bool
+		var closedAfterConsume atomic.Bool
+		closer := closerFunc(func() error {
+			closedAfterConsume.Store(consumed.Load())
+			return errors.New("close failed")
+		})
+		zig := Ziggurat{Closers: []io.Closer{closer}}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		mc := MockConsumer{PollInterval: 10 * time.Millisecond}
+		mc.On("Consume", mock.Anything, mock.Anything).Return(nil)
+		handler := HandlerFunc(func(ctx context.Context, event *Event) {
+			consumed.Store(true)
+		})
+
+		err := zig.Run(ctx, handler, &mc)
+		if err == nil || err.Error() != "close failed" {
+			t.Errorf("expected the close error got %v", err)
+		}
+		if !closedAfterConsume.Load() {
+			t.Errorf("expected the closer to be closed after the consumer returned")
+		}
+	})
+
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
 }
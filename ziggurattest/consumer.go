@@ -0,0 +1,107 @@
+package ziggurattest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gojekfarm/ziggurat/v2"
+)
+
+// Consumer is an in-memory ziggurat.MessageConsumer
+// events pushed to it are handed to the handler one at a time in the order they were pushed
+// Consumer supports deferred acknowledgements, Consume waits for them before returning
+type Consumer struct {
+	mu      sync.Mutex
+	queue   []*ziggurat.Event
+	notify  chan struct{}
+	closed  bool
+	acks    []error
+	pending sync.WaitGroup
+}
+
+func NewConsumer() *Consumer {
+	return &Consumer{notify: make(chan struct{}, 1)}
+}
+
+func (c *Consumer) signal() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Push queues events, it can be called before or while Consume is running
+func (c *Consumer) Push(events ...*ziggurat.Event) {
+	c.mu.Lock()
+	c.queue = append(c.queue, events...)
+	c.mu.Unlock()
+	c.signal()
+}
+
+// Close makes Consume return once the queued events are handled
+func (c *Consumer) Close() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.signal()
+}
+
+func (c *Consumer) next() (*ziggurat.Event, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.queue) == 0 {
+		return nil, false, c.closed
+	}
+	e := c.queue[0]
+	c.queue = c.queue[1:]
+	return e, true, false
+}
+
+// Consume hands the queued events to the handler until the Consumer is closed and drained
+// or ctx is done, it returns nil in both cases
+func (c *Consumer) Consume(ctx context.Context, h ziggurat.Handler) error {
+	defer c.pending.Wait()
+	for {
+		e, ok, closed := c.next()
+		if closed {
+			return nil
+		}
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-c.notify:
+			}
+			continue
+		}
+
+		c.pending.Add(1)
+		actx, ack := ziggurat.WithAck(ctx, func(err error) {
+			c.mu.Lock()
+			c.acks = append(c.acks, err)
+			c.mu.Unlock()
+			c.pending.Done()
+		})
+		h.Handle(actx, e)
+		ack.Release()
+	}
+}
+
+// Acks returns the result of every acknowledgement in the order the events were acknowledged
+// a nil error means the event was acknowledged successfully
+func (c *Consumer) Acks() []error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]error{}, c.acks...)
+}
+
+// Run runs ziggurat.Ziggurat with a Consumer which handles the events and returns
+// it returns deterministically once every event is handled and acknowledged
+// the returned error is ziggurat.ErrCleanShutdown unless ctx is done before that
+func Run(ctx context.Context, h ziggurat.Handler, events ...*ziggurat.Event) (*Consumer, error) {
+	c := NewConsumer()
+	c.Push(events...)
+	c.Close()
+	var z ziggurat.Ziggurat
+	return c, z.Run(ctx, h, c)
+}
@@ -0,0 +1,95 @@
+package ziggurattest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/mw/rabbitmq"
+)
+
+func TestRun(t *testing.T) {
+	orders := NewRecorder()
+	payments := NewRecorder()
+	router := ziggurat.NewRouter()
+	router.HandlerFunc("foo.id/orders/", orders.Handle)
+	router.HandlerFunc("foo.id/payments/", payments.Handle)
+
+	c, err := Run(context.Background(), router,
+		NewEvent().Value("o1").Kafka("foo.id", "orders", 0, 1).Build(),
+		NewEvent().Value("p1").Kafka("foo.id", "payments", 1, 7).Build(),
+		NewEvent().Value("o2").Kafka("foo.id", "orders", 1, 2).Build(),
+	)
+	if !errors.Is(err, ziggurat.ErrCleanShutdown) {
+		t.Fatalf("expected a clean shutdown got %v", err)
+	}
+
+	orders.AssertValues(t, "o1", "o2")
+	orders.AssertRoutingPaths(t, "foo.id/orders/0", "foo.id/orders/1")
+	payments.AssertCount(t, 1)
+	if got := payments.Events()[0].Metadata["kafka-offset"]; got != int64(7) {
+		t.Errorf("expected offset 7 got %v", got)
+	}
+	if acks := c.Acks(); len(acks) != 3 {
+		t.Errorf("expected 3 acks got %v", acks)
+	}
+}
+
+func TestConsumer_DeferredAcks(t *testing.T) {
+	deferred := make(chan ziggurat.AckFunc, 2)
+	h := ziggurat.HandlerFunc(func(ctx context.Context, event *ziggurat.Event) {
+		ack, _ := ziggurat.DeferAck(ctx)
+		deferred <- ack
+	})
+	go func() {
+		(<-deferred)(nil)
+		(<-deferred)(errors.New("failed"))
+	}()
+
+	c, _ := Run(context.Background(), h, NewEvent().Build(), NewEvent().Build())
+	acks := c.Acks()
+	if len(acks) != 2 || acks[0] != nil || acks[1] == nil {
+		t.Errorf("expected a successful and a failed ack got %v", acks)
+	}
+}
+
+func TestConsumer_Push(t *testing.T) {
+	r := NewRecorder()
+	c := NewConsumer()
+	ctx, cfn := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		var z ziggurat.Ziggurat
+		done <- z.Run(ctx, r, c)
+	}()
+
+	c.Push(NewEvent().Value("foo").Build())
+	r.WaitFor(t, 1, time.Second)
+	c.Push(NewEvent().Value("bar").Build())
+	r.WaitFor(t, 2, time.Second)
+	cfn()
+	if err := <-done; !errors.Is(err, ziggurat.ErrCleanShutdown) {
+		t.Errorf("expected a clean shutdown got %v", err)
+	}
+	r.AssertValues(t, "foo", "bar")
+}
+
+func TestEventBuilder(t *testing.T) {
+	b := NewEvent().Key("k").Value("v").Kafka("foo.id", "orders", 3, 9).RabbitMQ("orders_retry", 2)
+	e1, e2 := b.Build(), b.Build()
+	if e1 == e2 {
+		t.Error("expected Build to return a new event")
+	}
+	if e1.RoutingPath != "foo.id/orders/3" || e1.EventType != "kafka" {
+		t.Errorf("unexpected kafka fields %q %q", e1.RoutingPath, e1.EventType)
+	}
+	if rabbitmq.RetryCountFor(e1) != 2 {
+		t.Errorf("expected retry count 2 got %d", rabbitmq.RetryCountFor(e1))
+	}
+	e1.Metadata["foo"] = "bar"
+	if _, ok := e2.Metadata["foo"]; ok {
+		t.Error("expected built events not to share metadata")
+	}
+}
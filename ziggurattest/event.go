@@ -0,0 +1,87 @@
+package ziggurattest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+	"github.com/gojekfarm/ziggurat/v2/mw/rabbitmq"
+)
+
+// EventBuilder builds events which look like the ones emitted by the bundled message consumers
+//
+//	e := ziggurattest.NewEvent().Key("id-1").Value("foo").Kafka("foo.id", "orders", 2, 10).Build()
+type EventBuilder struct {
+	e ziggurat.Event
+}
+
+func NewEvent() *EventBuilder {
+	now := time.Now()
+	return &EventBuilder{e: ziggurat.Event{
+		Metadata:          map[string]any{},
+		Key:               []byte{},
+		Value:             []byte{},
+		ProducerTimestamp: now,
+		ReceivedTimestamp: now,
+	}}
+}
+
+func (b *EventBuilder) Key(key string) *EventBuilder {
+	b.e.Key = []byte(key)
+	return b
+}
+
+func (b *EventBuilder) Value(value string) *EventBuilder {
+	b.e.Value = []byte(value)
+	return b
+}
+
+func (b *EventBuilder) RoutingPath(path string) *EventBuilder {
+	b.e.RoutingPath = path
+	return b
+}
+
+func (b *EventBuilder) EventType(eventType string) *EventBuilder {
+	b.e.EventType = eventType
+	return b
+}
+
+func (b *EventBuilder) Metadata(key string, value any) *EventBuilder {
+	b.e.Metadata[key] = value
+	return b
+}
+
+func (b *EventBuilder) ProducerTimestamp(t time.Time) *EventBuilder {
+	b.e.ProducerTimestamp = t
+	return b
+}
+
+// Kafka sets the routing path, event type and metadata the way kafka.ConsumerGroup does
+func (b *EventBuilder) Kafka(groupID string, topic string, partition int32, offset int64) *EventBuilder {
+	b.e.RoutingPath = fmt.Sprintf("%s/%s/%d", groupID, topic, partition)
+	b.e.EventType = "kafka"
+	b.e.Metadata["kafka-topic"] = topic
+	b.e.Metadata["kafka-partition"] = int(partition)
+	b.e.Metadata["kafka-offset"] = offset
+	return b
+}
+
+// KafkaHeaders sets the kafka message headers
+func (b *EventBuilder) KafkaHeaders(headers map[string]string) *EventBuilder {
+	b.e.Metadata["kafka-headers"] = headers
+	return b
+}
+
+// RabbitMQ adds the metadata set by rabbitmq.ARetry when the event is consumed
+// from the queue after being retried retryCount times
+func (b *EventBuilder) RabbitMQ(queueKey string, retryCount int) *EventBuilder {
+	b.e.Metadata[rabbitmq.KeyQueue] = queueKey
+	b.e.Metadata[rabbitmq.KeyRetryCount] = retryCount
+	b.e.Metadata[rabbitmq.KeyHeaders] = map[string]string{"retry-origin": "ziggurat-go"}
+	return b
+}
+
+// Build returns a new event every time it is called
+func (b *EventBuilder) Build() *ziggurat.Event {
+	return b.e.Clone()
+}
@@ -0,0 +1,106 @@
+package ziggurattest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojekfarm/ziggurat/v2"
+)
+
+// Recorder is a ziggurat.Handler which records a clone of every event it handles
+type Recorder struct {
+	mu     sync.Mutex
+	events []*ziggurat.Event
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Handle(ctx context.Context, event *ziggurat.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event.Clone())
+}
+
+// Events returns the recorded events in the order they were handled
+func (r *Recorder) Events() []*ziggurat.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*ziggurat.Event{}, r.events...)
+}
+
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
+
+// WaitFor waits until at least n events are recorded, it fails the test on timeout
+func (r *Recorder) WaitFor(t testing.TB, n int, timeout time.Duration) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for r.Len() < n {
+		if time.Now().After(deadline) {
+			t.Errorf("timed out waiting for %d events, got %d", n, r.Len())
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return true
+}
+
+// AssertCount fails the test if the number of recorded events is not n
+func (r *Recorder) AssertCount(t testing.TB, n int) bool {
+	t.Helper()
+	if got := r.Len(); got != n {
+		t.Errorf("expected %d events got %d", n, got)
+		return false
+	}
+	return true
+}
+
+// AssertValues fails the test if the values of the recorded events do not match values in order
+func (r *Recorder) AssertValues(t testing.TB, values ...string) bool {
+	t.Helper()
+	events := r.Events()
+	got := make([]string, len(events))
+	for i, e := range events {
+		got[i] = string(e.Value)
+	}
+	return assertStrings(t, "values", values, got)
+}
+
+// AssertRoutingPaths fails the test if the routing paths of the recorded events do not match paths in order
+func (r *Recorder) AssertRoutingPaths(t testing.TB, paths ...string) bool {
+	t.Helper()
+	events := r.Events()
+	got := make([]string, len(events))
+	for i, e := range events {
+		got[i] = e.RoutingPath
+	}
+	return assertStrings(t, "routing paths", paths, got)
+}
+
+func assertStrings(t testing.TB, name string, want, got []string) bool {
+	t.Helper()
+	if len(want) != len(got) {
+		t.Errorf("expected %s %q got %q", name, want, got)
+		return false
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			t.Errorf("expected %s %q got %q", name, want, got)
+			return false
+		}
+	}
+	return true
+}